@@ -0,0 +1,332 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"avidlogic/github"
+)
+
+// CommitRecord is a commit with its author identity and per-file line counts
+type CommitRecord struct {
+	Repo      string              `json:"repo"`
+	SHA       string              `json:"sha"`
+	Login     string              `json:"login"` // empty when the email is not linked to a GitHub account
+	Name      string              `json:"name"`
+	Email     string              `json:"email"`
+	Date      time.Time           `json:"date"`
+	Additions int                 `json:"additions"`
+	Deletions int                 `json:"deletions"`
+	Files     []github.CommitFile `json:"files"`
+}
+
+// Contributor is one row of the contributor leaderboard. A contributor merges
+// every email and GitHub login that were seen on the same commits.
+type Contributor struct {
+	Name                 string   `json:"name"`
+	Logins               []string `json:"logins"`
+	Emails               []string `json:"emails"`
+	Commits              int      `json:"commits"`
+	PullRequestsOpened   int      `json:"pull_requests_opened"`
+	PullRequestsReviewed int      `json:"pull_requests_reviewed"`
+	LinesAdded           int      `json:"lines_added"`
+	LinesDeleted         int      `json:"lines_deleted"`
+	identityKey          string
+}
+
+// DirectoryOwnership is the knowledge-concentration score of one directory
+type DirectoryOwnership struct {
+	Directory      string  `json:"directory"`
+	LinesChanged   int     `json:"lines_changed"`
+	Contributors   int     `json:"contributors"`
+	BusFactor      int     `json:"bus_factor"`
+	TopContributor string  `json:"top_contributor"`
+	TopShare       float64 `json:"top_share"` // fraction of lines changed by the top contributor
+}
+
+// commitLimit caps the commits CollectCommits fetches per repository, as
+// every commit costs another request for its files
+const commitLimit = 500
+
+// CollectCommits fetches the commits authored between from and to in the given
+// repositories, including the files each commit touched. Only the most recent
+// commitLimit commits of each repository are fetched.
+func CollectCommits(ctx context.Context, client *github.Client, owner string, repos []string, from, to time.Time) ([]CommitRecord, error) {
+	var records []CommitRecord
+	for _, repo := range repos {
+		// One more than the limit tells whether the range was truncated
+		commits, err := client.ListCommits(ctx, owner, repo, from, to, commitLimit+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(commits) > commitLimit {
+			slog.WarnContext(ctx, "too many commits, only the most recent are analyzed", "repo", owner+"/"+repo, "limit", commitLimit)
+			commits = commits[:commitLimit]
+		}
+
+		repoRecords := make([]CommitRecord, len(commits))
+		err = ForEach(ctx, len(commits), func(ctx context.Context, i int) error {
+			detail, err := client.GetCommit(ctx, owner, repo, commits[i].SHA)
+			if err != nil {
+				return err
+			}
+
+			record := CommitRecord{
+				Repo:      repo,
+				SHA:       detail.SHA,
+				Name:      detail.Commit.Author.Name,
+				Email:     detail.Commit.Author.Email,
+				Date:      detail.Commit.Author.Date,
+				Additions: detail.Stats.Additions,
+				Deletions: detail.Stats.Deletions,
+				Files:     detail.Files,
+			}
+			if detail.Author != nil {
+				record.Login = detail.Author.Login
+			}
+			repoRecords[i] = record
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, repoRecords...)
+	}
+
+	return records, nil
+}
+
+// identities merges emails and logins that belong to the same person using a
+// union-find over "email:" and "login:" keys.
+type identities struct {
+	parent map[string]string
+}
+
+func newIdentities() *identities {
+	return &identities{parent: map[string]string{}}
+}
+
+func (ids *identities) find(key string) string {
+	if _, ok := ids.parent[key]; !ok {
+		ids.parent[key] = key
+	}
+	for ids.parent[key] != key {
+		ids.parent[key] = ids.parent[ids.parent[key]]
+		key = ids.parent[key]
+	}
+	return key
+}
+
+func (ids *identities) union(a, b string) {
+	rootA, rootB := ids.find(a), ids.find(b)
+	if rootA == rootB {
+		return
+	}
+	// Keep the lexically smallest root so results are deterministic
+	if rootB < rootA {
+		rootA, rootB = rootB, rootA
+	}
+	ids.parent[rootB] = rootA
+}
+
+func emailKey(email string) string { return "email:" + strings.ToLower(strings.TrimSpace(email)) }
+func loginKey(login string) string { return "login:" + strings.ToLower(login) }
+
+// commitKey returns the identity key of a commit's author
+func (ids *identities) commitKey(record CommitRecord) string {
+	switch {
+	case record.Login != "" && record.Email != "":
+		ids.union(loginKey(record.Login), emailKey(record.Email))
+		return loginKey(record.Login)
+	case record.Login != "":
+		return loginKey(record.Login)
+	default:
+		return emailKey(record.Email)
+	}
+}
+
+// Contributors builds the contributor leaderboard from commits and pull requests,
+// sorted by commits, then lines changed.
+func Contributors(commits []CommitRecord, pulls []PullRequestRecord) []Contributor {
+	ids := newIdentities()
+	for _, commit := range commits {
+		ids.commitKey(commit)
+	}
+
+	byRoot := map[string]*Contributor{}
+	contributor := func(key string) *Contributor {
+		root := ids.find(key)
+		if byRoot[root] == nil {
+			byRoot[root] = &Contributor{identityKey: root}
+		}
+		return byRoot[root]
+	}
+
+	for _, commit := range commits {
+		entry := contributor(ids.commitKey(commit))
+		entry.Commits++
+		entry.LinesAdded += commit.Additions
+		entry.LinesDeleted += commit.Deletions
+		if commit.Login != "" {
+			entry.Logins = appendUnique(entry.Logins, commit.Login)
+		}
+		if commit.Email != "" {
+			entry.Emails = appendUnique(entry.Emails, strings.ToLower(commit.Email))
+		}
+		if entry.Name == "" {
+			entry.Name = commit.Name
+		}
+	}
+
+	for _, pr := range pulls {
+		if pr.Author != "" {
+			entry := contributor(loginKey(pr.Author))
+			entry.PullRequestsOpened++
+			entry.Logins = appendUnique(entry.Logins, pr.Author)
+		}
+		for _, reviewer := range pr.Reviewers {
+			entry := contributor(loginKey(reviewer))
+			entry.PullRequestsReviewed++
+			entry.Logins = appendUnique(entry.Logins, reviewer)
+		}
+	}
+
+	leaderboard := make([]Contributor, 0, len(byRoot))
+	for _, entry := range byRoot {
+		sort.Strings(entry.Logins)
+		sort.Strings(entry.Emails)
+		if len(entry.Logins) > 0 {
+			entry.Name = entry.Logins[0]
+		}
+		leaderboard = append(leaderboard, *entry)
+	}
+
+	sort.Slice(leaderboard, func(i, j int) bool {
+		a, b := leaderboard[i], leaderboard[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		if a.LinesAdded+a.LinesDeleted != b.LinesAdded+b.LinesDeleted {
+			return a.LinesAdded+a.LinesDeleted > b.LinesAdded+b.LinesDeleted
+		}
+		return a.identityKey < b.identityKey
+	})
+
+	return leaderboard
+}
+
+// BusFactor computes, for every directory up to depth levels below each
+// repository root, how concentrated authorship is. The bus factor is the
+// smallest number of contributors who together changed at least half of the
+// directory's lines.
+func BusFactor(commits []CommitRecord, depth int) []DirectoryOwnership {
+	ids := newIdentities()
+	for _, commit := range commits {
+		ids.commitKey(commit)
+	}
+
+	names := map[string]string{}
+	lines := map[string]map[string]int{} // directory -> identity root -> lines changed
+	for _, commit := range commits {
+		root := ids.find(ids.commitKey(commit))
+		if commit.Login != "" || names[root] == "" {
+			names[root] = displayName(commit)
+		}
+
+		for _, file := range commit.Files {
+			changed := file.Additions + file.Deletions
+			for _, dir := range directories(commit.Repo, file.Filename, depth) {
+				if lines[dir] == nil {
+					lines[dir] = map[string]int{}
+				}
+				lines[dir][root] += changed
+			}
+		}
+	}
+
+	ownership := make([]DirectoryOwnership, 0, len(lines))
+	for dir, byAuthor := range lines {
+		ownership = append(ownership, directoryOwnership(dir, byAuthor, names))
+	}
+	sort.Slice(ownership, func(i, j int) bool { return ownership[i].Directory < ownership[j].Directory })
+
+	return ownership
+}
+
+func directoryOwnership(dir string, byAuthor map[string]int, names map[string]string) DirectoryOwnership {
+	type share struct {
+		author string
+		lines  int
+	}
+
+	total := 0
+	shares := make([]share, 0, len(byAuthor))
+	for author, changed := range byAuthor {
+		total += changed
+		shares = append(shares, share{author, changed})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].lines != shares[j].lines {
+			return shares[i].lines > shares[j].lines
+		}
+		return shares[i].author < shares[j].author
+	})
+
+	result := DirectoryOwnership{Directory: dir, LinesChanged: total, Contributors: len(shares)}
+	if len(shares) == 0 {
+		return result
+	}
+
+	result.TopContributor = names[shares[0].author]
+	if total > 0 {
+		result.TopShare = round(float64(shares[0].lines) / float64(total))
+	}
+
+	covered := 0
+	for _, s := range shares {
+		result.BusFactor++
+		covered += s.lines
+		if covered*2 >= total {
+			break
+		}
+	}
+
+	return result
+}
+
+// directories returns the repository root and each parent directory of file, up to depth levels deep
+func directories(repo, file string, depth int) []string {
+	dirs := []string{repo}
+	parts := strings.Split(path.Dir(file), "/")
+	if parts[0] == "." {
+		return dirs
+	}
+
+	for i := 0; i < len(parts) && i < depth; i++ {
+		dirs = append(dirs, repo+"/"+strings.Join(parts[:i+1], "/"))
+	}
+	return dirs
+}
+
+func displayName(commit CommitRecord) string {
+	if commit.Login != "" {
+		return commit.Login
+	}
+	if commit.Name != "" {
+		return commit.Name
+	}
+	return commit.Email
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,63 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"avidlogic/github"
+)
+
+// serveCommits serves n commits of acme/api, newest first, each adding one
+// line to README.md
+func serveCommits(fake *fakeGitHub, n int) {
+	for i := n; i >= 1; i-- {
+		var commit github.Commit
+		commit.SHA = fmt.Sprintf("sha%d", i)
+		commit.Commit.Author = github.CommitAuthor{Name: "Alice", Email: "alice@example.com", Date: day.Add(time.Duration(i) * time.Minute)}
+		fake.list("/repos/acme/api/commits", "", commit)
+		commit.Author = &github.User{Login: "alice"}
+		commit.Stats.Additions = 1
+		commit.Files = []github.CommitFile{{Filename: "README.md", Additions: 1}}
+		fake.object("/repos/acme/api/commits/"+commit.SHA, commit)
+	}
+}
+
+func TestCollectCommits(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	serveCommits(fake, 3)
+
+	records, err := CollectCommits(context.Background(), client, "acme", []string{"api"}, day, day.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	for i, record := range records {
+		sha := fmt.Sprintf("sha%d", 3-i)
+		if record.SHA != sha || record.Login != "alice" || record.Additions != 1 || len(record.Files) != 1 {
+			t.Errorf("record %d = %+v, want %s with its author and files", i, record, sha)
+		}
+	}
+}
+
+func TestCollectCommitsIsBounded(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	serveCommits(fake, commitLimit+150)
+
+	records, err := CollectCommits(context.Background(), client, "acme", []string{"api"}, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != commitLimit {
+		t.Errorf("got %d records, want %d", len(records), commitLimit)
+	}
+	if got, want := fake.count("/repos/acme/api/commits"), 6; got != want {
+		t.Errorf("got %d list requests, want %d", got, want)
+	}
+	if got := fake.count("/repos/acme/api/commits/sha1"); got != 0 {
+		t.Errorf("fetched a commit past the limit")
+	}
+}
@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+)
+
+// fetchConcurrency is how many GitHub requests one collection sends at once
+const fetchConcurrency = 4
+
+// ForEach calls fn for every index from 0 to n-1, running at most
+// fetchConcurrency calls at once, to fetch details from GitHub per item. The first error cancels the context of the
+// other calls and is returned.
+func ForEach(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	slots := make(chan struct{}, fetchConcurrency)
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"avidlogic/github"
+)
+
+// fakeGitHub serves lists of items on API paths, paginated like the GitHub
+// REST API with per_page, page and Link headers, and counts the requests.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	lists    map[string][]any // path -> items
+	keys     map[string]string
+	objects  map[string]any // path -> single object
+	requests map[string]int
+}
+
+// newFakeGitHub starts a fake API and returns a client for it
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *github.Client) {
+	t.Helper()
+	f := &fakeGitHub{lists: map[string][]any{}, keys: map[string]string{}, objects: map[string]any{}, requests: map[string]int{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, github.NewClient(srv.URL, "token")
+}
+
+// list serves items on path, wrapped in an object under key when key is set
+func (f *fakeGitHub) list(path, key string, items ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lists[path] = append(f.lists[path], items...)
+	f.keys[path] = key
+}
+
+// object serves a single object on path
+func (f *fakeGitHub) object(path string, v any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[path] = v
+}
+
+// count returns how many requests were made to path
+func (f *fakeGitHub) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[r.URL.Path]++
+	w.Header().Set("Content-Type", "application/json")
+
+	if v, ok := f.objects[r.URL.Path]; ok {
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+
+	items, ok := f.lists[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"Not Found"}`)
+		return
+	}
+
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage <= 0 {
+		perPage = 30
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.RequestURI()))
+	}
+
+	pageItems := items[start:end]
+	if key := f.keys[r.URL.Path]; key != "" {
+		json.NewEncoder(w).Encode(map[string]any{"total_count": len(items), key: pageItems})
+		return
+	}
+	json.NewEncoder(w).Encode(pageItems)
+}
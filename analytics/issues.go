@@ -0,0 +1,252 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"avidlogic/github"
+)
+
+// unlabeled is the label bucket used for issues without labels
+const unlabeled = "(unlabeled)"
+
+// Issue age buckets, by days since the issue was opened
+var ageBuckets = []struct {
+	Name    string
+	MaxDays int // exclusive upper bound, 0 means unbounded
+}{
+	{"0-7d", 7},
+	{"7-30d", 30},
+	{"30-90d", 90},
+	{"90-365d", 365},
+	{"365d+", 0},
+}
+
+// IssueRecord is an issue together with the repository it belongs to
+type IssueRecord struct {
+	Repo string
+	github.Issue
+}
+
+// IssueAging is the open-issue age distribution of a repository
+type IssueAging struct {
+	Repo          string         `json:"repo"`
+	Open          int            `json:"open"`
+	MedianAgeDays float64        `json:"median_age_days"`
+	Buckets       map[string]int `json:"buckets"`
+}
+
+// WeeklyFlow counts issues opened and closed in one week (starting Monday, UTC)
+type WeeklyFlow struct {
+	WeekStart time.Time `json:"week_start"`
+	Opened    int       `json:"opened"`
+	Closed    int       `json:"closed"`
+	Net       int       `json:"net"`
+}
+
+// IssueFlow is the weekly inflow and outflow of a repository
+type IssueFlow struct {
+	Repo  string       `json:"repo"`
+	Weeks []WeeklyFlow `json:"weeks"`
+}
+
+// StaleIssue is an open issue without activity for a while
+type StaleIssue struct {
+	Repo         string    `json:"repo"`
+	Number       int       `json:"number"`
+	Title        string    `json:"title"`
+	URL          string    `json:"url"`
+	Labels       []string  `json:"labels"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	DaysInactive int       `json:"days_inactive"`
+}
+
+// LabelBreakdown counts open issues per label in a repository
+type LabelBreakdown struct {
+	Repo   string         `json:"repo"`
+	Open   int            `json:"open"`
+	Labels map[string]int `json:"labels"`
+}
+
+// IssueLimit caps the issues CollectIssues fetches per repository, as every
+// hundred issues cost a request
+const IssueLimit = 1000
+
+// CollectIssues fetches issues in the given state from every repository,
+// the IssueLimit most recently created of each at most, and returns them with
+// the repositories that had more. When since is non-zero only issues updated
+// at or after it are returned.
+func CollectIssues(ctx context.Context, client *github.Client, owner string, repos []string, state string, since time.Time) ([]IssueRecord, []string, error) {
+	var records []IssueRecord
+	var truncated []string
+	for _, repo := range repos {
+		// One more than the limit tells whether the list was truncated
+		issues, err := client.ListIssues(ctx, owner, repo, state, since, IssueLimit+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(issues) > IssueLimit {
+			slog.WarnContext(ctx, "too many issues, only the most recent are analyzed", "repo", owner+"/"+repo, "limit", IssueLimit)
+			issues = issues[:IssueLimit]
+			truncated = append(truncated, repo)
+		}
+		for _, issue := range issues {
+			records = append(records, IssueRecord{Repo: repo, Issue: issue})
+		}
+	}
+	return records, truncated, nil
+}
+
+// byRepo groups issues by repository, making sure every repository is present even without issues
+func byRepo(repos []string, issues []IssueRecord) map[string][]IssueRecord {
+	grouped := make(map[string][]IssueRecord, len(repos))
+	for _, repo := range repos {
+		grouped[repo] = nil
+	}
+	for _, issue := range issues {
+		grouped[issue.Repo] = append(grouped[issue.Repo], issue)
+	}
+	return grouped
+}
+
+// IssueAges buckets open issues by age for every repository
+func IssueAges(repos []string, issues []IssueRecord, now time.Time) []IssueAging {
+	grouped := byRepo(repos, issues)
+
+	var result []IssueAging
+	for _, repo := range repos {
+		aging := IssueAging{Repo: repo, Buckets: map[string]int{}}
+		for _, bucket := range ageBuckets {
+			aging.Buckets[bucket.Name] = 0
+		}
+
+		var ages []float64
+		for _, issue := range grouped[repo] {
+			if issue.State != "open" {
+				continue
+			}
+			days := now.Sub(issue.CreatedAt).Hours() / 24
+			ages = append(ages, days)
+			aging.Buckets[ageBucket(days)]++
+		}
+
+		aging.Open = len(ages)
+		aging.MedianAgeDays = round(Percentile(ages, 50))
+		result = append(result, aging)
+	}
+	return result
+}
+
+func ageBucket(days float64) string {
+	for _, bucket := range ageBuckets {
+		if bucket.MaxDays == 0 || days < float64(bucket.MaxDays) {
+			return bucket.Name
+		}
+	}
+	return ageBuckets[len(ageBuckets)-1].Name
+}
+
+// IssueFlows counts issues opened and closed per week between from and to for every repository
+func IssueFlows(repos []string, issues []IssueRecord, from, to time.Time) []IssueFlow {
+	grouped := byRepo(repos, issues)
+
+	var result []IssueFlow
+	for _, repo := range repos {
+		weeks := map[time.Time]*WeeklyFlow{}
+		var order []time.Time
+		for week := weekStart(from); !week.After(to); week = week.AddDate(0, 0, 7) {
+			weeks[week] = &WeeklyFlow{WeekStart: week}
+			order = append(order, week)
+		}
+
+		for _, issue := range grouped[repo] {
+			if inRange(issue.CreatedAt, from, to) {
+				weeks[weekStart(issue.CreatedAt)].Opened++
+			}
+			if issue.ClosedAt != nil && inRange(*issue.ClosedAt, from, to) {
+				weeks[weekStart(*issue.ClosedAt)].Closed++
+			}
+		}
+
+		flow := IssueFlow{Repo: repo, Weeks: make([]WeeklyFlow, 0, len(order))}
+		for _, week := range order {
+			entry := weeks[week]
+			entry.Net = entry.Opened - entry.Closed
+			flow.Weeks = append(flow.Weeks, *entry)
+		}
+		result = append(result, flow)
+	}
+	return result
+}
+
+// weekStart returns midnight UTC of the Monday of t's week
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+func inRange(t, from, to time.Time) bool {
+	return !t.Before(from) && !t.After(to)
+}
+
+// StaleIssues lists open issues not updated for at least days days, least recently updated first
+func StaleIssues(issues []IssueRecord, now time.Time, days int) []StaleIssue {
+	cutoff := now.AddDate(0, 0, -days)
+
+	result := []StaleIssue{}
+	for _, issue := range issues {
+		if issue.State != "open" || issue.UpdatedAt.After(cutoff) {
+			continue
+		}
+		result = append(result, StaleIssue{
+			Repo:         issue.Repo,
+			Number:       issue.Number,
+			Title:        issue.Title,
+			URL:          issue.HTMLURL,
+			Labels:       labelNames(issue.Issue),
+			UpdatedAt:    issue.UpdatedAt,
+			DaysInactive: int(now.Sub(issue.UpdatedAt).Hours() / 24),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].UpdatedAt.Before(result[j].UpdatedAt) })
+	return result
+}
+
+// LabelBreakdowns counts open issues per label for every repository. An issue
+// with several labels is counted once per label.
+func LabelBreakdowns(repos []string, issues []IssueRecord) []LabelBreakdown {
+	grouped := byRepo(repos, issues)
+
+	var result []LabelBreakdown
+	for _, repo := range repos {
+		breakdown := LabelBreakdown{Repo: repo, Labels: map[string]int{}}
+		for _, issue := range grouped[repo] {
+			if issue.State != "open" {
+				continue
+			}
+			breakdown.Open++
+
+			labels := labelNames(issue.Issue)
+			if len(labels) == 0 {
+				labels = []string{unlabeled}
+			}
+			for _, label := range labels {
+				breakdown.Labels[label]++
+			}
+		}
+		result = append(result, breakdown)
+	}
+	return result
+}
+
+func labelNames(issue github.Issue) []string {
+	names := make([]string, 0, len(issue.Labels))
+	for _, label := range issue.Labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
@@ -0,0 +1,244 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"avidlogic/github"
+)
+
+// monday is midnight UTC starting the week of day
+var monday = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func ptr(t time.Time) *time.Time { return &t }
+
+// issue returns an issue of repo api, open unless closed is set
+func issue(number int, created, updated time.Time, closed *time.Time, labels ...string) IssueRecord {
+	i := IssueRecord{Repo: "api", Issue: github.Issue{Number: number, State: "open", CreatedAt: created, UpdatedAt: updated}}
+	if closed != nil {
+		i.State, i.ClosedAt = "closed", closed
+	}
+	for _, label := range labels {
+		i.Labels = append(i.Labels, github.Label{Name: label})
+	}
+	return i
+}
+
+func TestIssueAges(t *testing.T) {
+	now := monday
+	daysAgo := func(days float64) time.Time { return now.Add(-time.Duration(days * 24 * float64(time.Hour))) }
+
+	tests := []struct {
+		name   string
+		issues []IssueRecord
+		bucket map[string]int
+		median float64
+	}{
+		{"none", nil, map[string]int{}, 0},
+		{"just opened", []IssueRecord{issue(1, now, now, nil)}, map[string]int{"0-7d": 1}, 0},
+		{"bucket bounds are exclusive", []IssueRecord{
+			issue(1, daysAgo(6.9), now, nil),
+			issue(2, daysAgo(7), now, nil),
+			issue(3, daysAgo(30), now, nil),
+			issue(4, daysAgo(90), now, nil),
+			issue(5, daysAgo(365), now, nil),
+			issue(6, daysAgo(1000), now, nil),
+		}, map[string]int{"0-7d": 1, "7-30d": 1, "30-90d": 1, "90-365d": 1, "365d+": 2}, 60},
+		{"closed issues are ignored", []IssueRecord{
+			issue(1, daysAgo(10), now, nil),
+			issue(2, daysAgo(100), now, ptr(now)),
+		}, map[string]int{"7-30d": 1}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IssueAges([]string{"api", "web"}, tt.issues, now)
+			if len(got) != 2 || got[0].Repo != "api" || got[1].Repo != "web" || got[1].Open != 0 {
+				t.Fatalf("got %+v, want api and an empty web", got)
+			}
+			open := 0
+			for _, bucket := range ageBuckets {
+				open += tt.bucket[bucket.Name]
+				if got[0].Buckets[bucket.Name] != tt.bucket[bucket.Name] {
+					t.Errorf("bucket %s = %d, want %d", bucket.Name, got[0].Buckets[bucket.Name], tt.bucket[bucket.Name])
+				}
+			}
+			if got[0].Open != open || got[0].MedianAgeDays != tt.median {
+				t.Errorf("got %d open with median %v days, want %d with %v", got[0].Open, got[0].MedianAgeDays, open, tt.median)
+			}
+		})
+	}
+}
+
+func TestIssueFlows(t *testing.T) {
+	// Two full weeks starting on a Monday
+	from, to := monday, monday.AddDate(0, 0, 14).Add(-time.Second)
+	second := monday.AddDate(0, 0, 7)
+
+	tests := []struct {
+		name   string
+		issues []IssueRecord
+		opened []int
+		closed []int
+	}{
+		{"none", nil, []int{0, 0}, []int{0, 0}},
+		{"opened at the start of a week", []IssueRecord{
+			issue(1, monday, monday, nil),
+			issue(2, second, second, nil),
+		}, []int{1, 1}, []int{0, 0}},
+		{"opened at the end of a week", []IssueRecord{
+			issue(1, second.Add(-time.Second), second, nil),
+		}, []int{1, 0}, []int{0, 0}},
+		{"closed at the start of a week", []IssueRecord{
+			issue(1, monday.AddDate(0, 0, -30), second, ptr(second)),
+		}, []int{0, 0}, []int{0, 1}},
+		{"opened and closed in different weeks", []IssueRecord{
+			issue(1, monday.Add(time.Hour), second, ptr(second.Add(time.Hour))),
+		}, []int{1, 0}, []int{0, 1}},
+		{"outside the range", []IssueRecord{
+			issue(1, monday.Add(-time.Second), monday, ptr(to.Add(time.Second))),
+		}, []int{0, 0}, []int{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IssueFlows([]string{"api"}, tt.issues, from, to)
+			if len(got) != 1 || len(got[0].Weeks) != 2 {
+				t.Fatalf("got %+v, want two weeks of api", got)
+			}
+			for i, week := range got[0].Weeks {
+				if want := monday.AddDate(0, 0, 7*i); !week.WeekStart.Equal(want) {
+					t.Errorf("week %d starts %v, want %v", i, week.WeekStart, want)
+				}
+				if week.Opened != tt.opened[i] || week.Closed != tt.closed[i] || week.Net != tt.opened[i]-tt.closed[i] {
+					t.Errorf("week %d = %+v, want %d opened and %d closed", i, week, tt.opened[i], tt.closed[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want time.Time
+	}{
+		{monday, monday},
+		{monday.Add(-time.Nanosecond), monday.AddDate(0, 0, -7)},
+		{monday.AddDate(0, 0, 6).Add(23 * time.Hour), monday},
+		// Monday 01:00 in UTC+2 is still Sunday in UTC
+		{time.Date(2026, 3, 2, 1, 0, 0, 0, time.FixedZone("EET", 2*60*60)), monday.AddDate(0, 0, -7)},
+	}
+	for _, tt := range tests {
+		if got := weekStart(tt.t); !got.Equal(tt.want) {
+			t.Errorf("weekStart(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestStaleIssues(t *testing.T) {
+	now := monday
+	cutoff := now.AddDate(0, 0, -30)
+	issues := []IssueRecord{
+		issue(1, cutoff, cutoff, nil, "bug"),
+		issue(2, cutoff, cutoff.Add(time.Second), nil),
+		issue(3, cutoff, cutoff.AddDate(0, 0, -10), nil),
+		issue(4, cutoff, cutoff.AddDate(0, 0, -20), ptr(cutoff)),
+	}
+
+	tests := []struct {
+		name string
+		days int
+		want []int
+	}{
+		{"updated exactly at the threshold is stale", 30, []int{3, 1}},
+		{"longer threshold", 40, []int{3}},
+		{"shorter threshold", 1, []int{3, 1, 2}},
+		{"beyond every issue", 100, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StaleIssues(issues, now, tt.days)
+			numbers := make([]int, 0, len(got))
+			for _, stale := range got {
+				numbers = append(numbers, stale.Number)
+			}
+			if len(numbers) != len(tt.want) {
+				t.Fatalf("got issues %v, want %v", numbers, tt.want)
+			}
+			for i := range numbers {
+				if numbers[i] != tt.want[i] {
+					t.Fatalf("got issues %v, want %v", numbers, tt.want)
+				}
+			}
+		})
+	}
+
+	got := StaleIssues(issues, now, 30)
+	if got[1].DaysInactive != 30 || len(got[1].Labels) != 1 || got[1].Labels[0] != "bug" {
+		t.Errorf("got %+v, want 30 days inactive with label bug", got[1])
+	}
+}
+
+func TestLabelBreakdowns(t *testing.T) {
+	now := monday
+	tests := []struct {
+		name   string
+		issues []IssueRecord
+		open   int
+		labels map[string]int
+	}{
+		{"none", nil, 0, map[string]int{}},
+		{"unlabeled", []IssueRecord{issue(1, now, now, nil)}, 1, map[string]int{unlabeled: 1}},
+		{"counted once per label", []IssueRecord{
+			issue(1, now, now, nil, "bug", "ui"),
+			issue(2, now, now, nil, "bug"),
+		}, 2, map[string]int{"bug": 2, "ui": 1}},
+		{"closed issues are ignored", []IssueRecord{
+			issue(1, now, now, ptr(now), "bug"),
+			issue(2, now, now, nil),
+		}, 1, map[string]int{unlabeled: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LabelBreakdowns([]string{"api"}, tt.issues)
+			if len(got) != 1 || got[0].Open != tt.open || len(got[0].Labels) != len(tt.labels) {
+				t.Fatalf("got %+v, want %d open with labels %v", got, tt.open, tt.labels)
+			}
+			for label, count := range tt.labels {
+				if got[0].Labels[label] != count {
+					t.Errorf("label %s = %d, want %d", label, got[0].Labels[label], count)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectIssuesIsBounded(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	// The issues API lists pull requests too, which do not count
+	for number := IssueLimit + 200; number >= 1; number-- {
+		item := github.Issue{Number: number, State: "open", CreatedAt: day}
+		if number%10 == 0 {
+			item.PullRequest = &struct{}{}
+		}
+		fake.list("/repos/acme/api/issues", "", item)
+	}
+	fake.list("/repos/acme/web/issues", "", github.Issue{Number: 1, State: "open", CreatedAt: day})
+
+	records, truncated, err := CollectIssues(context.Background(), client, "acme", []string{"api", "web"}, "open", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(truncated) != 1 || truncated[0] != "api" {
+		t.Errorf("got truncated %v, want [api]", truncated)
+	}
+	if len(records) != IssueLimit+1 || records[0].Number != IssueLimit+199 || records[IssueLimit].Repo != "web" {
+		t.Errorf("got %d records starting at issue %d, want the %d most recent of api and the one of web",
+			len(records), records[0].Number, IssueLimit)
+	}
+	for _, record := range records {
+		if record.PullRequest != nil {
+			t.Fatalf("got pull request %d among the issues", record.Number)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"avidlogic/github"
+)
+
+// Size buckets are based on the number of changed lines (additions + deletions)
+var sizeBuckets = []struct {
+	Name  string
+	Limit int // inclusive upper bound, 0 means unbounded
+}{
+	{"xs", 9},
+	{"s", 49},
+	{"m", 249},
+	{"l", 999},
+	{"xl", 0},
+}
+
+// PullRequestRecord holds the timeline of a single pull request
+type PullRequestRecord struct {
+	Repo          string     `json:"repo"`
+	Number        int        `json:"number"`
+	Author        string     `json:"author"`
+	CreatedAt     time.Time  `json:"created_at"`
+	FirstReviewAt *time.Time `json:"first_review_at"`
+	ApprovedAt    *time.Time `json:"approved_at"`
+	MergedAt      *time.Time `json:"merged_at"`
+	Additions     int        `json:"additions"`
+	Deletions     int        `json:"deletions"`
+	Reviewers     []string   `json:"reviewers"`
+}
+
+// SizeStats describes the distribution of pull request sizes in changed lines
+type SizeStats struct {
+	P50     float64        `json:"p50_lines"`
+	P90     float64        `json:"p90_lines"`
+	Buckets map[string]int `json:"buckets"`
+}
+
+// PullRequestStats aggregates cycle-time metrics for a repository or an author
+type PullRequestStats struct {
+	Key               string        `json:"key"`
+	PullRequests      int           `json:"pull_requests"`
+	Merged            int           `json:"merged"`
+	TimeToFirstReview DurationStats `json:"time_to_first_review"`
+	TimeToApproval    DurationStats `json:"time_to_approval"`
+	TimeToMerge       DurationStats `json:"time_to_merge"`
+	Size              SizeStats     `json:"size"`
+}
+
+// PullRequestLimit caps the pull requests whose details are fetched per
+// repository, as every pull request costs more requests
+const PullRequestLimit = 300
+
+// CollectPullRequests fetches the pull requests created between from and to in
+// the given repositories, together with their reviews and size. Only the most
+// recent PullRequestLimit pull requests of each repository are fetched.
+func CollectPullRequests(ctx context.Context, client *github.Client, owner string, repos []string, from, to time.Time) ([]PullRequestRecord, error) {
+	var records []PullRequestRecord
+	for _, repo := range repos {
+		// One more than the limit tells whether the range was truncated
+		pulls, err := client.ListPullRequests(ctx, owner, repo, from, to, PullRequestLimit+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(pulls) > PullRequestLimit {
+			slog.WarnContext(ctx, "too many pull requests, only the most recent are analyzed", "repo", owner+"/"+repo, "limit", PullRequestLimit)
+			pulls = pulls[:PullRequestLimit]
+		}
+
+		repoRecords := make([]PullRequestRecord, len(pulls))
+		err = ForEach(ctx, len(pulls), func(ctx context.Context, i int) error {
+			detail, err := client.GetPullRequest(ctx, owner, repo, pulls[i].Number)
+			if err != nil {
+				return err
+			}
+			reviews, err := client.ListReviews(ctx, owner, repo, pulls[i].Number)
+			if err != nil {
+				return err
+			}
+			repoRecords[i] = newPullRequestRecord(repo, detail, reviews)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, repoRecords...)
+	}
+
+	return records, nil
+}
+
+// newPullRequestRecord derives review milestones from the raw reviews. Reviews
+// left by the author and pending reviews are ignored.
+func newPullRequestRecord(repo string, pr *github.PullRequest, reviews []github.Review) PullRequestRecord {
+	record := PullRequestRecord{
+		Repo:      repo,
+		Number:    pr.Number,
+		Author:    pr.User.Login,
+		CreatedAt: pr.CreatedAt,
+		MergedAt:  pr.MergedAt,
+		Additions: pr.Additions,
+		Deletions: pr.Deletions,
+	}
+
+	seen := map[string]bool{}
+	for _, review := range reviews {
+		if review.State == "PENDING" || review.User.Login == pr.User.Login || review.SubmittedAt.IsZero() {
+			continue
+		}
+
+		submitted := review.SubmittedAt
+		if record.FirstReviewAt == nil || submitted.Before(*record.FirstReviewAt) {
+			record.FirstReviewAt = &submitted
+		}
+		if review.State == "APPROVED" && (record.ApprovedAt == nil || submitted.Before(*record.ApprovedAt)) {
+			record.ApprovedAt = &submitted
+		}
+		if !seen[review.User.Login] {
+			seen[review.User.Login] = true
+			record.Reviewers = append(record.Reviewers, review.User.Login)
+		}
+	}
+
+	return record
+}
+
+// SummarizePullRequests groups records with keyOf and computes stats for each group, sorted by key
+func SummarizePullRequests(records []PullRequestRecord, keyOf func(PullRequestRecord) string) []PullRequestStats {
+	groups := map[string][]PullRequestRecord{}
+	for _, record := range records {
+		key := keyOf(record)
+		groups[key] = append(groups[key], record)
+	}
+
+	stats := make([]PullRequestStats, 0, len(groups))
+	for key, group := range groups {
+		stats = append(stats, summarizeGroup(key, group))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+
+	return stats
+}
+
+// ByRepo groups pull request records by repository
+func ByRepo(record PullRequestRecord) string { return record.Repo }
+
+// ByAuthor groups pull request records by author login
+func ByAuthor(record PullRequestRecord) string { return record.Author }
+
+func summarizeGroup(key string, records []PullRequestRecord) PullRequestStats {
+	var firstReview, approval, merge []time.Duration
+	sizes := make([]float64, 0, len(records))
+	buckets := map[string]int{}
+	for _, bucket := range sizeBuckets {
+		buckets[bucket.Name] = 0
+	}
+
+	merged := 0
+	for _, record := range records {
+		if record.FirstReviewAt != nil {
+			firstReview = append(firstReview, record.FirstReviewAt.Sub(record.CreatedAt))
+		}
+		if record.ApprovedAt != nil {
+			approval = append(approval, record.ApprovedAt.Sub(record.CreatedAt))
+		}
+		if record.MergedAt != nil {
+			merged++
+			merge = append(merge, record.MergedAt.Sub(record.CreatedAt))
+		}
+
+		lines := record.Additions + record.Deletions
+		sizes = append(sizes, float64(lines))
+		buckets[sizeBucket(lines)]++
+	}
+
+	return PullRequestStats{
+		Key:               key,
+		PullRequests:      len(records),
+		Merged:            merged,
+		TimeToFirstReview: summarizeDurations(firstReview),
+		TimeToApproval:    summarizeDurations(approval),
+		TimeToMerge:       summarizeDurations(merge),
+		Size: SizeStats{
+			P50:     round(Percentile(sizes, 50)),
+			P90:     round(Percentile(sizes, 90)),
+			Buckets: buckets,
+		},
+	}
+}
+
+func sizeBucket(lines int) string {
+	for _, bucket := range sizeBuckets {
+		if bucket.Limit == 0 || lines <= bucket.Limit {
+			return bucket.Name
+		}
+	}
+	return sizeBuckets[len(sizeBuckets)-1].Name
+}
@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"avidlogic/github"
+)
+
+// servePulls serves n pull requests of acme/api created an hour apart, newest
+// first, each with one approving review
+func servePulls(fake *fakeGitHub, n int) {
+	for number := n; number >= 1; number-- {
+		pr := github.PullRequest{Number: number, User: github.User{Login: "alice"}, CreatedAt: day.Add(time.Duration(number) * time.Hour)}
+		fake.list("/repos/acme/api/pulls", "", pr)
+		pr.Additions, pr.Deletions = number, 1
+		fake.object(fmt.Sprintf("/repos/acme/api/pulls/%d", number), pr)
+		fake.list(fmt.Sprintf("/repos/acme/api/pulls/%d/reviews", number), "",
+			github.Review{User: github.User{Login: "bob"}, State: "APPROVED", SubmittedAt: pr.CreatedAt.Add(time.Hour)})
+	}
+}
+
+func TestCollectPullRequests(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	servePulls(fake, 5)
+
+	// Pull requests 2 to 4 were created in the range
+	records, err := CollectPullRequests(context.Background(), client, "acme", []string{"api"}, day.Add(2*time.Hour), day.Add(4*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	for i, record := range records {
+		number := 4 - i
+		if record.Number != number || record.Additions != number || record.ApprovedAt == nil || len(record.Reviewers) != 1 {
+			t.Errorf("record %d = %+v, want PR %d with its size and approval", i, record, number)
+		}
+	}
+	if got := fake.count("/repos/acme/api/pulls/5"); got != 0 {
+		t.Errorf("fetched a pull request created after the range")
+	}
+}
+
+func TestCollectPullRequestsIsBounded(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	servePulls(fake, PullRequestLimit+150)
+
+	records, err := CollectPullRequests(context.Background(), client, "acme", []string{"api"}, day, day.Add(1000*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != PullRequestLimit || records[0].Number != PullRequestLimit+150 {
+		t.Errorf("got %d records starting at PR %d, want the %d most recent", len(records), records[0].Number, PullRequestLimit)
+	}
+	// The list stops paging at the limit, the older pull requests cost nothing
+	if got, want := fake.count("/repos/acme/api/pulls"), 4; got != want {
+		t.Errorf("got %d list requests, want %d", got, want)
+	}
+	if got := fake.count("/repos/acme/api/pulls/1"); got != 0 {
+		t.Errorf("fetched a pull request past the limit")
+	}
+}
+
+func TestCollectPullRequestsFailsOnDetailError(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	servePulls(fake, 3)
+	// Pull request 6 is listed, but fetching it answers 404
+	fake.list("/repos/acme/api/pulls", "", github.PullRequest{Number: 6, CreatedAt: day.Add(time.Minute)})
+
+	if _, err := CollectPullRequests(context.Background(), client, "acme", []string{"api"}, day, day.Add(24*time.Hour)); err == nil {
+		t.Fatal("got no error, want the 404 of the missing pull request")
+	}
+}
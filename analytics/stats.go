@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DurationStats summarizes a set of durations in hours
+type DurationStats struct {
+	Count    int     `json:"count"`
+	P50Hours float64 `json:"p50_hours"`
+	P90Hours float64 `json:"p90_hours"`
+}
+
+// Percentile returns the p-th percentile (0-100) of values using linear
+// interpolation between closest ranks. values does not need to be sorted.
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// summarizeDurations builds DurationStats from a list of durations
+func summarizeDurations(durations []time.Duration) DurationStats {
+	hours := make([]float64, len(durations))
+	for i, d := range durations {
+		hours[i] = d.Hours()
+	}
+
+	return DurationStats{
+		Count:    len(hours),
+		P50Hours: round(Percentile(hours, 50)),
+		P90Hours: round(Percentile(hours, 90)),
+	}
+}
+
+// round keeps two decimals so JSON output stays readable
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
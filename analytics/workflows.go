@@ -0,0 +1,432 @@
+package analytics
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"avidlogic/github"
+	"avidlogic/models"
+)
+
+// flakiestJobsLimit is how many jobs are listed in WorkflowStats.FlakiestJobs
+const flakiestJobsLimit = 5
+
+// WorkflowStats summarizes the completed runs of one workflow
+type WorkflowStats struct {
+	Repo                  string         `json:"repo"`
+	WorkflowID            int64          `json:"workflow_id"`
+	Workflow              string         `json:"workflow"`
+	Runs                  int            `json:"runs"`
+	Succeeded             int            `json:"succeeded"`
+	Failed                int            `json:"failed"`
+	PassRate              float64        `json:"pass_rate"` // succeeded / (succeeded + failed)
+	MedianDurationMinutes float64        `json:"median_duration_minutes"`
+	P90DurationMinutes    float64        `json:"p90_duration_minutes"`
+	MedianQueueSeconds    float64        `json:"median_queue_seconds"`
+	FlakiestJobs          []JobFlakiness `json:"flakiest_jobs"`
+}
+
+// JobFlakiness counts how often a job failed and how often it flaked
+type JobFlakiness struct {
+	Job          string  `json:"job"`
+	Runs         int     `json:"runs"`
+	Failures     int     `json:"failures"`
+	FlakyCommits int     `json:"flaky_commits"`
+	FailureRate  float64 `json:"failure_rate"`
+}
+
+// FlakyJob is a job that failed and then passed on the same commit
+type FlakyJob struct {
+	Repo           string    `json:"repo"`
+	WorkflowID     int64     `json:"workflow_id"`
+	Workflow       string    `json:"workflow"`
+	Job            string    `json:"job"`
+	HeadSHA        string    `json:"head_sha"`
+	Failures       int       `json:"failures"`
+	FirstFailureAt time.Time `json:"first_failure_at"`
+	PassedAt       time.Time `json:"passed_at"`
+}
+
+// WorkflowTrendPoint summarizes a workflow's runs created in one period
+type WorkflowTrendPoint struct {
+	PeriodStart           time.Time `json:"period_start"`
+	Runs                  int       `json:"runs"`
+	PassRate              float64   `json:"pass_rate"`
+	MedianDurationMinutes float64   `json:"median_duration_minutes"`
+	MedianQueueSeconds    float64   `json:"median_queue_seconds"`
+}
+
+// syncRunLimit caps the runs CollectWorkflowRuns fetches per repository, as
+// every run costs another request for its jobs
+const syncRunLimit = 200
+
+// WorkflowBatch is what CollectWorkflowRuns fetched
+type WorkflowBatch struct {
+	Runs []models.WorkflowRun
+	Jobs []models.WorkflowJob
+	// Truncated lists the repositories with more than syncRunLimit runs in
+	// the range; only their most recent runs were fetched
+	Truncated []string
+}
+
+// CollectWorkflowRuns fetches the workflow runs created between from and to in
+// every repository, at most syncRunLimit per repository, together with the
+// jobs of every run attempt.
+func CollectWorkflowRuns(ctx context.Context, client *github.Client, projectID int, owner string, repos []string, from, to time.Time) (WorkflowBatch, error) {
+	var batch WorkflowBatch
+	for _, repo := range repos {
+		// One run more than the limit tells whether the range was truncated
+		ghRuns, err := client.ListWorkflowRuns(ctx, owner, repo, from, to, syncRunLimit+1)
+		if err != nil {
+			return WorkflowBatch{}, err
+		}
+		if len(ghRuns) > syncRunLimit {
+			ghRuns = ghRuns[:syncRunLimit]
+			batch.Truncated = append(batch.Truncated, repo)
+		}
+
+		for _, run := range ghRuns {
+			batch.Runs = append(batch.Runs, models.WorkflowRun{
+				ID:           run.ID,
+				ProjectID:    projectID,
+				RepoName:     repo,
+				WorkflowID:   run.WorkflowID,
+				WorkflowName: run.Name,
+				HeadSHA:      run.HeadSHA,
+				HeadBranch:   run.HeadBranch,
+				Event:        run.Event,
+				Status:       run.Status,
+				Conclusion:   run.Conclusion,
+				RunAttempt:   run.RunAttempt,
+				CreatedAt:    run.CreatedAt,
+				RunStartedAt: run.RunStartedAt,
+				UpdatedAt:    run.UpdatedAt,
+			})
+		}
+
+		runJobs := make([][]github.WorkflowJob, len(ghRuns))
+		err = ForEach(ctx, len(ghRuns), func(ctx context.Context, i int) error {
+			jobs, err := client.ListWorkflowJobs(ctx, owner, repo, ghRuns[i].ID)
+			runJobs[i] = jobs
+			return err
+		})
+		if err != nil {
+			return WorkflowBatch{}, err
+		}
+		for _, ghJobs := range runJobs {
+			for _, job := range ghJobs {
+				batch.Jobs = append(batch.Jobs, models.WorkflowJob{
+					ID:          job.ID,
+					ProjectID:   projectID,
+					RunID:       job.RunID,
+					Name:        job.Name,
+					HeadSHA:     job.HeadSHA,
+					RunAttempt:  job.RunAttempt,
+					Status:      job.Status,
+					Conclusion:  job.Conclusion,
+					CreatedAt:   job.CreatedAt,
+					StartedAt:   job.StartedAt,
+					CompletedAt: job.CompletedAt,
+				})
+			}
+		}
+	}
+
+	return batch, nil
+}
+
+func succeeded(conclusion string) bool { return conclusion == "success" }
+
+func failed(conclusion string) bool {
+	return conclusion == "failure" || conclusion == "timed_out"
+}
+
+// runDuration is the wall-clock time of a completed run's latest attempt
+func runDuration(run models.WorkflowRun) (time.Duration, bool) {
+	if run.Status != "completed" || run.RunStartedAt == nil {
+		return 0, false
+	}
+	return run.UpdatedAt.Sub(*run.RunStartedAt), true
+}
+
+// jobQueueTime is how long a job waited for a runner
+func jobQueueTime(job models.WorkflowJob) (time.Duration, bool) {
+	if job.StartedAt == nil {
+		return 0, false
+	}
+	return job.StartedAt.Sub(job.CreatedAt), true
+}
+
+type workflowKey struct {
+	Repo       string
+	WorkflowID int64
+}
+
+// runsByID indexes runs so jobs can be attributed to their workflow
+func runsByID(runs []models.WorkflowRun) map[int64]models.WorkflowRun {
+	index := make(map[int64]models.WorkflowRun, len(runs))
+	for _, run := range runs {
+		index[run.ID] = run
+	}
+	return index
+}
+
+// WorkflowSummaries computes pass rates, durations, queue times and the
+// flakiest jobs of every workflow, sorted by repository and workflow name.
+func WorkflowSummaries(runs []models.WorkflowRun, jobs []models.WorkflowJob) []WorkflowStats {
+	stats := map[workflowKey]*WorkflowStats{}
+	durations := map[workflowKey][]float64{}
+	queues := map[workflowKey][]float64{}
+
+	for _, run := range runs {
+		key := workflowKey{run.RepoName, run.WorkflowID}
+		if stats[key] == nil {
+			stats[key] = &WorkflowStats{Repo: run.RepoName, WorkflowID: run.WorkflowID, Workflow: run.WorkflowName, FlakiestJobs: []JobFlakiness{}}
+		}
+		if run.Status != "completed" {
+			continue
+		}
+
+		entry := stats[key]
+		entry.Runs++
+		if succeeded(run.Conclusion) {
+			entry.Succeeded++
+		} else if failed(run.Conclusion) {
+			entry.Failed++
+		}
+		if d, ok := runDuration(run); ok {
+			durations[key] = append(durations[key], d.Minutes())
+		}
+	}
+
+	index := runsByID(runs)
+	jobsByWorkflow := map[workflowKey][]models.WorkflowJob{}
+	for _, job := range jobs {
+		run, ok := index[job.RunID]
+		if !ok {
+			continue
+		}
+		key := workflowKey{run.RepoName, run.WorkflowID}
+		jobsByWorkflow[key] = append(jobsByWorkflow[key], job)
+		if q, ok := jobQueueTime(job); ok {
+			queues[key] = append(queues[key], q.Seconds())
+		}
+	}
+
+	result := make([]WorkflowStats, 0, len(stats))
+	for key, entry := range stats {
+		if entry.Succeeded+entry.Failed > 0 {
+			entry.PassRate = round(float64(entry.Succeeded) / float64(entry.Succeeded+entry.Failed))
+		}
+		entry.MedianDurationMinutes = round(Percentile(durations[key], 50))
+		entry.P90DurationMinutes = round(Percentile(durations[key], 90))
+		entry.MedianQueueSeconds = round(Percentile(queues[key], 50))
+		entry.FlakiestJobs = flakiestJobs(jobsByWorkflow[key])
+		result = append(result, *entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Repo != result[j].Repo {
+			return result[i].Repo < result[j].Repo
+		}
+		return result[i].Workflow < result[j].Workflow
+	})
+	return result
+}
+
+// flakiestJobs ranks the jobs of one workflow by flaky commits, then failure rate
+func flakiestJobs(jobs []models.WorkflowJob) []JobFlakiness {
+	byName := map[string]*JobFlakiness{}
+	for _, job := range jobs {
+		if job.Status != "completed" {
+			continue
+		}
+		if byName[job.Name] == nil {
+			byName[job.Name] = &JobFlakiness{Job: job.Name}
+		}
+		byName[job.Name].Runs++
+		if failed(job.Conclusion) {
+			byName[job.Name].Failures++
+		}
+	}
+
+	for _, flake := range detectFlakes(jobs) {
+		byName[flake.name].FlakyCommits++
+	}
+
+	ranked := []JobFlakiness{}
+	for _, entry := range byName {
+		if entry.Failures == 0 {
+			continue
+		}
+		entry.FailureRate = round(float64(entry.Failures) / float64(entry.Runs))
+		ranked = append(ranked, *entry)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].FlakyCommits != ranked[j].FlakyCommits {
+			return ranked[i].FlakyCommits > ranked[j].FlakyCommits
+		}
+		if ranked[i].FailureRate != ranked[j].FailureRate {
+			return ranked[i].FailureRate > ranked[j].FailureRate
+		}
+		return ranked[i].Job < ranked[j].Job
+	})
+
+	if len(ranked) > flakiestJobsLimit {
+		ranked = ranked[:flakiestJobsLimit]
+	}
+	return ranked
+}
+
+type flake struct {
+	name           string
+	headSHA        string
+	failures       int
+	firstFailureAt time.Time
+	passedAt       time.Time
+}
+
+// detectFlakes finds jobs (by name) that failed and later passed on the same commit.
+// All jobs are expected to belong to the same workflow.
+func detectFlakes(jobs []models.WorkflowJob) []flake {
+	type attemptKey struct{ name, sha string }
+
+	attempts := map[attemptKey][]models.WorkflowJob{}
+	for _, job := range jobs {
+		if job.Status != "completed" || job.CompletedAt == nil {
+			continue
+		}
+		key := attemptKey{job.Name, job.HeadSHA}
+		attempts[key] = append(attempts[key], job)
+	}
+
+	var flakes []flake
+	for key, group := range attempts {
+		sort.Slice(group, func(i, j int) bool { return group[i].CompletedAt.Before(*group[j].CompletedAt) })
+
+		current := flake{name: key.name, headSHA: key.sha}
+		for _, job := range group {
+			if failed(job.Conclusion) {
+				if current.failures == 0 {
+					current.firstFailureAt = *job.CompletedAt
+				}
+				current.failures++
+			} else if succeeded(job.Conclusion) && current.failures > 0 {
+				current.passedAt = *job.CompletedAt
+				flakes = append(flakes, current)
+				break
+			}
+		}
+	}
+
+	return flakes
+}
+
+// FlakyJobs lists every job that failed and then passed on the same commit,
+// most recent first.
+func FlakyJobs(runs []models.WorkflowRun, jobs []models.WorkflowJob) []FlakyJob {
+	index := runsByID(runs)
+	jobsByWorkflow := map[workflowKey][]models.WorkflowJob{}
+	names := map[workflowKey]string{}
+	for _, job := range jobs {
+		run, ok := index[job.RunID]
+		if !ok {
+			continue
+		}
+		key := workflowKey{run.RepoName, run.WorkflowID}
+		jobsByWorkflow[key] = append(jobsByWorkflow[key], job)
+		names[key] = run.WorkflowName
+	}
+
+	result := []FlakyJob{}
+	for key, workflowJobs := range jobsByWorkflow {
+		for _, f := range detectFlakes(workflowJobs) {
+			result = append(result, FlakyJob{
+				Repo:           key.Repo,
+				WorkflowID:     key.WorkflowID,
+				Workflow:       names[key],
+				Job:            f.name,
+				HeadSHA:        f.headSHA,
+				Failures:       f.failures,
+				FirstFailureAt: f.firstFailureAt,
+				PassedAt:       f.passedAt,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].PassedAt.After(result[j].PassedAt) })
+	return result
+}
+
+// WorkflowTrend buckets the runs of one workflow by day or week (interval "day"
+// or "week", weeks starting on Monday) and summarizes each bucket.
+func WorkflowTrend(runs []models.WorkflowRun, jobs []models.WorkflowJob, workflowID int64, interval string) []WorkflowTrendPoint {
+	periodOf := func(t time.Time) time.Time {
+		if interval == "week" {
+			return weekStart(t)
+		}
+		t = t.UTC()
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	type bucket struct {
+		runs, succeeded, failed int
+		durations, queues       []float64
+	}
+	buckets := map[time.Time]*bucket{}
+	get := func(period time.Time) *bucket {
+		if buckets[period] == nil {
+			buckets[period] = &bucket{}
+		}
+		return buckets[period]
+	}
+
+	index := map[int64]time.Time{}
+	for _, run := range runs {
+		if run.WorkflowID != workflowID || run.Status != "completed" {
+			continue
+		}
+		period := periodOf(run.CreatedAt)
+		index[run.ID] = period
+
+		b := get(period)
+		b.runs++
+		if succeeded(run.Conclusion) {
+			b.succeeded++
+		} else if failed(run.Conclusion) {
+			b.failed++
+		}
+		if d, ok := runDuration(run); ok {
+			b.durations = append(b.durations, d.Minutes())
+		}
+	}
+
+	for _, job := range jobs {
+		period, ok := index[job.RunID]
+		if !ok {
+			continue
+		}
+		if q, ok := jobQueueTime(job); ok {
+			b := get(period)
+			b.queues = append(b.queues, q.Seconds())
+		}
+	}
+
+	points := make([]WorkflowTrendPoint, 0, len(buckets))
+	for period, b := range buckets {
+		point := WorkflowTrendPoint{
+			PeriodStart:           period,
+			Runs:                  b.runs,
+			MedianDurationMinutes: round(Percentile(b.durations, 50)),
+			MedianQueueSeconds:    round(Percentile(b.queues, 50)),
+		}
+		if b.succeeded+b.failed > 0 {
+			point.PassRate = round(float64(b.succeeded) / float64(b.succeeded+b.failed))
+		}
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].PeriodStart.Before(points[j].PeriodStart) })
+
+	return points
+}
@@ -0,0 +1,150 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"avidlogic/github"
+)
+
+var day = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// at returns a time minutes after day
+func at(minutes int) *time.Time {
+	t := day.Add(time.Duration(minutes) * time.Minute)
+	return &t
+}
+
+// fakeRun is a run attempt of the "CI" workflow and the conclusions of its
+// "test" job, one per attempt
+type fakeRun struct {
+	id       int64
+	sha      string
+	attempts []string
+}
+
+// serveRuns serves runs of acme/api and the jobs of each attempt, every
+// attempt completing ten minutes after the previous one
+func serveRuns(fake *fakeGitHub, runs []fakeRun) {
+	minutes := 0
+	for _, run := range runs {
+		var jobs []any
+		for i, conclusion := range run.attempts {
+			minutes += 10
+			jobs = append(jobs, github.WorkflowJob{
+				ID: run.id*10 + int64(i), RunID: run.id, Name: "test", HeadSHA: run.sha, RunAttempt: i + 1,
+				Status: "completed", Conclusion: conclusion, CreatedAt: *at(minutes - 5), StartedAt: at(minutes - 5), CompletedAt: at(minutes),
+			})
+		}
+		last := run.attempts[len(run.attempts)-1]
+		fake.list("/repos/acme/api/actions/runs", "workflow_runs", github.WorkflowRun{
+			ID: run.id, Name: "CI", WorkflowID: 7, HeadSHA: run.sha, Status: "completed", Conclusion: last,
+			RunAttempt: len(run.attempts), CreatedAt: day, UpdatedAt: *at(minutes),
+		})
+		fake.list(fmt.Sprintf("/repos/acme/api/actions/runs/%d/jobs", run.id), "jobs", jobs...)
+	}
+}
+
+func TestFlakyJobs(t *testing.T) {
+	tests := []struct {
+		name         string
+		runs         []fakeRun
+		wantSHA      string
+		wantFailures int
+	}{
+		{"fail then pass on the same sha", []fakeRun{
+			{1, "aaa", []string{"failure"}},
+			{2, "aaa", []string{"success"}},
+		}, "aaa", 1},
+		{"fail then pass on different shas", []fakeRun{
+			{1, "aaa", []string{"failure"}},
+			{2, "bbb", []string{"success"}},
+		}, "", 0},
+		{"pass then fail on the same sha", []fakeRun{
+			{1, "aaa", []string{"success"}},
+			{2, "aaa", []string{"failure"}},
+		}, "", 0},
+		{"re-run attempt passes", []fakeRun{
+			{1, "aaa", []string{"failure", "success"}},
+		}, "aaa", 1},
+		{"several failed attempts before passing", []fakeRun{
+			{1, "aaa", []string{"failure", "timed_out", "success"}},
+		}, "aaa", 2},
+		{"cancelled attempt is not a failure", []fakeRun{
+			{1, "aaa", []string{"cancelled", "success"}},
+		}, "", 0},
+		{"re-runs that keep failing", []fakeRun{
+			{1, "aaa", []string{"failure", "failure"}},
+		}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeGitHub(t)
+			serveRuns(fake, tt.runs)
+
+			batch, err := CollectWorkflowRuns(context.Background(), client, 1, "acme", []string{"api"}, day.Add(-time.Hour), day.Add(24*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			flaky := FlakyJobs(batch.Runs, batch.Jobs)
+
+			if tt.wantSHA == "" {
+				if len(flaky) != 0 {
+					t.Errorf("got flaky jobs %+v, want none", flaky)
+				}
+				return
+			}
+			if len(flaky) != 1 {
+				t.Fatalf("got %d flaky jobs %+v, want 1", len(flaky), flaky)
+			}
+			got := flaky[0]
+			if got.Repo != "api" || got.Workflow != "CI" || got.Job != "test" || got.HeadSHA != tt.wantSHA || got.Failures != tt.wantFailures {
+				t.Errorf("got %+v, want test on %s with %d failures", got, tt.wantSHA, tt.wantFailures)
+			}
+			if !got.FirstFailureAt.Before(got.PassedAt) {
+				t.Errorf("first failure %s is not before the pass %s", got.FirstFailureAt, got.PassedAt)
+			}
+		})
+	}
+}
+
+func TestCollectWorkflowRunsIsBounded(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	runs := make([]fakeRun, syncRunLimit+50)
+	for i := range runs {
+		runs[i] = fakeRun{int64(i + 1), fmt.Sprintf("sha%d", i), []string{"success"}}
+	}
+	serveRuns(fake, runs)
+
+	batch, err := CollectWorkflowRuns(context.Background(), client, 1, "acme", []string{"api"}, day.Add(-time.Hour), day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Runs) != syncRunLimit || len(batch.Jobs) != syncRunLimit {
+		t.Errorf("got %d runs and %d jobs, want %d of each", len(batch.Runs), len(batch.Jobs), syncRunLimit)
+	}
+	if len(batch.Truncated) != 1 || batch.Truncated[0] != "api" {
+		t.Errorf("got truncated repos %v, want [api]", batch.Truncated)
+	}
+	// Paging stops once the limit is reached, and the runs past it cost no
+	// request for their jobs
+	if got, want := fake.count("/repos/acme/api/actions/runs"), 3; got != want {
+		t.Errorf("got %d requests for runs, want %d", got, want)
+	}
+	if got := fake.count(fmt.Sprintf("/repos/acme/api/actions/runs/%d/jobs", syncRunLimit+1)); got != 0 {
+		t.Errorf("fetched the jobs of a run past the limit")
+	}
+}
+
+func TestCollectWorkflowRunsFailsOnJobError(t *testing.T) {
+	fake, client := newFakeGitHub(t)
+	serveRuns(fake, []fakeRun{{1, "aaa", []string{"success"}}})
+	// The jobs of run 2 are not served, so listing them answers 404
+	fake.list("/repos/acme/api/actions/runs", "workflow_runs", github.WorkflowRun{ID: 2, Name: "CI", WorkflowID: 7, CreatedAt: day})
+
+	if _, err := CollectWorkflowRuns(context.Background(), client, 1, "acme", []string{"api"}, day.Add(-time.Hour), day.Add(24*time.Hour)); err == nil {
+		t.Fatal("got no error, want the 404 of the missing jobs")
+	}
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"avidlogic/models"
+	"avidlogic/store"
+)
+
+// fixedLog walks a given list of events, as a tampered table would return them
+type fixedLog struct {
+	store.AuditStore
+	events []models.AuditEvent
+}
+
+func (l fixedLog) WalkAuditEvents(ctx context.Context, fn func(models.AuditEvent) error) error {
+	for _, event := range l.events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordedEvents records n events and returns the log
+func recordedEvents(t *testing.T, n int) []models.AuditEvent {
+	t.Helper()
+	db := store.NewMemoryStore()
+	r := NewRecorder(db)
+	for i := range n {
+		r.Record(context.Background(), models.AuditEvent{Action: ActionLogin, Actor: fmt.Sprintf("user%d", i), Outcome: models.AuditSuccess})
+	}
+	var events []models.AuditEvent
+	if err := db.WalkAuditEvents(context.Background(), func(e models.AuditEvent) error {
+		events = append(events, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != n {
+		t.Fatalf("recorded %d events, want %d", len(events), n)
+	}
+	return events
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(events []models.AuditEvent) []models.AuditEvent
+		want     VerifyResult
+		wantText string
+	}{
+		{"intact", func(events []models.AuditEvent) []models.AuditEvent { return events },
+			VerifyResult{Valid: true, Checked: 4}, ""},
+		{"empty", func(events []models.AuditEvent) []models.AuditEvent { return nil },
+			VerifyResult{Valid: true}, ""},
+		{"edited", func(events []models.AuditEvent) []models.AuditEvent {
+			events[2].Outcome = models.AuditFailure
+			return events
+		}, VerifyResult{Checked: 2, BrokenAt: 3}, "event 3 was modified"},
+		{"edited with its hash", func(events []models.AuditEvent) []models.AuditEvent {
+			events[1].Actor = "mallory"
+			events[1].Hash = events[1].ChainHash()
+			return events
+		}, VerifyResult{Checked: 2, BrokenAt: 3}, "event 3 does not follow the previous event"},
+		{"deleted", func(events []models.AuditEvent) []models.AuditEvent {
+			return slices.Delete(events, 1, 2)
+		}, VerifyResult{Checked: 1, BrokenAt: 3}, "event 3 does not follow the previous event"},
+		{"first deleted", func(events []models.AuditEvent) []models.AuditEvent {
+			return events[1:]
+		}, VerifyResult{BrokenAt: 2}, "event 2 does not follow the previous event"},
+		{"reordered", func(events []models.AuditEvent) []models.AuditEvent {
+			events[1], events[2] = events[2], events[1]
+			return events
+		}, VerifyResult{Checked: 1, BrokenAt: 3}, "event 3 does not follow the previous event"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRecorder(fixedLog{events: tt.tamper(recordedEvents(t, 4))})
+			got, err := r.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Reason = tt.wantText
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// cancellableLog fails appends on a done context, as the database would
+type cancellableLog struct {
+	*store.MemoryStore
+}
+
+func (l cancellableLog) AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return l.MemoryStore.AppendAuditEvent(ctx, event)
+}
+
+func TestRecordAfterCancel(t *testing.T) {
+	db := store.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A client hanging up does not keep its request out of the log
+	NewRecorder(cancellableLog{db}).Record(ctx, models.AuditEvent{Action: ActionLogin, Outcome: models.AuditFailure})
+	if _, total, err := db.ListAuditEvents(context.Background(), store.AuditFilter{Limit: 10}); err != nil || total != 1 {
+		t.Errorf("got %d events (%v), want 1", total, err)
+	}
+}
@@ -1,33 +1,45 @@
 package auth
 
 import (
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// JWT secret key (can be stored in .env file)
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
 // Claims struct for JWT token
 type Claims struct {
 	UserID string `json:"user_id"`
+	// Version is the token version of the account when the token was issued
+	Version int `json:"ver,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a new JWT token
-func GenerateJWT(userID string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token valid for 24 hours
+// JWT issues and validates the API tokens
+type JWT struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewJWT returns a JWT signing with the given secret key and issuing tokens
+// valid for ttl
+func NewJWT(secret string, ttl time.Duration) *JWT {
+	return &JWT{secret: []byte(secret), ttl: ttl}
+}
+
+// Generate generates a new JWT token for the given token version of the account
+func (j *JWT) Generate(userID string, version int) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:  userID,
+		Version: version,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.ttl)),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := token.SignedString(j.secret)
 	if err != nil {
 		return "", err
 	}
@@ -35,12 +47,15 @@ func GenerateJWT(userID string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateJWT validates the provided JWT token
-func ValidateJWT(tokenString string) (*Claims, error) {
+// Validate validates the provided JWT token
+func (j *JWT) Validate(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return j.secret, nil
 	})
 
 	if err != nil {
@@ -0,0 +1,416 @@
+// Package config loads the server configuration. Every setting is named by an
+// environment variable such as JWT_SECRET and can be given, in increasing
+// order of precedence, in a .env style file, in the environment or as a
+// command line flag named after the variable (-jwt-secret).
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultFile is read, if it exists, when no config file is named
+const DefaultFile = ".env"
+
+// defaultRetentionDays matches retention.DefaultWindow
+const defaultRetentionDays = 30
+
+// minJWTSecretLength is the shortest accepted token signing key, in bytes
+const minJWTSecretLength = 32
+
+// Config is the validated server configuration
+type Config struct {
+	Port            int
+	TrustedProxies  []string // proxies whose X-Forwarded-For is trusted for client IPs
+	HTTP            HTTP
+	Database        Database
+	JWTSecret       string
+	JWTTTL          time.Duration
+	AutoMigrate     bool
+	RetentionWindow time.Duration
+	BreachedList    string // breached password list file; empty disables the check
+	RateLimitStore  string // none, memory or postgres
+	GitHubAPIURL    string // REST API root of github.com projects; empty uses the public API
+	// AllowedNetworks are internal networks that webhooks and self-hosted
+	// forges may be reached on; other non-public addresses are refused
+	AllowedNetworks []netip.Prefix
+	SMTP            SMTP
+	Tracing         Tracing
+	Logging         Logging
+}
+
+// HTTP configures the server timeouts
+type HTTP struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownDelay     time.Duration // how long to keep serving, while failing readiness, before draining
+	ShutdownTimeout   time.Duration // how long in-flight requests and background jobs get to finish
+}
+
+// Database configures the connection pool. Zero values keep the pool_*
+// parameters of the URL or the pgx defaults.
+type Database struct {
+	URL               string
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	ConnectTimeout    time.Duration
+	TraceQueries      bool // record a span per query
+}
+
+// SMTP configures the mail relay. Mail is only logged when Host is empty.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Rate limit stores
+const (
+	RateLimitNone     = "none"
+	RateLimitMemory   = "memory"
+	RateLimitPostgres = "postgres"
+)
+
+// Log formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Logging configures the structured logs
+type Logging struct {
+	Level  slog.Level
+	Format string // json or text
+}
+
+// Trace exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Tracing configures OpenTelemetry tracing
+type Tracing struct {
+	Exporter     string  // none, otlp or stdout
+	OTLPEndpoint string  // base URL of the OTLP/HTTP collector; empty uses the exporter default
+	ServiceName  string  // service.name of the spans
+	SampleRatio  float64 // share of new traces recorded, from 0 to 1
+}
+
+// Addr returns the address the HTTP server listens on
+func (c *Config) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// settings lists every variable with its flag description
+var settings = []struct{ name, usage string }{
+	{"PORT", "HTTP port (default 8080)"},
+	{"TRUSTED_PROXIES", "comma-separated proxy IPs or CIDRs allowed to set X-Forwarded-For (default none)"},
+	{"HTTP_READ_HEADER_TIMEOUT", "time allowed to read request headers (default 10s)"},
+	{"HTTP_READ_TIMEOUT", "time allowed to read a whole request (default 30s)"},
+	{"HTTP_WRITE_TIMEOUT", "time allowed to write a response (default 2m)"},
+	{"HTTP_IDLE_TIMEOUT", "keep-alive connection idle timeout (default 2m)"},
+	{"SHUTDOWN_DELAY", "time to keep serving with failing readiness before draining, so load balancers stop routing (default 0s)"},
+	{"SHUTDOWN_TIMEOUT", "time given to in-flight requests and background jobs on shutdown (default 30s)"},
+	{"DATABASE_URL", "PostgreSQL connection string (required)"},
+	{"DB_MAX_CONNS", "maximum pool size"},
+	{"DB_MIN_CONNS", "minimum pool size"},
+	{"DB_MAX_CONN_LIFETIME", "maximum connection lifetime, e.g. 1h"},
+	{"DB_MAX_CONN_IDLE_TIME", "maximum connection idle time, e.g. 30m"},
+	{"DB_HEALTH_CHECK_PERIOD", "interval between idle connection health checks"},
+	{"DB_CONNECT_TIMEOUT", "timeout for opening a connection"},
+	{"JWT_SECRET", "token signing key, at least 32 bytes (required)"},
+	{"JWT_TTL", "token lifetime (default 24h)"},
+	{"AUTO_MIGRATE", "apply pending migrations at startup"},
+	{"DATA_RETENTION_DAYS", "days deleted accounts and projects are kept (default 30)"},
+	{"BREACHED_PASSWORDS_FILE", "breached password list in the Pwned Passwords format (sorted SHA-1 hashes), checked on signup and password changes"},
+	{"RATE_LIMIT_STORE", "where rate limit buckets live: memory (per instance), postgres (shared by every instance) or none to disable (default memory)"},
+	{"GITHUB_API_URL", "REST API root used for github.com projects, e.g. a local fake server (default https://api.github.com)"},
+	{"OUTBOUND_ALLOWED_NETWORKS", "comma-separated internal networks (CIDRs or IPs) that webhooks and self-hosted forges may be reached on, e.g. a GitHub Enterprise Server on 10.0.0.0/8 (default none)"},
+	{"LOG_LEVEL", "minimum log level: debug, info, warn or error (default info)"},
+	{"LOG_FORMAT", "log format: json or text (default json)"},
+	{"OTEL_TRACES_EXPORTER", "trace exporter: none, otlp or stdout (default none)"},
+	{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTLP/HTTP collector URL, e.g. http://localhost:4318; headers go in OTEL_EXPORTER_OTLP_HEADERS"},
+	{"OTEL_SERVICE_NAME", "service name of the traces (default avidlogic)"},
+	{"OTEL_TRACES_SAMPLER_ARG", "share of new traces to record, from 0 to 1 (default 1)"},
+	{"SMTP_HOST", "mail relay host; emails are only logged when unset"},
+	{"SMTP_PORT", "mail relay port (default 587)"},
+	{"SMTP_USERNAME", "mail relay username"},
+	{"SMTP_PASSWORD", "mail relay password"},
+	{"SMTP_FROM", "sender address of emails"},
+}
+
+// flagName returns the flag setting the named variable
+func flagName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
+
+// Load reads the configuration from the config file, the environment and the
+// flags in args, and validates it. The file is named by -config or
+// CONFIG_FILE and defaults to an optional .env. Load returns the arguments
+// left after the flags, such as a subcommand.
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("avidlogic", flag.ContinueOnError)
+	file := flags.String("config", "", "config file of NAME=value lines (CONFIG_FILE, default "+DefaultFile+" if present)")
+	flagged := make(map[string]string)
+	for _, s := range settings {
+		name := s.name
+		flags.Func(flagName(name), s.usage+" ("+name+")", func(value string) error {
+			flagged[name] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	path, explicit := DefaultFile, false
+	if env := os.Getenv("CONFIG_FILE"); env != "" {
+		path, explicit = env, true
+	}
+	if *file != "" {
+		path, explicit = *file, true
+	}
+
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		values = make(map[string]string)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("reading config file: %w", err)
+	}
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.name); ok {
+			values[s.name] = value
+		}
+	}
+	for name, value := range flagged {
+		values[name] = value
+	}
+
+	cfg, err := parse(values)
+	return cfg, flags.Args(), err
+}
+
+// parse converts and validates the raw values, reporting every invalid one
+func parse(values map[string]string) (*Config, error) {
+	p := &parser{values: values}
+	cfg := &Config{
+		Port:           p.int("PORT", 8080, 1, 65535),
+		TrustedProxies: p.list("TRUSTED_PROXIES"),
+		HTTP: HTTP{
+			ReadHeaderTimeout: p.duration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+			ReadTimeout:       p.duration("HTTP_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:      p.duration("HTTP_WRITE_TIMEOUT", 2*time.Minute),
+			IdleTimeout:       p.duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+			ShutdownDelay:     p.duration("SHUTDOWN_DELAY", 0),
+			ShutdownTimeout:   p.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		},
+		Database: Database{
+			URL:               p.required("DATABASE_URL"),
+			MaxConns:          int32(p.int("DB_MAX_CONNS", 0, 0, 1<<31-1)),
+			MinConns:          int32(p.int("DB_MIN_CONNS", 0, 0, 1<<31-1)),
+			MaxConnLifetime:   p.duration("DB_MAX_CONN_LIFETIME", 0),
+			MaxConnIdleTime:   p.duration("DB_MAX_CONN_IDLE_TIME", 0),
+			HealthCheckPeriod: p.duration("DB_HEALTH_CHECK_PERIOD", 0),
+			ConnectTimeout:    p.duration("DB_CONNECT_TIMEOUT", 0),
+		},
+		JWTSecret:       p.required("JWT_SECRET"),
+		JWTTTL:          p.duration("JWT_TTL", 24*time.Hour),
+		AutoMigrate:     p.bool("AUTO_MIGRATE"),
+		RetentionWindow: time.Duration(p.int("DATA_RETENTION_DAYS", defaultRetentionDays, 0, 36500)) * 24 * time.Hour,
+		BreachedList:    p.string("BREACHED_PASSWORDS_FILE", ""),
+		RateLimitStore:  p.oneOf("RATE_LIMIT_STORE", RateLimitMemory, RateLimitNone, RateLimitMemory, RateLimitPostgres),
+		GitHubAPIURL:    p.url("GITHUB_API_URL"),
+		AllowedNetworks: p.prefixes("OUTBOUND_ALLOWED_NETWORKS"),
+		Logging: Logging{
+			Level:  p.level("LOG_LEVEL"),
+			Format: p.oneOf("LOG_FORMAT", LogFormatJSON, LogFormatJSON, LogFormatText),
+		},
+		Tracing: Tracing{
+			Exporter:     p.oneOf("OTEL_TRACES_EXPORTER", ExporterNone, ExporterNone, ExporterOTLP, ExporterStdout),
+			OTLPEndpoint: p.url("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			ServiceName:  p.string("OTEL_SERVICE_NAME", "avidlogic"),
+			SampleRatio:  p.float("OTEL_TRACES_SAMPLER_ARG", 1, 0, 1),
+		},
+		SMTP: SMTP{
+			Host:     p.values["SMTP_HOST"],
+			Port:     p.int("SMTP_PORT", 587, 1, 65535),
+			Username: p.values["SMTP_USERNAME"],
+			Password: p.values["SMTP_PASSWORD"],
+			From:     p.values["SMTP_FROM"],
+		},
+	}
+
+	cfg.Database.TraceQueries = cfg.Tracing.Exporter != ExporterNone
+
+	if cfg.JWTSecret != "" && len(cfg.JWTSecret) < minJWTSecretLength {
+		p.fail("JWT_SECRET", "must be at least %d bytes long", minJWTSecretLength)
+	}
+	if cfg.JWTTTL == 0 && p.values["JWT_TTL"] != "" {
+		p.fail("JWT_TTL", "must be positive")
+	}
+	if cfg.Database.MaxConns > 0 && cfg.Database.MinConns > cfg.Database.MaxConns {
+		p.fail("DB_MIN_CONNS", "(%d) exceeds DB_MAX_CONNS (%d)", cfg.Database.MinConns, cfg.Database.MaxConns)
+	}
+
+	if len(p.errs) > 0 {
+		return nil, errors.New("invalid configuration:\n  " + strings.Join(p.errs, "\n  "))
+	}
+	return cfg, nil
+}
+
+// parser converts raw values, collecting an error for each invalid one
+type parser struct {
+	values map[string]string
+	errs   []string
+}
+
+func (p *parser) fail(name, format string, args ...any) {
+	p.errs = append(p.errs, name+" "+fmt.Sprintf(format, args...))
+}
+
+func (p *parser) required(name string) string {
+	value := p.values[name]
+	if value == "" {
+		p.fail(name, "is required: set it in the environment, the config file or with -%s", flagName(name))
+	}
+	return value
+}
+
+func (p *parser) string(name, def string) string {
+	if value := p.values[name]; value != "" {
+		return value
+	}
+	return def
+}
+
+func (p *parser) oneOf(name, def string, allowed ...string) string {
+	value := p.string(name, def)
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	p.fail(name, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	return def
+}
+
+func (p *parser) url(name string) string {
+	value := p.values[name]
+	if value == "" {
+		return ""
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p.fail(name, "must be an http or https URL, got %q", value)
+		return ""
+	}
+	return strings.TrimRight(value, "/")
+}
+
+func (p *parser) float(name string, def, min, max float64) float64 {
+	value := p.values[name]
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < min || f > max {
+		p.fail(name, "must be a number from %g to %g, got %q", min, max, value)
+		return def
+	}
+	return f
+}
+
+func (p *parser) level(name string) slog.Level {
+	var level slog.Level
+	if value := p.values[name]; value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			p.fail(name, "must be debug, info, warn or error, got %q", value)
+		}
+	}
+	return level
+}
+
+func (p *parser) int(name string, def, min, max int) int {
+	value := p.values[name]
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		p.fail(name, "must be an integer from %d to %d, got %q", min, max, value)
+		return def
+	}
+	return n
+}
+
+func (p *parser) duration(name string, def time.Duration) time.Duration {
+	value := p.values[name]
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		p.fail(name, "must be a duration such as 30s or 1h, got %q", value)
+		return def
+	}
+	return d
+}
+
+func (p *parser) bool(name string) bool {
+	value := p.values[name]
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(name, "must be true or false, got %q", value)
+	}
+	return b
+}
+
+func (p *parser) prefixes(name string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range p.list(name) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			ip, ipErr := netip.ParseAddr(item)
+			if ipErr != nil {
+				p.fail(name, "must list CIDRs or IP addresses, got %q", item)
+				continue
+			}
+			prefix = netip.PrefixFrom(ip, ip.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+func (p *parser) list(name string) []string {
+	var items []string
+	for _, item := range strings.Split(p.values[name], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret is a valid JWT_SECRET
+const secret = "a signing key that is 32 bytes!!"
+
+// clearEnv unsets every setting for the duration of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range append(settings, struct{ name, usage string }{"CONFIG_FILE", ""}) {
+		t.Setenv(s.name, "")
+		os.Unsetenv(s.name)
+	}
+}
+
+// writeFile writes a config file of the given lines and returns its path
+func writeFile(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "avidlogic.env")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t,
+		"DATABASE_URL=postgres://file/db",
+		"JWT_SECRET="+secret,
+		"PORT=1000",
+		"JWT_TTL=1h",
+		"SMTP_FROM=file@example.com",
+	)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		flags   []string
+		port    int
+		ttl     time.Duration
+		smtp    string
+		dbURL   string
+		remains []string
+	}{
+		{"file", nil, nil, 1000, time.Hour, "file@example.com", "postgres://file/db", []string{}},
+		{"environment over file", map[string]string{"PORT": "2000", "JWT_TTL": "2h"}, nil,
+			2000, 2 * time.Hour, "file@example.com", "postgres://file/db", []string{}},
+		{"flag over environment", map[string]string{"PORT": "2000", "JWT_TTL": "2h"}, []string{"-port", "3000"},
+			3000, 2 * time.Hour, "file@example.com", "postgres://file/db", []string{}},
+		{"flag over file", nil, []string{"-database-url=postgres://flag/db", "migrate", "up"},
+			1000, time.Hour, "file@example.com", "postgres://flag/db", []string{"migrate", "up"}},
+		{"empty environment value over file", map[string]string{"SMTP_FROM": ""}, nil,
+			1000, time.Hour, "", "postgres://file/db", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, rest, err := Load(append([]string{"-config", file}, tt.flags...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.port || cfg.JWTTTL != tt.ttl || cfg.SMTP.From != tt.smtp || cfg.Database.URL != tt.dbURL {
+				t.Errorf("got port %d, TTL %v, SMTP from %q, database %q, want %d, %v, %q, %q",
+					cfg.Port, cfg.JWTTTL, cfg.SMTP.From, cfg.Database.URL, tt.port, tt.ttl, tt.smtp, tt.dbURL)
+			}
+			if strings.Join(rest, " ") != strings.Join(tt.remains, " ") {
+				t.Errorf("got arguments %q, want %q", rest, tt.remains)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	env := writeFile(t, "DATABASE_URL=postgres://env-file/db", "JWT_SECRET="+secret)
+	flagged := writeFile(t, "DATABASE_URL=postgres://flag-file/db", "JWT_SECRET="+secret)
+
+	tests := []struct {
+		name    string
+		env     string
+		flags   []string
+		want    string
+		wantErr string
+	}{
+		{"named in the environment", env, nil, "postgres://env-file/db", ""},
+		{"flag over environment", env, []string{"-config", flagged}, "postgres://flag-file/db", ""},
+		{"missing file", "", []string{"-config", filepath.Join(t.TempDir(), "missing.env")}, "", "reading config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.env != "" {
+				t.Setenv("CONFIG_FILE", tt.env)
+			}
+			cfg, _, err := Load(tt.flags)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Database.URL != tt.want {
+				t.Errorf("got database %q, want %q", cfg.Database.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]string
+		wantErr []string // every one reported, none when empty
+	}{
+		{"valid", nil, nil},
+		{"missing required", map[string]string{"DATABASE_URL": "", "JWT_SECRET": ""},
+			[]string{"DATABASE_URL is required", "JWT_SECRET is required"}},
+		{"short JWT secret", map[string]string{"JWT_SECRET": secret[1:]}, []string{"JWT_SECRET must be at least 32 bytes long"}},
+		{"invalid duration", map[string]string{"HTTP_READ_TIMEOUT": "30"}, []string{`HTTP_READ_TIMEOUT must be a duration such as 30s or 1h, got "30"`}},
+		{"negative duration", map[string]string{"SHUTDOWN_TIMEOUT": "-1s"}, []string{"SHUTDOWN_TIMEOUT must be a duration"}},
+		{"zero token lifetime", map[string]string{"JWT_TTL": "0s"}, []string{"JWT_TTL must be positive"}},
+		{"port zero", map[string]string{"PORT": "0"}, []string{`PORT must be an integer from 1 to 65535, got "0"`}},
+		{"port too large", map[string]string{"SMTP_PORT": "65536"}, []string{"SMTP_PORT must be an integer from 1 to 65535"}},
+		{"port not a number", map[string]string{"PORT": "http"}, []string{"PORT must be an integer"}},
+		{"pool bounds", map[string]string{"DB_MAX_CONNS": "5", "DB_MIN_CONNS": "10"}, []string{"DB_MIN_CONNS (10) exceeds DB_MAX_CONNS (5)"}},
+		{"every error reported", map[string]string{"PORT": "0", "JWT_TTL": "soon", "LOG_FORMAT": "xml"},
+			[]string{"PORT must be", "JWT_TTL must be", "LOG_FORMAT must be one of json, text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]string{"DATABASE_URL": "postgres://localhost/db", "JWT_SECRET": secret}
+			for name, value := range tt.values {
+				values[name] = value
+			}
+			cfg, err := parse(values)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cfg.Port != 8080 || cfg.JWTTTL != 24*time.Hour || cfg.SMTP.Port != 587 {
+					t.Errorf("got port %d, TTL %v, SMTP port %d, want the defaults", cfg.Port, cfg.JWTTTL, cfg.SMTP.Port)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got no error, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("got error %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,544 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"avidlogic/audit"
+	"avidlogic/export"
+	"avidlogic/models"
+	"avidlogic/problem"
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// emailVerificationTTL is how long an email change can be confirmed
+const emailVerificationTTL = 24 * time.Hour
+
+// Page sizes of the admin user list
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// UpdateMeInput holds the profile fields to change. A new email only takes
+// effect once the address is verified.
+type UpdateMeInput struct {
+	Username *string `json:"username" binding:"omitnil,username"`
+	Email    *string `json:"email" binding:"omitnil,email,max=254"`
+}
+
+// ChangePasswordInput defines the fields required to change the password
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,password"` // 10 to 72 bytes, not in a known breach
+}
+
+// PasswordChangedResponse carries the token replacing the revoked ones
+type PasswordChangedResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+// VerifyEmailInput holds the token sent to the new email address
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// DeleteAccountInput confirms the account deletion
+type DeleteAccountInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// UserListResponse is one page of the admin user list
+type UserListResponse struct {
+	Users  []models.User `json:"users"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// currentUser loads the authenticated user, writing the error response on failure
+func (h *UserHandler) currentUser(c *gin.Context) (models.User, bool) {
+	userID, _ := c.Get("userID")
+	id, err := uuid.Parse(userID.(string))
+	if err != nil {
+		problem.Abort(c, problem.InvalidToken.New("User not found"))
+		return models.User{}, false
+	}
+
+	user, err := h.Users.GetUserByID(c.Request.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Abort(c, problem.InvalidToken.New("User not found"))
+		return user, false
+	} else if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to load user"))
+		return user, false
+	}
+	return user, true
+}
+
+// userUpdated reports whether an update of the user succeeded, answering 409
+// with the conflicting field if a unique value is taken, 404 if the user is
+// gone and 500 otherwise
+func (h *UserHandler) userUpdated(c *gin.Context, id uuid.UUID, err error) bool {
+	var conflict *store.ConflictError
+	if errors.As(err, &conflict) {
+		problem.Error(c, conflict)
+		return false
+	} else if errors.Is(err, store.ErrNotFound) {
+		problem.Abort(c, problem.NotFound.New("User not found"))
+		return false
+	} else if err != nil {
+		slog.ErrorContext(c.Request.Context(), "updating user", "target_user_id", id, "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to update user"))
+		return false
+	}
+	return true
+}
+
+// hashToken returns the hex SHA-256 of a verification token, which is what gets stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetMe returns the account of the logged-in user
+// @Summary Get my account
+// @Description Returns the account of the logged-in user, including a pending email change.
+// @Tags Account
+// @Produce json,application/problem+json
+// @Success 200 {object} models.User
+// @Failure 401 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateMe changes the username and/or email of the logged-in user
+// @Summary Update my account
+// @Description Changes the username immediately. A new email is stored as pending and a verification token is sent to it; the change applies once the token is confirmed. If the verification email cannot be sent, nothing is changed.
+// @Tags Account
+// @Accept json
+// @Produce json,application/problem+json
+// @Param account body UpdateMeInput true "Fields to change"
+// @Success 200 {object} models.User
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/me [patch]
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	var input UpdateMeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Abort(c, problem.Binding(err))
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	newUsername := input.Username != nil && *input.Username != user.Username
+	var email, token string
+	if input.Email != nil && store.NormalizeEmail(*input.Email) != user.Email {
+		email = store.NormalizeEmail(*input.Email)
+		if _, err := h.Users.GetUserByEmail(ctx, email); err == nil {
+			problem.Error(c, &store.ConflictError{Field: "email"})
+			return
+		} else if !errors.Is(err, store.ErrNotFound) {
+			problem.Abort(c, problem.Internal.New("Failed to update user"))
+			return
+		}
+
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			problem.Abort(c, problem.Internal.New("Failed to update user"))
+			return
+		}
+		token = hex.EncodeToString(raw)
+	}
+
+	// The verification email goes out before anything is saved, so a failed
+	// send leaves the account as it was. A token mailed for a change that then
+	// fails to save matches nothing.
+	if token != "" {
+		name := user.Username
+		if newUsername {
+			name = *input.Username
+		}
+		text := fmt.Sprintf("Hello %s,\n\nConfirm this address for your AvidLogic account by sending this token to POST /v1/email-verifications:\n\n%s\n\nThe token expires in %d hours. If you did not ask for this change, ignore this email.\n",
+			name, token, int(emailVerificationTTL.Hours()))
+		if err := h.Mailer.Send(ctx, email, "Confirm your new email address", text); err != nil {
+			slog.ErrorContext(ctx, "sending email verification", "target_user_id", user.ID, "err", err)
+			problem.Abort(c, problem.Upstream.New("Failed to send the verification email, nothing was changed"))
+			return
+		}
+	}
+
+	// Each change only writes its own columns, so a password change or a
+	// disable made meanwhile is kept
+	now := time.Now()
+	if newUsername {
+		oldUsername := user.Username
+		updated, err := h.Users.SetUsername(ctx, user.ID, *input.Username, now)
+		if !h.userUpdated(c, user.ID, err) {
+			return
+		}
+		user = updated
+		h.record(c, audit.ActionUserUpdate, models.AuditSuccess, user, map[string]string{
+			"old_username": oldUsername, "username": user.Username})
+	}
+	if token != "" {
+		updated, err := h.Users.SetPendingEmail(ctx, user.ID, email, hashToken(token), now.Add(emailVerificationTTL), now)
+		if !h.userUpdated(c, user.ID, err) {
+			return
+		}
+		user = updated
+		h.record(c, audit.ActionEmailChangeRequest, models.AuditSuccess, user, map[string]string{
+			"email": user.Email, "pending_email": user.PendingEmail})
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// VerifyEmail applies a pending email change
+// @Summary Verify a new email address
+// @Description Confirms a pending email change with the token sent to the new address.
+// @Tags Account
+// @Accept json
+// @Produce json,application/problem+json
+// @Param verification body VerifyEmailInput true "Verification token"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Router /v1/email-verifications [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Abort(c, problem.Binding(err))
+		return
+	}
+
+	user, err := h.Users.GetUserByEmailVerification(c.Request.Context(), hashToken(input.Token))
+	if errors.Is(err, store.ErrNotFound) {
+		h.record(c, audit.ActionEmailVerify, models.AuditFailure, models.User{}, map[string]string{"reason": "unknown token"})
+		problem.Abort(c, problem.Field(problem.ValidationFailed, "token", "invalid", "is invalid or expired"))
+		return
+	} else if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to verify email"))
+		return
+	}
+	if user.EmailVerificationExpiresAt == nil || time.Now().After(*user.EmailVerificationExpiresAt) {
+		h.record(c, audit.ActionEmailVerify, models.AuditFailure, user, map[string]string{"reason": "expired token"})
+		problem.Abort(c, problem.Field(problem.ValidationFailed, "token", "invalid", "is invalid or expired"))
+		return
+	}
+
+	oldEmail := user.Email
+	updated, err := h.Users.ConfirmEmail(c.Request.Context(), user.ID, user.EmailVerificationHash, time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		// Another change replaced the pending email meanwhile
+		problem.Abort(c, problem.Field(problem.ValidationFailed, "token", "invalid", "is invalid or expired"))
+		return
+	} else if !h.userUpdated(c, user.ID, err) {
+		return
+	}
+	user = updated
+	h.record(c, audit.ActionEmailVerify, models.AuditSuccess, user, map[string]string{"old_email": oldEmail, "email": user.Email})
+
+	c.JSON(http.StatusOK, SuccessResponse{Message: "Email address updated"})
+}
+
+// ChangePassword replaces the password of the logged-in user
+// @Summary Change my password
+// @Description Replaces the password after confirming the current one. The new password is 10 to 72 bytes and must not appear in a known data breach. Every token issued so far stops working; the response carries a new one.
+// @Tags Account
+// @Accept json
+// @Produce json,application/problem+json
+// @Param password body ChangePasswordInput true "Current and new password"
+// @Success 200 {object} PasswordChangedResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/me/password [put]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Abort(c, problem.Binding(err))
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.CurrentPassword)); err != nil {
+		h.record(c, audit.ActionPasswordChange, models.AuditFailure, user, map[string]string{"reason": "wrong current password"})
+		problem.Abort(c, problem.InvalidCredentials.New("Current password is incorrect"))
+		return
+	}
+	if !h.checkPassword(c, "new_password", input.NewPassword) {
+		return
+	}
+
+	passwordHash, err := HashPassword(input.NewPassword)
+	if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to hash password"))
+		return
+	}
+	// Every token issued so far is revoked, the caller gets a new one
+	updated, err := h.Users.SetPasswordHash(c.Request.Context(), user.ID, passwordHash, time.Now())
+	if !h.userUpdated(c, user.ID, err) {
+		return
+	}
+	user = updated
+	h.record(c, audit.ActionPasswordChange, models.AuditSuccess, user, nil)
+
+	token, err := h.Tokens.Generate(user.ID.String(), user.TokenVersion)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "generating JWT", "err", err)
+		problem.Abort(c, problem.Internal.New("Password changed, but could not generate a new token; log in again"))
+		return
+	}
+
+	c.JSON(http.StatusOK, PasswordChangedResponse{Message: "Password changed successfully", Token: token})
+}
+
+// DeleteMe deletes the account of the logged-in user
+// @Summary Delete my account
+// @Description Deletes the account after confirming the password. The account and its projects disappear immediately and are permanently purged, with their reports and digest preferences, after the retention window.
+// @Tags Account
+// @Accept json
+// @Produce json,application/problem+json
+// @Param confirmation body DeleteAccountInput true "Password confirmation"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/me [delete]
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	var input DeleteAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Abort(c, problem.Binding(err))
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+		h.record(c, audit.ActionUserDelete, models.AuditFailure, user, map[string]string{"reason": "wrong password"})
+		problem.Abort(c, problem.InvalidCredentials.New("Password is incorrect"))
+		return
+	}
+
+	if err := h.Users.DeleteUser(c.Request.Context(), user.ID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "deleting user", "target_user_id", user.ID, "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to delete account"))
+		return
+	}
+	h.record(c, audit.ActionUserDelete, models.AuditSuccess, user, nil)
+
+	c.JSON(http.StatusOK, SuccessResponse{Message: "Account deleted"})
+}
+
+// auditTrail returns every audit event the user was the actor of, oldest first
+func (h *UserHandler) auditTrail(c *gin.Context, user models.User) ([]models.AuditEvent, error) {
+	if h.Audit == nil {
+		return nil, nil
+	}
+
+	var events []models.AuditEvent
+	filter := store.AuditFilter{ActorID: user.ID.String(), Limit: maxAuditPageSize}
+	for {
+		page, total, err := h.Audit.Events.ListAuditEvents(c.Request.Context(), filter)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		filter.Offset += len(page)
+		if len(page) == 0 || filter.Offset >= total {
+			break
+		}
+	}
+
+	// Pages come newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// ExportMe downloads everything stored about the logged-in user
+// @Summary Export my data
+// @Description Downloads a ZIP archive of everything stored about the logged-in user: profile, projects (without access tokens), digest preferences, report metadata, the audit events they caused and the workflow activity ingested for their projects.
+// @Tags Account
+// @Produce application/zip
+// @Success 200 {file} file
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/me/export [get]
+func (h *UserHandler) ExportMe(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	projects, err := h.Projects.ListProjects(c.Request.Context(), user.ID.String())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "exporting user data", "target_user_id", user.ID, "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to export data"))
+		return
+	}
+
+	events, err := h.auditTrail(c, user)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "exporting user data", "target_user_id", user.ID, "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to export data"))
+		return
+	}
+
+	now := time.Now()
+	archive, err := export.Build(c.Request.Context(), h.Exports, user, projects, events, now)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "exporting user data", "target_user_id", user.ID, "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to export data"))
+		return
+	}
+
+	h.record(c, audit.ActionDataExport, models.AuditSuccess, user, nil)
+
+	filename := fmt.Sprintf("avidlogic-export-%s.zip", now.Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// ListUsers lists and searches user accounts
+// @Summary List users
+// @Description Lists users, oldest first, optionally filtered by a case-insensitive search on username and email. Administrators only.
+// @Tags Admin
+// @Produce json,application/problem+json
+// @Param q query string false "Search term"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/admin/users [get]
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	filter := store.UserFilter{Query: c.Query("q"), Limit: defaultUserPageSize}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxUserPageSize {
+			problem.Abort(c, problem.InvalidParameter.New(fmt.Sprintf("Invalid limit, expected 1 to %d", maxUserPageSize)))
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid offset, expected a non-negative integer"))
+			return
+		}
+		filter.Offset = offset
+	}
+
+	users, total, err := h.Users.ListUsers(c.Request.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "listing users", "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to list users"))
+		return
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+
+	c.JSON(http.StatusOK, UserListResponse{Users: users, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// setDisabled loads the user named by :user_id and disables or re-enables it
+func (h *UserHandler) setDisabled(c *gin.Context, disabled bool) {
+	id, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		problem.Abort(c, problem.InvalidParameter.New("Invalid user ID"))
+		return
+	}
+	if userID, _ := c.Get("userID"); disabled && userID == id.String() {
+		problem.Abort(c, problem.Forbidden.New("You cannot disable your own account"))
+		return
+	}
+
+	action := audit.ActionUserEnable
+	if disabled {
+		action = audit.ActionUserDisable
+	}
+
+	// Disabling revokes the account's tokens, so they stay invalid once it is re-enabled
+	user, err := h.Users.SetDisabled(c.Request.Context(), id, disabled, time.Now())
+	if !h.userUpdated(c, id, err) {
+		return
+	}
+	h.record(c, action, models.AuditSuccess, user, map[string]string{"email": user.Email})
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DisableUser disables an account, locking it out of the API
+// @Summary Disable a user
+// @Description Disables an account: its existing tokens are revoked, even once it is re-enabled, and it cannot log in. Administrators only.
+// @Tags Admin
+// @Produce json,application/problem+json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/admin/users/{user_id}/disable [post]
+func (h *UserHandler) DisableUser(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled account
+// @Summary Re-enable a user
+// @Description Re-enables a disabled account. Administrators only.
+// @Tags Admin
+// @Produce json,application/problem+json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/admin/users/{user_id}/enable [post]
+func (h *UserHandler) EnableUser(c *gin.Context) {
+	h.setDisabled(c, false)
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"avidlogic/models"
+)
+
+func TestUpdateMeEmail(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "alice@example.com", "correct horse battery")
+	username, email := "alice2", "new@example.com"
+
+	// A failed send leaves the account untouched
+	s.mailer.err = errors.New("relay down")
+	var res problemCode
+	if status := s.do(http.MethodPatch, "/v1/me", token, UpdateMeInput{Username: &username, Email: &email}, &res); status != http.StatusBadGateway {
+		t.Fatalf("got %d %q, want 502", status, res.Code)
+	}
+	var user models.User
+	s.do(http.MethodGet, "/v1/me", token, nil, &user)
+	if user.Username != "alice" || user.PendingEmail != "" {
+		t.Errorf("got %s with pending email %q after a failed send, want no change", user.Username, user.PendingEmail)
+	}
+
+	s.mailer.err = nil
+	if status := s.do(http.MethodPatch, "/v1/me", token, UpdateMeInput{Username: &username, Email: &email}, &user); status != http.StatusOK {
+		t.Fatalf("got %d, want 200", status)
+	}
+	if user.Username != "alice2" || user.Email != "alice@example.com" || user.PendingEmail != "new@example.com" {
+		t.Errorf("got %s <%s> pending %q, want alice2 <alice@example.com> pending new@example.com", user.Username, user.Email, user.PendingEmail)
+	}
+	if len(s.mailer.sent) != 1 || s.mailer.sent[0] != "new@example.com" {
+		t.Errorf("sent emails to %v, want [new@example.com]", s.mailer.sent)
+	}
+}
+
+func TestUpdateMeKeepsConcurrentDisable(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "alice@example.com", "correct horse battery")
+	alice, _ := s.store.GetUserByEmail(context.Background(), "alice@example.com")
+
+	// An admin disables the account while the verification email is on its way
+	s.mailer.onSend = func() {
+		if _, err := s.store.SetDisabled(context.Background(), alice.ID, true, time.Now()); err != nil {
+			t.Error(err)
+		}
+	}
+	username, email := "alice2", "new@example.com"
+	if status := s.do(http.MethodPatch, "/v1/me", token, UpdateMeInput{Username: &username, Email: &email}, nil); status != http.StatusOK {
+		t.Fatalf("got %d, want 200", status)
+	}
+
+	user, _ := s.store.GetUserByID(context.Background(), alice.ID)
+	if user.DisabledAt == nil || user.TokenVersion != alice.TokenVersion+1 {
+		t.Errorf("got disabled_at %v and token version %d, want the disable kept", user.DisabledAt, user.TokenVersion)
+	}
+	if user.Username != "alice2" || user.PendingEmail != "new@example.com" {
+		t.Errorf("got %s pending %q, want alice2 pending new@example.com", user.Username, user.PendingEmail)
+	}
+}
+
+func TestChangePasswordRevokesTokens(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "alice@example.com", "correct horse battery")
+	other := s.login("alice@example.com", "correct horse battery")
+
+	var res PasswordChangedResponse
+	input := ChangePasswordInput{CurrentPassword: "correct horse battery", NewPassword: "another correct horse"}
+	if status := s.do(http.MethodPut, "/v1/me/password", token, input, &res); status != http.StatusOK || res.Token == "" {
+		t.Fatalf("got %d with token %q, want 200 with a new token", status, res.Token)
+	}
+
+	for _, revoked := range []string{token, other} {
+		var rejected problemCode
+		if status := s.do(http.MethodGet, "/v1/me", revoked, nil, &rejected); status != http.StatusUnauthorized || rejected.Code != "invalid_token" {
+			t.Errorf("token issued before the change: got %d %q, want 401 invalid_token", status, rejected.Code)
+		}
+	}
+	if status := s.do(http.MethodGet, "/v1/me", res.Token, nil, nil); status != http.StatusOK {
+		t.Errorf("new token: got %d, want 200", status)
+	}
+}
+
+func TestDisableUserRevokesTokens(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	token := s.signup("alice", "alice@example.com", "correct horse battery")
+	alice, _ := s.store.GetUserByEmail(context.Background(), "alice@example.com")
+
+	for _, action := range []string{"disable", "enable"} {
+		if status := s.do(http.MethodPost, "/v1/admin/users/"+alice.ID.String()+"/"+action, adminToken, nil, nil); status != http.StatusOK {
+			t.Fatalf("%s: got %d, want 200", action, status)
+		}
+	}
+
+	// Re-enabling the account does not bring its old tokens back
+	var res problemCode
+	if status := s.do(http.MethodGet, "/v1/me", token, nil, &res); status != http.StatusUnauthorized || res.Code != "invalid_token" {
+		t.Errorf("old token: got %d %q, want 401 invalid_token", status, res.Code)
+	}
+	if status := s.do(http.MethodGet, "/v1/me", s.login("alice@example.com", "correct horse battery"), nil, nil); status != http.StatusOK {
+		t.Errorf("new login: got %d, want 200", status)
+	}
+}
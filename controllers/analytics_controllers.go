@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"avidlogic/analytics"
+	"avidlogic/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAnalyticsWindow is used when no "from" date is given
+const defaultAnalyticsWindow = 30 * 24 * time.Hour
+
+// defaultBusFactorDepth is how many directory levels the bus-factor report descends by default
+const defaultBusFactorDepth = 2
+
+// PullRequestStatsResponse is returned by the pull request analytics endpoints
+type PullRequestStatsResponse struct {
+	From  time.Time                    `json:"from"`
+	To    time.Time                    `json:"to"`
+	Stats []analytics.PullRequestStats `json:"stats"`
+}
+
+// ContributorsResponse is returned by the contributor leaderboard endpoint
+type ContributorsResponse struct {
+	From         time.Time               `json:"from"`
+	To           time.Time               `json:"to"`
+	Contributors []analytics.Contributor `json:"contributors"`
+}
+
+// BusFactorResponse is returned by the bus-factor endpoint
+type BusFactorResponse struct {
+	From        time.Time                      `json:"from"`
+	To          time.Time                      `json:"to"`
+	Directories []analytics.DirectoryOwnership `json:"directories"`
+}
+
+// parseDate accepts either a plain date (2006-01-02) or an RFC 3339 timestamp
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseDateRange reads the "from" and "to" query parameters. "to" defaults to now
+// and "from" to 30 days before "to". A plain "to" date includes the whole day.
+func parseDateRange(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		parsed, err := parseDate(value)
+		if err != nil {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid 'to' date, expected YYYY-MM-DD or RFC 3339"))
+			return time.Time{}, time.Time{}, false
+		}
+		if len(value) == len("2006-01-02") {
+			parsed = parsed.Add(24*time.Hour - time.Nanosecond)
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultAnalyticsWindow)
+	if value := c.Query("from"); value != "" {
+		parsed, err := parseDate(value)
+		if err != nil {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid 'from' date, expected YYYY-MM-DD or RFC 3339"))
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		problem.Abort(c, problem.InvalidParameter.New("'from' must be before 'to'"))
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
+}
+
+// collectPullRequests loads the project and fetches its pull requests for the requested window
+func (h *ProjectHandler) collectPullRequests(c *gin.Context) ([]analytics.PullRequestRecord, time.Time, time.Time, bool) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return nil, time.Time{}, time.Time{}, false
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return nil, time.Time{}, time.Time{}, false
+	}
+
+	records, err := analytics.CollectPullRequests(c.Request.Context(), h.GitHubClient(project), project.Username, project.Repos(), from, to)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "fetching pull requests", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Upstream.New("Failed to fetch pull requests from GitHub"))
+		return nil, time.Time{}, time.Time{}, false
+	}
+
+	return records, from, to, true
+}
+
+// GetPullRequestStatsByRepo reports pull request cycle times per repository
+// @Summary Pull request cycle-time stats per repository
+// @Description Time to first review, time to approval, time to merge (p50/p90, hours) and PR size distribution for each repository in the project. PRs are selected by creation date; at most the 300 most recent PRs of each repository are analyzed.
+// @Tags Analytics
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Success 200 {object} PullRequestStatsResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/pulls/repositories [get]
+func (h *ProjectHandler) GetPullRequestStatsByRepo(c *gin.Context) {
+	records, from, to, ok := h.collectPullRequests(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, PullRequestStatsResponse{
+		From:  from,
+		To:    to,
+		Stats: analytics.SummarizePullRequests(records, analytics.ByRepo),
+	})
+}
+
+// GetPullRequestStatsByAuthor reports pull request cycle times per author
+// @Summary Pull request cycle-time stats per author
+// @Description Time to first review, time to approval, time to merge (p50/p90, hours) and PR size distribution for each pull request author across the project's repositories. At most the 300 most recent PRs of each repository are analyzed.
+// @Tags Analytics
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Success 200 {object} PullRequestStatsResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/pulls/authors [get]
+func (h *ProjectHandler) GetPullRequestStatsByAuthor(c *gin.Context) {
+	records, from, to, ok := h.collectPullRequests(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, PullRequestStatsResponse{
+		From:  from,
+		To:    to,
+		Stats: analytics.SummarizePullRequests(records, analytics.ByAuthor),
+	})
+}
+
+// wantsCSV reports whether the client asked for CSV through ?format=csv
+func wantsCSV(c *gin.Context) bool {
+	return strings.EqualFold(c.Query("format"), "csv")
+}
+
+// writeCSV sends rows as a CSV attachment
+func writeCSV(c *gin.Context, filename string, header []string, rows [][]string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(header)
+	_ = w.WriteAll(rows)
+}
+
+// GetContributors returns the contributor leaderboard of a project
+// @Summary Contributor leaderboard
+// @Description Commits, pull requests opened and reviewed, and lines changed per contributor across the project's repositories. Git emails and GitHub logins seen on the same commits are merged into one contributor. At most the 500 most recent commits and 300 most recent PRs of each repository are analyzed.
+// @Tags Analytics
+// @Produce json,application/problem+json
+// @Produce text/csv
+// @Param id path int true "Project ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Param format query string false "Response format: json (default) or csv"
+// @Success 200 {object} ContributorsResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/contributors [get]
+func (h *ProjectHandler) GetContributors(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	client := h.GitHubClient(project)
+	commits, err := analytics.CollectCommits(c.Request.Context(), client, project.Username, project.Repos(), from, to)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "fetching commits", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Upstream.New("Failed to fetch commits from GitHub"))
+		return
+	}
+
+	pulls, err := analytics.CollectPullRequests(c.Request.Context(), client, project.Username, project.Repos(), from, to)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "fetching pull requests", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Upstream.New("Failed to fetch pull requests from GitHub"))
+		return
+	}
+
+	contributors := analytics.Contributors(commits, pulls)
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(contributors))
+		for _, contributor := range contributors {
+			rows = append(rows, []string{
+				contributor.Name,
+				strings.Join(contributor.Logins, " "),
+				strings.Join(contributor.Emails, " "),
+				strconv.Itoa(contributor.Commits),
+				strconv.Itoa(contributor.PullRequestsOpened),
+				strconv.Itoa(contributor.PullRequestsReviewed),
+				strconv.Itoa(contributor.LinesAdded),
+				strconv.Itoa(contributor.LinesDeleted),
+			})
+		}
+		writeCSV(c, fmt.Sprintf("project-%d-contributors.csv", project.ID),
+			[]string{"name", "logins", "emails", "commits", "pull_requests_opened", "pull_requests_reviewed", "lines_added", "lines_deleted"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, ContributorsResponse{From: from, To: to, Contributors: contributors})
+}
+
+// GetBusFactor returns the knowledge-concentration report of a project
+// @Summary Bus factor per directory
+// @Description For each repository and directory (up to 'depth' levels), the number of contributors who together authored at least half of the changed lines, and the share of the top contributor. At most the 500 most recent commits of each repository are analyzed.
+// @Tags Analytics
+// @Produce json,application/problem+json
+// @Produce text/csv
+// @Param id path int true "Project ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Param depth query int false "Directory depth below each repository root (default 2)"
+// @Param format query string false "Response format: json (default) or csv"
+// @Success 200 {object} BusFactorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/bus-factor [get]
+func (h *ProjectHandler) GetBusFactor(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	depth := defaultBusFactorDepth
+	if value := c.Query("depth"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid depth"))
+			return
+		}
+		depth = parsed
+	}
+
+	commits, err := analytics.CollectCommits(c.Request.Context(), h.GitHubClient(project), project.Username, project.Repos(), from, to)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "fetching commits", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Upstream.New("Failed to fetch commits from GitHub"))
+		return
+	}
+
+	directories := analytics.BusFactor(commits, depth)
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(directories))
+		for _, dir := range directories {
+			rows = append(rows, []string{
+				dir.Directory,
+				strconv.Itoa(dir.LinesChanged),
+				strconv.Itoa(dir.Contributors),
+				strconv.Itoa(dir.BusFactor),
+				dir.TopContributor,
+				strconv.FormatFloat(dir.TopShare, 'f', 2, 64),
+			})
+		}
+		writeCSV(c, fmt.Sprintf("project-%d-bus-factor.csv", project.ID),
+			[]string{"directory", "lines_changed", "contributors", "bus_factor", "top_contributor", "top_share"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, BusFactorResponse{From: from, To: to, Directories: directories})
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"avidlogic/audit"
+	"avidlogic/models"
+	"avidlogic/problem"
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes of the audit log query
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 500
+)
+
+// AuditEventListResponse is one page of the audit log
+type AuditEventListResponse struct {
+	Events []models.AuditEvent `json:"events"`
+	Total  int                 `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+// AuditHandler serves the audit log endpoints
+type AuditHandler struct {
+	Audit *audit.Recorder
+}
+
+// NewAuditHandler returns a handler reading the log of the given recorder
+func NewAuditHandler(recorder *audit.Recorder) *AuditHandler {
+	return &AuditHandler{Audit: recorder}
+}
+
+// auditEvent starts an audit event for the request, with the authenticated
+// user (if any) as actor and the client's IP and user agent
+func auditEvent(c *gin.Context, action, outcome string) models.AuditEvent {
+	event := models.AuditEvent{
+		Action:    action,
+		Outcome:   outcome,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if userID, ok := c.Get("userID"); ok {
+		event.ActorID = userID.(string)
+	}
+	return event
+}
+
+// ListAuditEvents queries the audit log
+// @Summary Query the audit log
+// @Description Lists audit events, newest first, filtered by actor, action, target, outcome and time. Administrators only.
+// @Tags Admin
+// @Produce json,application/problem+json
+// @Param actor_id query string false "Actor user ID"
+// @Param action query string false "Action, e.g. user.login or project.create"
+// @Param target_type query string false "Target type, e.g. user or project"
+// @Param target_id query string false "Target ID"
+// @Param outcome query string false "success or failure"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339)"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339)"
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param offset query int false "Number of events to skip"
+// @Success 200 {object} AuditEventListResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/admin/audit-events [get]
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	filter := store.AuditFilter{
+		ActorID:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Outcome:    c.Query("outcome"),
+		Limit:      defaultAuditPageSize,
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := parseDate(value)
+		if err != nil {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid 'from' date, expected YYYY-MM-DD or RFC 3339"))
+			return
+		}
+		filter.From = from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseDate(value)
+		if err != nil {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid 'to' date, expected YYYY-MM-DD or RFC 3339"))
+			return
+		}
+		filter.To = to
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditPageSize {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid limit, expected 1 to "+strconv.Itoa(maxAuditPageSize)))
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid offset, expected a non-negative integer"))
+			return
+		}
+		filter.Offset = offset
+	}
+
+	events, total, err := h.Audit.Events.ListAuditEvents(c.Request.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "listing audit events", "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to list audit events"))
+		return
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+
+	c.JSON(http.StatusOK, AuditEventListResponse{Events: events, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// VerifyAuditLog checks the audit log hash chain
+// @Summary Verify the audit log
+// @Description Recomputes the hash chain of the whole audit log and reports the first modified, removed or reordered event, if any. Administrators only.
+// @Tags Admin
+// @Produce json,application/problem+json
+// @Success 200 {object} audit.VerifyResult
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/admin/audit-events/verify [get]
+func (h *AuditHandler) VerifyAuditLog(c *gin.Context) {
+	result, err := h.Audit.Verify(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "verifying audit log", "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to verify audit log"))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
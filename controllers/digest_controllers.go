@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"avidlogic/digest"
+	"avidlogic/models"
+	"avidlogic/netguard"
+	"avidlogic/problem"
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DigestPreferenceInput defines the weekly digest settings of a user
+type DigestPreferenceInput struct {
+	Enabled    *bool  `json:"enabled" binding:"required"`
+	Channel    string `json:"channel" binding:"required,oneof=email webhook"`
+	WebhookURL string `json:"webhook_url" binding:"omitempty,url"`    // required for the webhook channel
+	Weekday    *int   `json:"weekday" binding:"required,min=0,max=6"` // 0 = Sunday, UTC
+	Hour       *int   `json:"hour" binding:"required,min=0,max=23"`   // UTC
+}
+
+// DigestHandler serves the digest preferences of the logged-in user
+type DigestHandler struct {
+	Digests store.DigestStore
+	Guard   *netguard.Guard // decides which webhook URLs are accepted
+}
+
+// NewDigestHandler returns a handler persisting digest preferences in the
+// given store and accepting the webhook URLs guard allows
+func NewDigestHandler(digests store.DigestStore, guard *netguard.Guard) *DigestHandler {
+	return &DigestHandler{Digests: digests, Guard: guard}
+}
+
+// GetDigestPreference returns the weekly digest settings of the logged-in user
+// @Summary Get digest preferences
+// @Description Returns the weekly digest settings of the logged-in user. Users who never opted in get the disabled defaults.
+// @Tags Digest
+// @Produce json,application/problem+json
+// @Success 200 {object} models.DigestPreference
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/me/digest-preferences [get]
+func (h *DigestHandler) GetDigestPreference(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	pref, err := h.Digests.GetDigestPreference(c.Request.Context(), userID.(string))
+	if errors.Is(err, store.ErrNotFound) {
+		pref = models.DigestPreference{UserID: userID.(string), Channel: digest.ChannelEmail, Weekday: int(time.Monday), Hour: 8}
+	} else if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to load digest preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+// UpdateDigestPreference opts the logged-in user in or out of the weekly digest
+// @Summary Update digest preferences
+// @Description Enables or disables the weekly digest and sets its delivery channel (email or webhook) and schedule (weekday and hour, UTC). Webhook URLs must be https and point to a public host.
+// @Tags Digest
+// @Accept json
+// @Produce json,application/problem+json
+// @Param preferences body DigestPreferenceInput true "Digest preferences"
+// @Success 200 {object} models.DigestPreference
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/me/digest-preferences [put]
+func (h *DigestHandler) UpdateDigestPreference(c *gin.Context) {
+	var input DigestPreferenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Abort(c, problem.Binding(err))
+		return
+	}
+	if input.Channel == digest.ChannelWebhook && input.WebhookURL == "" {
+		problem.Abort(c, problem.Field(problem.ValidationFailed, "webhook_url", "required", "is required for the webhook channel"))
+		return
+	}
+	// The address is checked again on every delivery, as the host may
+	// resolve differently by then
+	if input.WebhookURL != "" {
+		if err := h.Guard.CheckURL(input.WebhookURL); err != nil {
+			problem.Abort(c, problem.Field(problem.ValidationFailed, "webhook_url", "public_https_url", "must be an https URL of a public host"))
+			return
+		}
+	}
+
+	userID, _ := c.Get("userID")
+	pref := models.DigestPreference{
+		UserID:     userID.(string),
+		Enabled:    *input.Enabled,
+		Channel:    input.Channel,
+		WebhookURL: input.WebhookURL,
+		Weekday:    *input.Weekday,
+		Hour:       *input.Hour,
+	}
+
+	if err := h.Digests.SaveDigestPreference(c.Request.Context(), pref); err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to save digest preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+// PreviewDigest compiles the current weekly digest of a project
+// @Summary Preview a project digest
+// @Description Compiles the digest the project would get now: merged PRs, PRs waiting for a first review, new issues, failing workflows and PAT health over the last 7 days.
+// @Tags Digest
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Success 200 {object} digest.Digest
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/digest [get]
+func (h *ProjectHandler) PreviewDigest(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	d, err := digest.Compile(c.Request.Context(), h.GitHubClient(project), h.Workflows, project, time.Now())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "compiling digest", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Upstream.New("Failed to compile digest"))
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
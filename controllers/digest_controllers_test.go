@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUpdateDigestPreferenceWebhookURL(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "alice@example.com", "correct horse battery")
+
+	enabled, weekday, hour := true, 1, 9
+	tests := []struct {
+		name     string
+		url      string
+		wantCode string
+	}{
+		{"public https", "https://hooks.example.com/T000/B000", ""},
+		{"plain http", "http://hooks.example.com/T000/B000", "public_https_url"},
+		{"loopback", "https://127.0.0.1:8080/admin", "public_https_url"},
+		{"cloud metadata", "https://169.254.169.254/latest/meta-data", "public_https_url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := DigestPreferenceInput{Enabled: &enabled, Channel: "webhook", WebhookURL: tt.url, Weekday: &weekday, Hour: &hour}
+			var res problemCode
+			status := s.do(http.MethodPut, "/v1/me/digest-preferences", token, input, &res)
+			if tt.wantCode == "" {
+				if status != http.StatusOK {
+					t.Fatalf("got %d, want 200", status)
+				}
+				return
+			}
+			if status != http.StatusBadRequest || len(res.Errors) != 1 || res.Errors[0].Code != tt.wantCode {
+				t.Errorf("got %d %+v, want 400 with %s", status, res.Errors, tt.wantCode)
+			}
+		})
+	}
+}
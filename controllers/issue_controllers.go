@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"avidlogic/analytics"
+	"avidlogic/models"
+	"avidlogic/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStaleDays is the inactivity threshold used when ?days is not given
+const defaultStaleDays = 30
+
+// IssueAgingResponse is returned by the issue aging endpoint
+type IssueAgingResponse struct {
+	Repositories []analytics.IssueAging `json:"repositories"`
+	// TruncatedRepos had more issues than are analyzed; only their most
+	// recently created ones were counted
+	TruncatedRepos []string `json:"truncated_repos,omitempty"`
+}
+
+// IssueFlowResponse is returned by the issue flow endpoint
+type IssueFlowResponse struct {
+	From         time.Time             `json:"from"`
+	To           time.Time             `json:"to"`
+	Repositories []analytics.IssueFlow `json:"repositories"`
+	// TruncatedRepos had more issues than are analyzed; only their most
+	// recently created ones were counted
+	TruncatedRepos []string `json:"truncated_repos,omitempty"`
+}
+
+// StaleIssuesResponse is returned by the stale issues endpoint
+type StaleIssuesResponse struct {
+	Days   int                    `json:"days"`
+	Issues []analytics.StaleIssue `json:"issues"`
+	// TruncatedRepos had more issues than are analyzed; only their most
+	// recently created ones were counted
+	TruncatedRepos []string `json:"truncated_repos,omitempty"`
+}
+
+// IssueLabelsResponse is returned by the label breakdown endpoint
+type IssueLabelsResponse struct {
+	Repositories []analytics.LabelBreakdown `json:"repositories"`
+	// TruncatedRepos had more issues than are analyzed; only their most
+	// recently created ones were counted
+	TruncatedRepos []string `json:"truncated_repos,omitempty"`
+}
+
+// fetchIssues fetches the project's issues and the repositories that had too
+// many, writing a 502 response on failure
+func (h *ProjectHandler) fetchIssues(c *gin.Context, project models.UserProject, state string, since time.Time) ([]analytics.IssueRecord, []string, bool) {
+	issues, truncated, err := analytics.CollectIssues(c.Request.Context(), h.GitHubClient(project), project.Username, project.Repos(), state, since)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "fetching issues", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Upstream.New("Failed to fetch issues from GitHub"))
+		return nil, nil, false
+	}
+	return issues, truncated, true
+}
+
+// GetIssueAging reports how old the open issues of each repository are
+// @Summary Open-issue age buckets
+// @Description Number of open issues per age bucket (0-7d, 7-30d, 30-90d, 90-365d, 365d+) and median age for every repository in the project. At most 1000 issues per repository are analyzed, the most recently created first; repositories with more are listed in truncated_repos.
+// @Tags Issues
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Success 200 {object} IssueAgingResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/issues/aging [get]
+func (h *ProjectHandler) GetIssueAging(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	issues, truncated, ok := h.fetchIssues(c, project, "open", time.Time{})
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, IssueAgingResponse{
+		Repositories:   analytics.IssueAges(project.Repos(), issues, time.Now()),
+		TruncatedRepos: truncated,
+	})
+}
+
+// GetIssueFlow reports weekly issue inflow and outflow
+// @Summary Weekly issue inflow vs. outflow
+// @Description Issues opened and closed per week (weeks start on Monday, UTC) for every repository in the project. At most 1000 issues per repository are analyzed, the most recently created first; repositories with more are listed in truncated_repos.
+// @Tags Issues
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Success 200 {object} IssueFlowResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/issues/flow [get]
+func (h *ProjectHandler) GetIssueFlow(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	// Any issue opened or closed in the window was updated at or after its start
+	issues, truncated, ok := h.fetchIssues(c, project, "all", from)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, IssueFlowResponse{
+		From:           from,
+		To:             to,
+		Repositories:   analytics.IssueFlows(project.Repos(), issues, from, to),
+		TruncatedRepos: truncated,
+	})
+}
+
+// GetStaleIssues lists open issues without recent activity
+// @Summary Stale issues
+// @Description Open issues that have not been updated for at least 'days' days, least recently updated first. At most 1000 issues per repository are analyzed, the most recently created first; repositories with more are listed in truncated_repos.
+// @Tags Issues
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param days query int false "Inactivity threshold in days (default 30)"
+// @Success 200 {object} StaleIssuesResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/issues/stale [get]
+func (h *ProjectHandler) GetStaleIssues(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	days := defaultStaleDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			problem.Abort(c, problem.InvalidParameter.New("Invalid days, expected a positive integer"))
+			return
+		}
+		days = parsed
+	}
+
+	issues, truncated, ok := h.fetchIssues(c, project, "open", time.Time{})
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, StaleIssuesResponse{
+		Days:           days,
+		Issues:         analytics.StaleIssues(issues, time.Now(), days),
+		TruncatedRepos: truncated,
+	})
+}
+
+// GetIssueLabels breaks open issues down by label
+// @Summary Open issues per label
+// @Description Number of open issues per label for every repository in the project. Issues without labels are counted under "(unlabeled)". At most 1000 issues per repository are analyzed, the most recently created first; repositories with more are listed in truncated_repos.
+// @Tags Issues
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Success 200 {object} IssueLabelsResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/issues/labels [get]
+func (h *ProjectHandler) GetIssueLabels(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	issues, truncated, ok := h.fetchIssues(c, project, "open", time.Time{})
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, IssueLabelsResponse{
+		Repositories:   analytics.LabelBreakdowns(project.Repos(), issues),
+		TruncatedRepos: truncated,
+	})
+}
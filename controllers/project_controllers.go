@@ -1,63 +1,175 @@
 package controllers
 
 import (
-	"avidlogic/database"
+	"avidlogic/audit"
+	"avidlogic/github"
 	"avidlogic/models"
-	"context"
+	"avidlogic/netguard"
+	"avidlogic/problem"
+	"avidlogic/providers"
+	"avidlogic/reports"
+	"avidlogic/store"
+	"avidlogic/validation"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ProjectHandler serves the project endpoints and everything scoped to a project
+type ProjectHandler struct {
+	Projects  store.ProjectStore
+	Workflows store.WorkflowStore
+	Reports   store.ReportStore
+	Queue     *reports.Queue // generates the reports created here
+	// NewProvider returns the forge client used to validate new projects
+	NewProvider func(name, baseURL string) (providers.Provider, error)
+	// GitHubClient returns the API client used for the analytics of a project
+	GitHubClient func(project models.UserProject) *github.Client
+	Audit        *audit.Recorder
+}
+
+// NewProjectHandler returns a handler persisting projects, their workflow runs
+// and reports in the given stores, generating reports on queue, reaching
+// forges through forges and recording project changes in the audit log
+func NewProjectHandler(projects store.ProjectStore, workflows store.WorkflowStore, reportStore store.ReportStore,
+	queue *reports.Queue, forges providers.Forges, recorder *audit.Recorder) *ProjectHandler {
+	return &ProjectHandler{Projects: projects, Workflows: workflows, Reports: reportStore, Queue: queue,
+		NewProvider: forges.New, GitHubClient: forges.GitHubClient, Audit: recorder}
+}
+
+// record appends an audit event about a project
+func (h *ProjectHandler) record(c *gin.Context, action, outcome string, projectID int, details map[string]string) {
+	event := auditEvent(c, action, outcome)
+	event.TargetType = "project"
+	if projectID != 0 {
+		event.TargetID = strconv.Itoa(projectID)
+	}
+	event.Details = details
+	h.Audit.Record(c.Request.Context(), event)
+}
+
 // Input struct for adding a project
 type AddProjectInput struct {
-	ProjectType string `json:"project_type" binding:"required"` // 'personal' or 'org'
-	Username    string `json:"username" binding:"required"`
-	PAT         string `json:"pat" binding:"required"`
-	RepoNames   string `json:"repo_names" binding:"required"` // Comma-separated repo names
+	Provider    string `json:"provider" binding:"omitempty,oneof=github gitlab bitbucket gitea" enums:"github,gitlab,bitbucket,gitea"` // 'github' (default), 'gitlab', 'bitbucket' or 'gitea'
+	BaseURL     string `json:"base_url" binding:"omitempty,url"`                                                                       // Self-hosted instance, e.g. https://gitlab.example.com
+	ProjectType string `json:"project_type" binding:"required,oneof=personal org" enums:"personal,org"`                                // 'personal' or 'org' (a GitLab group or Bitbucket workspace)
+	Username    string `json:"username" binding:"required,max=255"`                                                                    // Account or organization owning the repos
+	PAT         string `json:"pat" binding:"required"`                                                                                 // Personal access token, Bitbucket app password or access token
+	Login       string `json:"login"`                                                                                                  // Bitbucket account username, required with app passwords
+	RepoNames   string `json:"repo_names" binding:"required,repo_names"`                                                               // Comma-separated repo names
+}
+
+// baseURLRejected returns the error for a base URL the server may not connect to
+func baseURLRejected() *problem.Problem {
+	return problem.Field(problem.ValidationFailed, "base_url", "public_https_url", "must be an https URL of a public host")
 }
 
-// AddProject adds a new project (GitHub repositories) to the user
+// AddProject adds a new project (repositories on GitHub, GitLab, Bitbucket Cloud or Gitea) to the user
 // @Summary Add a new project
-// @Description Adds a new project to the user's account (personal or organizational repos)
+// @Description Adds a new project to the user's account (personal or organizational repos) on GitHub, GitLab, Bitbucket Cloud or Gitea, including self-hosted GitHub, GitLab and Gitea instances. Base URLs must be https and point to a public host or a network the server allows. Bitbucket app passwords need the account username in 'login'.
 // @Tags Projects
 // @Accept  json
 // @Produce  json
 // @Param project body AddProjectInput true "Project Details"
 // @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
 // @Security BearerAuth
-// @Router /projects [post]
-func AddProject(c *gin.Context) {
+// @Router /v1/projects [post]
+func (h *ProjectHandler) AddProject(c *gin.Context) {
 	var input AddProjectInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, ErrorResponse{Error: "Invalid input"})
+		problem.Abort(c, problem.Binding(err))
 		return
 	}
 
+	if input.Provider == "" {
+		input.Provider = providers.GitHub
+	}
+	provider, err := h.NewProvider(input.Provider, input.BaseURL)
+	if errors.Is(err, netguard.ErrInsecureURL) || errors.Is(err, netguard.ErrForbiddenAddress) {
+		problem.Abort(c, baseURLRejected())
+		return
+	} else if errors.Is(err, providers.ErrSelfHostedUnsupported) {
+		problem.Abort(c, problem.Unsupported.New("Self-hosted instances are not supported for provider: "+input.Provider))
+		return
+	} else if err != nil {
+		problem.Abort(c, problem.Unsupported.New("Unsupported provider: "+input.Provider))
+		return
+	}
+	if provider.Name() == providers.GitHub && !validGitHubNames(c, input) {
+		return
+	}
+	// Bitbucket app passwords authenticate together with the account username
+	if provider.Name() == providers.Bitbucket && input.Login != "" {
+		input.PAT = input.Login + ":" + input.PAT
+	}
+	ctx := c.Request.Context()
+	name := provider.DisplayName()
+	// The audit log never stores the token itself, only a fingerprint of it
+	details := map[string]string{
+		"provider":     provider.Name(),
+		"base_url":     strings.TrimRight(input.BaseURL, "/"),
+		"project_type": input.ProjectType,
+		"owner":        input.Username,
+		"repos":        input.RepoNames,
+		"pat":          audit.Fingerprint(input.PAT),
+	}
+	fail := func(reason string) {
+		details["reason"] = reason
+		h.record(c, audit.ActionProjectCreate, models.AuditFailure, 0, details)
+	}
+	// unreachable reports a forge that could not answer, as opposed to one
+	// that rejected the project details
+	unreachable := func(err error) {
+		fail("forge unreachable")
+		if errors.Is(err, netguard.ErrForbiddenAddress) {
+			problem.Abort(c, baseURLRejected())
+			return
+		}
+		slog.WarnContext(ctx, "validating project", "provider", provider.Name(), "err", err)
+		problem.Abort(c, problem.Upstream.New(name+" could not be reached, try again later"))
+	}
+
 	// Step 1: Validate the PAT
-	validPat, err := ValidatePAT(input.PAT)
-	if err != nil || !validPat {
-		c.JSON(400, ErrorResponse{Error: "Invalid GitHub PAT"})
+	validPat, err := provider.ValidatePAT(ctx, input.PAT)
+	if err != nil {
+		unreachable(err)
+		return
+	} else if !validPat {
+		fail("invalid credential")
+		problem.Abort(c, problem.ProviderRejected.New("Invalid "+name+" "+provider.CredentialTerm()))
 		return
 	}
 
-	// Step 2: Validate the GitHub user or organization
+	// Step 2: Validate the user or organization
 	if input.ProjectType == "personal" {
-		// Validate the GitHub user
-		validUser, err := ValidateUser(input.Username)
-		if err != nil || !validUser {
-			c.JSON(400, ErrorResponse{Error: "GitHub user not found"})
+		// Validate the user
+		validUser, err := provider.ValidateUser(ctx, input.PAT, input.Username)
+		if err != nil {
+			unreachable(err)
+			return
+		} else if !validUser {
+			fail("user not found")
+			problem.Abort(c, problem.ProviderRejected.New(name+" user not found"))
 			return
 		}
-	} else if input.ProjectType == "org" {
+	} else {
 		// Validate the organization
-		validOrg, err := ValidateOrg(input.PAT, input.Username)
-		if err != nil || !validOrg {
-			c.JSON(400, ErrorResponse{Error: "GitHub organization not found or no access"})
+		validOrg, err := provider.ValidateOrg(ctx, input.PAT, input.Username)
+		if err != nil {
+			unreachable(err)
+			return
+		} else if !validOrg {
+			fail("organization not found")
+			problem.Abort(c, problem.ProviderRejected.New(name+" "+provider.OrgTerm()+" not found or no access"))
 			return
 		}
 	}
@@ -66,9 +178,13 @@ func AddProject(c *gin.Context) {
 	repoNames := strings.Split(input.RepoNames, ",")
 	for _, repo := range repoNames {
 		repo = strings.TrimSpace(repo)
-		validRepo, err := ValidateRepoAccess(input.PAT, input.Username, repo)
-		if err != nil || !validRepo {
-			c.JSON(400, ErrorResponse{Error: "No access to repository: " + repo})
+		validRepo, err := provider.ValidateRepoAccess(ctx, input.PAT, input.Username, repo)
+		if err != nil {
+			unreachable(err)
+			return
+		} else if !validRepo {
+			fail("no access to repository " + repo)
+			problem.Abort(c, problem.ProviderRejected.New("No access to repository: "+repo))
 			return
 		}
 	}
@@ -77,6 +193,8 @@ func AddProject(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	newProject := models.UserProject{
 		UserID:      userID.(string),
+		Provider:    provider.Name(),
+		BaseURL:     strings.TrimRight(input.BaseURL, "/"),
 		ProjectType: input.ProjectType,
 		Username:    input.Username,
 		PAT:         input.PAT,
@@ -84,85 +202,93 @@ func AddProject(c *gin.Context) {
 		CreatedAt:   time.Now(),
 	}
 
-	query := `INSERT INTO user_projects (user_id, project_type, username, pat, repo_names, created_at) 
-              VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err = database.DB.Exec(context.Background(), query, newProject.UserID, newProject.ProjectType, newProject.Username, newProject.PAT, newProject.RepoNames, newProject.CreatedAt)
-	if err != nil {
-		c.JSON(500, ErrorResponse{Error: "Failed to add project"})
+	if err := h.Projects.CreateProject(c.Request.Context(), &newProject); err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to add project"))
 		return
 	}
+	h.record(c, audit.ActionProjectCreate, models.AuditSuccess, newProject.ID, details)
 
 	c.JSON(200, SuccessResponse{Message: "Project added successfully"})
 }
 
-// ValidatePAT checks if the provided PAT is valid by calling the /user GitHub API
-func ValidatePAT(pat string) (bool, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
-	if err != nil {
-		return false, err
+// validGitHubNames checks the owner and repository names against the GitHub
+// formats, writing the error response, before any call is spent on them
+func validGitHubNames(c *gin.Context, input AddProjectInput) bool {
+	if !validation.GitHubName(input.Username) {
+		problem.Abort(c, problem.Field(problem.ValidationFailed, "username", "github_name", validation.Messages["github_name"]))
+		return false
 	}
-
-	req.Header.Set("Authorization", "token "+pat)
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return false, err
+	for _, repo := range strings.Split(input.RepoNames, ",") {
+		if repo = strings.TrimSpace(repo); !validation.GitHubRepo(repo) {
+			problem.Abort(c, problem.Field(problem.ValidationFailed, "repo_names", "github_repo",
+				"contains an invalid GitHub repository name: "+repo))
+			return false
+		}
 	}
-
-	defer resp.Body.Close()
-	return true, nil
+	return true
 }
 
-// ValidateUser checks if the GitHub user exists
-func ValidateUser(username string) (bool, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.github.com/users/"+username, nil)
-	if err != nil {
-		return false, err
+// DeleteProject deletes a project of the logged-in user
+// @Summary Delete a project
+// @Description Deletes the project. It disappears immediately and is permanently purged, with its workflow runs and reports, after the retention window.
+// @Tags Projects
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id} [delete]
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
 	}
 
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return false, err
+	if err := h.Projects.DeleteProject(c.Request.Context(), project.ID, project.UserID); err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to delete project"))
+		return
 	}
+	h.record(c, audit.ActionProjectDelete, models.AuditSuccess, project.ID, map[string]string{
+		"provider": project.Provider, "owner": project.Username, "repos": project.RepoNames})
 
-	defer resp.Body.Close()
-	return true, nil
+	c.JSON(http.StatusOK, SuccessResponse{Message: "Project deleted"})
 }
 
-// ValidateOrg checks if the GitHub organization exists
-func ValidateOrg(pat, org string) (bool, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.github.com/orgs/"+org, nil)
+// loadProject fetches the project named by the :id route parameter, making sure it
+// belongs to the authenticated user. It writes the error response and returns false on failure.
+func (h *ProjectHandler) loadProject(c *gin.Context) (models.UserProject, bool) {
+	projectID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return false, err
+		problem.Abort(c, problem.InvalidParameter.New("Invalid project ID"))
+		return models.UserProject{}, false
 	}
 
-	req.Header.Set("Authorization", "token "+pat)
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return false, err
+	userID, _ := c.Get("userID")
+	project, err := h.Projects.GetProject(c.Request.Context(), projectID, userID.(string))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Abort(c, problem.NotFound.New("Project not found"))
+			return project, false
+		}
+		problem.Abort(c, problem.Internal.New("Failed to load project"))
+		return project, false
 	}
 
-	defer resp.Body.Close()
-	return true, nil
+	return project, true
 }
 
-// ValidateRepoAccess checks if the PAT can access the given repository
-func ValidateRepoAccess(pat, owner, repo string) (bool, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.github.com/repos/"+owner+"/"+repo, nil)
-	if err != nil {
-		return false, err
+// loadGitHubProject is loadProject for endpoints backed by the GitHub API
+func (h *ProjectHandler) loadGitHubProject(c *gin.Context) (models.UserProject, bool) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return project, false
 	}
 
-	// Add the Authorization header with the PAT
-	req.Header.Set("Authorization", "token "+pat)
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return false, err
+	if project.Provider != providers.GitHub {
+		problem.Abort(c, problem.Unsupported.New("Analytics are only available for GitHub projects"))
+		return project, false
 	}
 
-	defer resp.Body.Close()
-	return true, nil
+	return project, true
 }
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestProjectCRUD(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signup("alice", "alice@example.com", "correct horse battery")
+	bob := s.signup("bob", "bob@example.com", "correct horse battery")
+
+	input := AddProjectInput{ProjectType: "personal", Username: "alice", PAT: "ghp_token", RepoNames: "api, web"}
+	if status := s.do(http.MethodPost, "/v1/projects", alice, input, nil); status != http.StatusOK {
+		t.Fatalf("adding the project: status %d", status)
+	}
+
+	user, err := s.store.GetUserByEmail(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects, err := s.store.ListProjects(context.Background(), user.ID.String())
+	if err != nil || len(projects) != 1 {
+		t.Fatalf("got projects %+v (%v), want one", projects, err)
+	}
+	project := projects[0]
+	if project.Provider != "github" || project.Username != "alice" || project.RepoNames != "api, web" {
+		t.Errorf("stored %+v", project)
+	}
+	path := "/v1/projects/" + strconv.Itoa(project.ID)
+
+	// Only the owner sees the project
+	if status := s.do(http.MethodGet, path+"/workflows", alice, nil, nil); status != http.StatusOK {
+		t.Errorf("reading as the owner: got %d, want 200", status)
+	}
+	var res problemCode
+	if status := s.do(http.MethodGet, path+"/workflows", bob, nil, &res); status != http.StatusNotFound || res.Code != "not_found" {
+		t.Errorf("reading as another user: got %d %q, want 404 not_found", status, res.Code)
+	}
+	if status := s.do(http.MethodDelete, path, bob, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleting as another user: got %d, want 404", status)
+	}
+
+	if status := s.do(http.MethodDelete, path, alice, nil, nil); status != http.StatusOK {
+		t.Fatalf("deleting the project: status %d", status)
+	}
+	if status := s.do(http.MethodGet, path+"/workflows", alice, nil, nil); status != http.StatusNotFound {
+		t.Errorf("reading after deletion: got %d, want 404", status)
+	}
+	if status := s.do(http.MethodDelete, path, alice, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleting twice: got %d, want 404", status)
+	}
+}
+
+func TestAddProjectRejected(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "alice@example.com", "correct horse battery")
+
+	tests := []struct {
+		name     string
+		input    AddProjectInput
+		status   int
+		wantCode string
+	}{
+		{"invalid token", AddProjectInput{ProjectType: "personal", Username: "alice", PAT: "missing", RepoNames: "api"},
+			http.StatusBadRequest, "provider_rejected"},
+		{"unknown user", AddProjectInput{ProjectType: "personal", Username: "missing", PAT: "ghp_token", RepoNames: "api"},
+			http.StatusBadRequest, "provider_rejected"},
+		{"unknown organization", AddProjectInput{ProjectType: "org", Username: "missing", PAT: "ghp_token", RepoNames: "api"},
+			http.StatusBadRequest, "provider_rejected"},
+		{"inaccessible repository", AddProjectInput{ProjectType: "personal", Username: "alice", PAT: "ghp_token", RepoNames: "api,missing"},
+			http.StatusBadRequest, "provider_rejected"},
+		{"invalid GitHub name", AddProjectInput{ProjectType: "personal", Username: "-alice", PAT: "ghp_token", RepoNames: "api"},
+			http.StatusBadRequest, "validation_failed"},
+		{"missing fields", AddProjectInput{ProjectType: "personal"}, http.StatusBadRequest, "validation_failed"},
+		{"plain http base URL", AddProjectInput{Provider: "gitlab", BaseURL: "http://gitlab.example.com", ProjectType: "personal", Username: "alice", PAT: "glpat", RepoNames: "api"},
+			http.StatusBadRequest, "validation_failed"},
+		{"internal base URL", AddProjectInput{Provider: "gitlab", BaseURL: "https://10.0.0.5", ProjectType: "personal", Username: "alice", PAT: "glpat", RepoNames: "api"},
+			http.StatusBadRequest, "validation_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res problemCode
+			if status := s.do(http.MethodPost, "/v1/projects", token, tt.input, &res); status != tt.status || res.Code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %s", status, res.Code, tt.status, tt.wantCode)
+			}
+		})
+	}
+
+	user, _ := s.store.GetUserByEmail(context.Background(), "alice@example.com")
+	if projects, _ := s.store.ListProjects(context.Background(), user.ID.String()); len(projects) != 0 {
+		t.Errorf("rejected projects were stored: %+v", projects)
+	}
+}
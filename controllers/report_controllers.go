@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"avidlogic/models"
+	"avidlogic/problem"
+	"avidlogic/reports"
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ReportHandler serves the reports of the logged-in user
+type ReportHandler struct {
+	Reports store.ReportStore
+}
+
+// NewReportHandler returns a handler reading reports from the given store
+func NewReportHandler(reports store.ReportStore) *ReportHandler {
+	return &ReportHandler{Reports: reports}
+}
+
+// CreateReportInput defines the report to generate. From and To default like the analytics endpoints.
+type CreateReportInput struct {
+	Kind   string `json:"kind" binding:"required,oneof=pull_requests contributors issues workflows"`
+	Format string `json:"format" binding:"required,oneof=csv jsonl pdf"`
+	From   string `json:"from"` // YYYY-MM-DD or RFC 3339
+	To     string `json:"to"`   // YYYY-MM-DD or RFC 3339
+}
+
+// CreateReport queues the generation of a project report
+// @Summary Generate a report
+// @Description Queues a report of the project's analytics (pull_requests, contributors, issues or workflows) rendered as CSV, JSON Lines or PDF. Poll the status endpoint and download the artifact once completed.
+// @Tags Reports
+// @Accept json
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param report body CreateReportInput true "Report details"
+// @Success 202 {object} models.Report
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 503 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/reports [post]
+func (h *ProjectHandler) CreateReport(c *gin.Context) {
+	var input CreateReportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Abort(c, problem.Binding(err))
+		return
+	}
+
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	// Reuse the analytics date handling by exposing the body fields as query parameters
+	query := c.Request.URL.Query()
+	query.Set("from", input.From)
+	query.Set("to", input.To)
+	c.Request.URL.RawQuery = query.Encode()
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	report := models.Report{
+		ID:        uuid.New(),
+		UserID:    userID.(string),
+		ProjectID: project.ID,
+		Kind:      input.Kind,
+		Format:    input.Format,
+		Status:    models.ReportPending,
+		From:      from,
+		To:        to,
+		CreatedAt: time.Now(),
+	}
+
+	if err := h.Reports.CreateReport(c.Request.Context(), report); err != nil {
+		slog.ErrorContext(c.Request.Context(), "creating report", "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to create report"))
+		return
+	}
+
+	err := h.Queue.Enqueue(reports.Job{
+		ReportID: report.ID,
+		Project:  project,
+		Client:   h.GitHubClient(project),
+		Kind:     report.Kind,
+		Format:   report.Format,
+		From:     from,
+		To:       to,
+	})
+	if err != nil {
+		_ = h.Reports.FailReport(c.Request.Context(), report.ID, "Too many reports in progress")
+		problem.Abort(c, problem.Unavailable.New("Too many reports in progress, try again later"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, report)
+}
+
+// loadReport fetches the report named by the :report_id route parameter for the authenticated user
+func (h *ReportHandler) loadReport(c *gin.Context) (models.Report, bool) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		problem.Abort(c, problem.InvalidParameter.New("Invalid report ID"))
+		return models.Report{}, false
+	}
+
+	userID, _ := c.Get("userID")
+	report, err := h.Reports.GetReport(c.Request.Context(), reportID, userID.(string))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Abort(c, problem.NotFound.New("Report not found"))
+			return report, false
+		}
+		problem.Abort(c, problem.Internal.New("Failed to load report"))
+		return report, false
+	}
+
+	return report, true
+}
+
+// GetReportStatus returns the generation status of a report
+// @Summary Get report status
+// @Description Returns the report's status: pending, running, completed or failed (with the reason).
+// @Tags Reports
+// @Produce json,application/problem+json
+// @Param report_id path string true "Report ID"
+// @Success 200 {object} models.Report
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/reports/{report_id} [get]
+func (h *ReportHandler) GetReportStatus(c *gin.Context) {
+	report, ok := h.loadReport(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// DownloadReport sends the generated report file
+// @Summary Download a report
+// @Description Downloads the artifact of a completed report.
+// @Tags Reports
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/pdf
+// @Param report_id path string true "Report ID"
+// @Success 200 {file} file
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/reports/{report_id}/download [get]
+func (h *ReportHandler) DownloadReport(c *gin.Context) {
+	report, ok := h.loadReport(c)
+	if !ok {
+		return
+	}
+
+	if report.Status != models.ReportCompleted {
+		problem.Abort(c, problem.NotReady.New("Report is "+report.Status))
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	contentType, artifact, err := h.Reports.GetReportArtifact(c.Request.Context(), report.ID, userID.(string))
+	if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to load report"))
+		return
+	}
+
+	filename := fmt.Sprintf("project-%d-%s-%s%s", report.ProjectID, report.Kind, report.CreatedAt.Format("20060102"), reports.Extension(report.Format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, artifact)
+}
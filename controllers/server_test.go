@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"avidlogic/audit"
+	"avidlogic/auth"
+	"avidlogic/middleware"
+	"avidlogic/models"
+	"avidlogic/providers"
+	"avidlogic/reports"
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	// Hashing at the production cost would make every signup take a second
+	passwordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// testServer serves the handlers on a memory store
+type testServer struct {
+	t        *testing.T
+	store    *store.MemoryStore
+	mailer   *fakeMailer
+	users    *UserHandler
+	projects *ProjectHandler
+	digests  *DigestHandler
+	router   *gin.Engine
+}
+
+// newTestServer routes the API like main does, without rate limits or the
+// deprecated aliases
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db := store.NewMemoryStore()
+	mailer := &fakeMailer{}
+	recorder := audit.NewRecorder(db)
+	tokens := auth.NewJWT("a test signing key of at least 32 bytes", time.Hour)
+	s := &testServer{
+		t:        t,
+		store:    db,
+		mailer:   mailer,
+		users:    NewUserHandler(db, db, db, mailer, recorder, tokens, nil),
+		projects: NewProjectHandler(db, db, db, reports.NewQueue(db, db), providers.Forges{}, recorder),
+		digests:  NewDigestHandler(db, nil),
+		router:   gin.New(),
+	}
+	// The real providers check the name and base URL, the fake validates
+	s.projects.NewProvider = func(name, baseURL string) (providers.Provider, error) {
+		if _, err := (providers.Forges{}).New(name, baseURL); err != nil {
+			return nil, err
+		}
+		return fakeProvider{}, nil
+	}
+
+	authRequired := middleware.AuthMiddleware(tokens, db)
+	v1 := s.router.Group("/v1")
+	v1.POST("/users", s.users.CreateUser)
+	v1.POST("/sessions", s.users.Login)
+	me := v1.Group("/me", authRequired)
+	me.GET("", s.users.GetMe)
+	me.PATCH("", s.users.UpdateMe)
+	me.DELETE("", s.users.DeleteMe)
+	me.PUT("/password", s.users.ChangePassword)
+	me.PUT("/digest-preferences", s.digests.UpdateDigestPreference)
+	admin := v1.Group("/admin", authRequired, middleware.AdminMiddleware())
+	admin.POST("/users/:user_id/disable", s.users.DisableUser)
+	admin.POST("/users/:user_id/enable", s.users.EnableUser)
+	project := v1.Group("/projects", authRequired)
+	project.POST("", s.projects.AddProject)
+	project.DELETE("/:id", s.projects.DeleteProject)
+	project.GET("/:id/workflows", s.projects.GetWorkflowStats)
+	return s
+}
+
+// do sends a request with an optional JSON body and bearer token and decodes
+// the JSON response into out, when not nil
+func (s *testServer) do(method, path, token string, body, out any) int {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+// signup creates an account and returns a login token for it
+func (s *testServer) signup(username, email, password string) string {
+	s.t.Helper()
+	input := CreateUserInput{Username: username, Email: email, Password: password}
+	if status := s.do(http.MethodPost, "/v1/users", "", input, nil); status != http.StatusOK {
+		s.t.Fatalf("signup %s: status %d", username, status)
+	}
+	return s.login(email, password)
+}
+
+// login returns a token for the credentials
+func (s *testServer) login(email, password string) string {
+	s.t.Helper()
+	var res struct{ Token string }
+	if status := s.do(http.MethodPost, "/v1/sessions", "", LoginInput{Email: email, Password: password}, &res); status != http.StatusOK {
+		s.t.Fatalf("login %s: status %d", email, status)
+	}
+	return res.Token
+}
+
+// problemCode is the part of a problem response the tests branch on
+type problemCode struct {
+	Code   string
+	Errors []struct{ Field, Code string }
+}
+
+// admin creates an administrator, which cannot sign up, and logs them in
+func (s *testServer) admin() string {
+	s.t.Helper()
+	passwordHash, err := HashPassword("correct horse battery")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	now := time.Now()
+	admin := models.User{ID: uuid.New(), Username: "admin", Email: "admin@example.com",
+		PasswordHash: passwordHash, IsAdmin: true, CreatedAt: now, UpdatedAt: now}
+	if err := s.store.CreateUser(context.Background(), admin); err != nil {
+		s.t.Fatal(err)
+	}
+	return s.login("admin@example.com", "correct horse battery")
+}
+
+// fakeMailer records the emails it is asked to send, failing with err when
+// set. onSend runs while an email is being sent.
+type fakeMailer struct {
+	mu     sync.Mutex
+	err    error
+	sent   []string // recipients
+	onSend func()
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to, subject, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.onSend != nil {
+		m.onSend()
+	}
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+// fakeProvider accepts every credential, owner and repository except those
+// named "missing"
+type fakeProvider struct{}
+
+func (fakeProvider) Name() string           { return providers.GitHub }
+func (fakeProvider) DisplayName() string    { return "GitHub" }
+func (fakeProvider) OrgTerm() string        { return "organization" }
+func (fakeProvider) CredentialTerm() string { return "PAT" }
+
+func (fakeProvider) ValidatePAT(ctx context.Context, token string) (bool, error) {
+	return token != "missing", nil
+}
+
+func (fakeProvider) ValidateUser(ctx context.Context, token, username string) (bool, error) {
+	return username != "missing", nil
+}
+
+func (fakeProvider) ValidateOrg(ctx context.Context, token, org string) (bool, error) {
+	return org != "missing", nil
+}
+
+func (fakeProvider) ValidateRepoAccess(ctx context.Context, token, owner, repo string) (bool, error) {
+	return repo != "missing", nil
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+
+	"avidlogic/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz reports that the process is alive
+// @Summary Liveness probe
+// @Description Always succeeds while the process is serving requests. It does not check dependencies; use /readyz for that.
+// @Tags System
+// @Produce json,application/problem+json
+// @Success 200 {object} health.Component
+// @Router /healthz [get]
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, health.Component{Status: health.StatusUp})
+}
+
+// Readyz reports whether the server can take traffic
+// @Summary Readiness probe
+// @Description Checks Postgres connectivity, pending migrations and the background worker heartbeats, with one entry per component. Fails while the server shuts down.
+// @Tags System
+// @Produce json,application/problem+json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func Readyz(c *gin.Context) {
+	report := health.Ready(c.Request.Context())
+	if report.Status != health.StatusUp {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
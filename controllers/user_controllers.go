@@ -1,23 +1,83 @@
 package controllers
 
 import (
-	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"avidlogic/audit"
 	"avidlogic/auth"
-	"avidlogic/database"
+	"avidlogic/export"
+	"avidlogic/mail"
+	"avidlogic/metrics"
 	"avidlogic/models"
+	"avidlogic/problem"
+	"avidlogic/store"
+	"avidlogic/validation"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// ErrorResponse defines the structure for error messages.
-type ErrorResponse struct {
-	Error string `json:"error"`
+// UserHandler serves the account endpoints
+type UserHandler struct {
+	Users    store.UserStore
+	Projects store.ProjectStore
+	Exports  export.Source // the rest of the data exported with an account
+	Mailer   mail.Sender
+	Audit    *audit.Recorder
+	Tokens   *auth.JWT
+	Breached *validation.BreachedList // nil skips the breached password check
+
+	dummyHash []byte // checked against on logins with an unknown email
+}
+
+// NewUserHandler returns a handler persisting users and reading their projects
+// and exported data in the given stores, sending account emails through
+// mailer, recording account changes in the audit log, issuing login tokens
+// with tokens and rejecting new passwords found in breached
+func NewUserHandler(users store.UserStore, projects store.ProjectStore, exports export.Source, mailer mail.Sender,
+	recorder *audit.Recorder, tokens *auth.JWT, breached *validation.BreachedList) *UserHandler {
+	// Hashed up front so that no login pays for it
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("not a password of any account"), passwordCost)
+	if err != nil {
+		panic(err) // only fails for an invalid cost
+	}
+	return &UserHandler{Users: users, Projects: projects, Exports: exports, Mailer: mailer, Audit: recorder, Tokens: tokens,
+		Breached: breached, dummyHash: dummyHash}
+}
+
+// checkPassword rejects a new password found in the breached password list,
+// writing the error response. The list being unreadable does not block users.
+func (h *UserHandler) checkPassword(c *gin.Context, field, password string) bool {
+	breached, err := h.Breached.Breached(password)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "checking breached passwords", "err", err)
+		return true
+	}
+	if breached {
+		problem.Abort(c, problem.Field(problem.ValidationFailed, field, "breached",
+			"appears in a known data breach, choose another one"))
+		return false
+	}
+	return true
+}
+
+// record appends an audit event about the target user. On unauthenticated
+// routes, such as email verification, the target is also the actor.
+func (h *UserHandler) record(c *gin.Context, action, outcome string, target models.User, details map[string]string) {
+	event := auditEvent(c, action, outcome)
+	event.TargetType = "user"
+	if target.ID != uuid.Nil {
+		event.TargetID = target.ID.String()
+		if event.ActorID == "" {
+			event.ActorID = event.TargetID
+		}
+	}
+	event.Details = details
+	h.Audit.Record(c.Request.Context(), event)
 }
 
 // SuccessResponse defines the structure of the success response
@@ -27,9 +87,9 @@ type SuccessResponse struct {
 
 // Input struct for creating a user
 type CreateUserInput struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string `json:"username" binding:"required,username"` // 3 to 32 letters, digits, '.', '_' or '-'
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required,password"` // 10 to 72 bytes, not in a known breach
 }
 
 // LoginInput defines the fields required for login
@@ -38,26 +98,15 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// Centralized error logging and response
-func handleError(c *gin.Context, status int, message string) {
-	log.Printf("[Error] %s | Status: %d", message, status)
-	c.JSON(status, ErrorResponse{Error: message})
-}
-
-// UserProfile is a protected route to get user profile
-// @Summary Get user profile
-// @Description Fetch the profile of the logged-in user
-// @Tags Users
-// @Produce json
-// @Security BearerAuth
-// @Success 200 {object} map[string]string
-// @Failure 401 {object} ErrorResponse
-// @Router /protected/profile [get]
-func UserProfile(c *gin.Context) {
+// UserProfile is a protected route to get user profile. It is only served at
+// the unversioned /protected/profile and left out of the API docs.
+//
+// Deprecated: GetMe replaces it.
+func (h *UserHandler) UserProfile(c *gin.Context) {
 	// Retrieve the user ID from the context (set by the JWT middleware)
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "User not found"})
+		problem.Abort(c, problem.InvalidToken.New("User not found"))
 		return
 	}
 
@@ -73,103 +122,144 @@ func UserProfile(c *gin.Context) {
 // @Description Authenticate user and return JWT token
 // @Tags Users
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param user body LoginInput true "Login credentials"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} ErrorResponse
-// @Failure 401 {object} ErrorResponse
-// @Router /login [post]
-func Login(c *gin.Context) {
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /v1/sessions [post]
+func (h *UserHandler) Login(c *gin.Context) {
 	var input LoginInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid input"})
+		problem.Abort(c, problem.Binding(err))
 		return
 	}
 
+	// Every attempt is audited under the email it was made with
+	event := auditEvent(c, audit.ActionLogin, models.AuditFailure)
+	event.Actor = store.NormalizeEmail(input.Email)
+	defer func() { metrics.Login(event.Outcome) }()
+
 	// Check if the user exists
-	var user models.User
-	err := database.DB.QueryRow(context.Background(), "SELECT id, password_hash FROM users WHERE email=$1", input.Email).Scan(&user.ID, &user.PasswordHash)
-	if err != nil {
-		handleError(c, http.StatusUnauthorized, "Invalid credentials")
+	user, err := h.Users.GetUserByEmail(c.Request.Context(), input.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		// Spend the time of a password check so that response times do not
+		// tell which emails have an account
+		bcrypt.CompareHashAndPassword(h.dummyHash, []byte(input.Password))
+		event.Details = map[string]string{"reason": "unknown email"}
+		h.Audit.Record(c.Request.Context(), event)
+		problem.Abort(c, problem.InvalidCredentials.New("Invalid credentials"))
+		return
+	} else if err != nil {
+		slog.ErrorContext(c.Request.Context(), "loading user to log in", "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to log in"))
 		return
 	}
+	event.ActorID = user.ID.String()
 
 	// Compare the provided password with the stored hashed password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
 	if err != nil {
-		handleError(c, http.StatusUnauthorized, "Invalid credentials")
+		event.Details = map[string]string{"reason": "wrong password"}
+		h.Audit.Record(c.Request.Context(), event)
+		problem.Abort(c, problem.InvalidCredentials.New("Invalid credentials"))
+		return
+	}
+
+	if user.DisabledAt != nil {
+		event.Details = map[string]string{"reason": "account disabled"}
+		h.Audit.Record(c.Request.Context(), event)
+		problem.Abort(c, problem.AccountDisabled.New(""))
 		return
 	}
 
 	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID.String())
+	token, err := h.Tokens.Generate(user.ID.String(), user.TokenVersion)
 	if err != nil {
-		log.Printf("Error generating JWT: %v", err)
-		handleError(c, http.StatusInternalServerError, "Could not generate token")
+		slog.ErrorContext(c.Request.Context(), "generating JWT", "err", err)
+		problem.Abort(c, problem.Internal.New("Could not generate token"))
 		return
 	}
 
+	event.Outcome = models.AuditSuccess
+	h.Audit.Record(c.Request.Context(), event)
+
 	// Return the token to the client
 	c.JSON(http.StatusOK, gin.H{"token": token})
 }
 
+// passwordCost is the bcrypt cost of password hashes
+var passwordCost = 14
+
 // HashPassword hashes the password using bcrypt
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
 	return string(bytes), err
 }
 
 // CreateUser handles the creation of a new user
 // @Summary Create a new user
-// @Description Create a new user with username, email, and password
+// @Description Create a new user with username, email, and password. Usernames are 3 to 32 letters, digits, '.', '_' or '-'; passwords are 10 to 72 bytes and must not appear in a known data breach.
 // @Tags Users
 // @Accept json
-// @Produce json
+// @Produce json,application/problem+json
 // @Param user body CreateUserInput true "User Data"
 // @Success 200 {object} models.User
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /users [post]
-func CreateUser(c *gin.Context) {
+// @Failure 400 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /v1/users [post]
+func (h *UserHandler) CreateUser(c *gin.Context) {
 	var input CreateUserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		problem.Abort(c, problem.Binding(err))
 		return
 	}
-
-	// Check if user already exists
-	var existingUser models.User
-	err := database.DB.QueryRow(context.Background(), "SELECT id FROM users WHERE email=$1", input.Email).Scan(&existingUser.ID)
-	if err == nil {
-		c.JSON(http.StatusConflict, ErrorResponse{Error: "User with this email already exists"})
+	if !h.checkPassword(c, "password", input.Password) {
 		return
 	}
 
 	passwordHash, err := HashPassword(input.Password)
 	if err != nil {
-		log.Fatal("Failed to hash password:", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to hash password"})
+		slog.ErrorContext(c.Request.Context(), "hashing password", "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to hash password"))
 		return
 	}
 
 	newUser := models.User{
 		ID:           uuid.New(),
 		Username:     input.Username,
-		Email:        input.Email,
+		Email:        store.NormalizeEmail(input.Email),
 		PasswordHash: passwordHash,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
-	query := `INSERT INTO users (id, username, email, password_hash, created_at, updated_at) 
-              VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err = database.DB.Exec(context.Background(), query,
-		newUser.ID, newUser.Username, newUser.Email, newUser.PasswordHash, newUser.CreatedAt, newUser.UpdatedAt)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create user"})
+	// The unique indexes reject duplicates, even between concurrent signups
+	err = h.Users.CreateUser(c.Request.Context(), newUser)
+	var conflict *store.ConflictError
+	if errors.As(err, &conflict) {
+		h.record(c, audit.ActionUserCreate, models.AuditFailure, models.User{}, map[string]string{
+			"email": newUser.Email, "username": newUser.Username, "reason": conflict.Field + " taken"})
+		problem.Error(c, conflict)
+		return
+	} else if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to create user"))
 		return
 	}
 
+	// The new user is their own actor, signups are not authenticated
+	event := auditEvent(c, audit.ActionUserCreate, models.AuditSuccess)
+	event.ActorID = newUser.ID.String()
+	event.Actor = newUser.Username
+	event.TargetType = "user"
+	event.TargetID = newUser.ID.String()
+	event.Details = map[string]string{"email": newUser.Email, "username": newUser.Username}
+	h.Audit.Record(c.Request.Context(), event)
+
 	c.JSON(http.StatusOK, gin.H{"message": "User created successfully", "user": newUser})
 }
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"avidlogic/models"
+	"avidlogic/store"
+)
+
+func TestCreateUserConflict(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice", "alice@example.com", "correct horse battery")
+
+	tests := []struct {
+		name  string
+		input CreateUserInput
+		field string
+	}{
+		{"same email", CreateUserInput{Username: "alice2", Email: "alice@example.com", Password: "correct horse battery"}, "email"},
+		{"email differing by case", CreateUserInput{Username: "alice2", Email: "Alice@Example.com", Password: "correct horse battery"}, "email"},
+		{"username differing by case", CreateUserInput{Username: "ALICE", Email: "other@example.com", Password: "correct horse battery"}, "username"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res problemCode
+			status := s.do(http.MethodPost, "/v1/users", "", tt.input, &res)
+			if status != http.StatusConflict || res.Code != "conflict" {
+				t.Fatalf("got %d %q, want 409 conflict", status, res.Code)
+			}
+			if len(res.Errors) != 1 || res.Errors[0].Field != tt.field {
+				t.Errorf("got field errors %+v, want %s", res.Errors, tt.field)
+			}
+		})
+	}
+}
+
+func TestCreateUserAfterDeletion(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "alice@example.com", "correct horse battery")
+	if status := s.do(http.MethodDelete, "/v1/me", token, DeleteAccountInput{Password: "correct horse battery"}, nil); status != http.StatusOK {
+		t.Fatalf("deleting the account: status %d", status)
+	}
+
+	// A deleted account frees its username and email until it is purged
+	s.signup("alice", "alice@example.com", "another correct horse")
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice", "alice@example.com", "correct horse battery")
+
+	tests := []struct {
+		name     string
+		input    LoginInput
+		wantCode string
+	}{
+		{"valid", LoginInput{Email: "alice@example.com", Password: "correct horse battery"}, ""},
+		{"email in another case", LoginInput{Email: "ALICE@example.com", Password: "correct horse battery"}, ""},
+		{"wrong password", LoginInput{Email: "alice@example.com", Password: "wrong horse battery"}, "invalid_credentials"},
+		{"unknown email", LoginInput{Email: "bob@example.com", Password: "correct horse battery"}, "invalid_credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res struct {
+				Token string
+				Code  string
+			}
+			status := s.do(http.MethodPost, "/v1/sessions", "", tt.input, &res)
+			if tt.wantCode == "" {
+				if status != http.StatusOK || res.Token == "" {
+					t.Fatalf("got %d with token %q, want 200 with a token", status, res.Token)
+				}
+				return
+			}
+			if status != http.StatusUnauthorized || res.Code != tt.wantCode {
+				t.Errorf("got %d %q, want 401 %s", status, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+// unavailableUsers fails every email lookup as a database outage would
+type unavailableUsers struct {
+	store.UserStore
+}
+
+func (unavailableUsers) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return models.User{}, errors.New("connection refused")
+}
+
+func TestLoginStoreError(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice", "alice@example.com", "correct horse battery")
+	s.users.Users = unavailableUsers{s.store}
+
+	// An outage is not a wrong password
+	var res problemCode
+	status := s.do(http.MethodPost, "/v1/sessions", "", LoginInput{Email: "alice@example.com", Password: "correct horse battery"}, &res)
+	if status != http.StatusInternalServerError || res.Code != "internal_error" {
+		t.Errorf("got %d %q, want 500 internal_error", status, res.Code)
+	}
+}
+
+func TestGetMe(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "Alice@Example.com", "correct horse battery")
+
+	var user models.User
+	if status := s.do(http.MethodGet, "/v1/me", token, nil, &user); status != http.StatusOK {
+		t.Fatalf("got %d, want 200", status)
+	}
+	if user.Username != "alice" || user.Email != "alice@example.com" {
+		t.Errorf("got %s <%s>, want alice <alice@example.com>", user.Username, user.Email)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode string
+	}{
+		{"without token", "", "unauthenticated"},
+		{"with invalid token", "not-a-jwt", "invalid_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res problemCode
+			if status := s.do(http.MethodGet, "/v1/me", tt.token, nil, &res); status != http.StatusUnauthorized || res.Code != tt.wantCode {
+				t.Errorf("got %d %q, want 401 %s", status, res.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"avidlogic/analytics"
+	"avidlogic/models"
+	"avidlogic/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkflowSyncResponse is returned after ingesting workflow runs
+type WorkflowSyncResponse struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	Runs  int       `json:"runs"`
+	Jobs  int       `json:"jobs"`
+	// TruncatedRepos had more runs in the range than one sync ingests; only
+	// their most recent runs were stored
+	TruncatedRepos []string `json:"truncated_repos,omitempty"`
+}
+
+// WorkflowStatsResponse is returned by the workflow stats endpoint
+type WorkflowStatsResponse struct {
+	From      time.Time                 `json:"from"`
+	To        time.Time                 `json:"to"`
+	Workflows []analytics.WorkflowStats `json:"workflows"`
+}
+
+// WorkflowTrendResponse is returned by the workflow trend endpoint
+type WorkflowTrendResponse struct {
+	WorkflowID int64                          `json:"workflow_id"`
+	Interval   string                         `json:"interval"`
+	From       time.Time                      `json:"from"`
+	To         time.Time                      `json:"to"`
+	Points     []analytics.WorkflowTrendPoint `json:"points"`
+}
+
+// FlakyJobsResponse is returned by the flaky job detector
+type FlakyJobsResponse struct {
+	From time.Time            `json:"from"`
+	To   time.Time            `json:"to"`
+	Jobs []analytics.FlakyJob `json:"jobs"`
+}
+
+// loadWorkflowData loads the stored runs and jobs of the project for the requested date range
+func (h *ProjectHandler) loadWorkflowData(c *gin.Context) ([]models.WorkflowRun, []models.WorkflowJob, time.Time, time.Time, bool) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return nil, nil, time.Time{}, time.Time{}, false
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return nil, nil, time.Time{}, time.Time{}, false
+	}
+
+	runs, err := h.Workflows.ListWorkflowRuns(c.Request.Context(), project.ID, from, to)
+	if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to load workflow runs"))
+		return nil, nil, time.Time{}, time.Time{}, false
+	}
+
+	jobs, err := h.Workflows.ListWorkflowJobs(c.Request.Context(), project.ID, from, to)
+	if err != nil {
+		problem.Abort(c, problem.Internal.New("Failed to load workflow jobs"))
+		return nil, nil, time.Time{}, time.Time{}, false
+	}
+
+	return runs, jobs, from, to, true
+}
+
+// SyncWorkflowRuns ingests GitHub Actions workflow runs and jobs for a project
+// @Summary Ingest GitHub Actions runs
+// @Description Fetches the workflow runs created between 'from' and 'to' (and the jobs of every run attempt) for each repository in the project and stores them. Already stored runs are updated. At most 200 runs per repository are ingested per call, the most recent first; repositories with more are listed in truncated_repos, and their older runs can be ingested by syncing again with an earlier 'to'.
+// @Tags Workflows
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param from query string false "Ingest runs created since this date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "Ingest runs created until this date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Success 200 {object} WorkflowSyncResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Failure 502 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/workflows/sync [post]
+func (h *ProjectHandler) SyncWorkflowRuns(c *gin.Context) {
+	project, ok := h.loadGitHubProject(c)
+	if !ok {
+		return
+	}
+
+	since, until, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	batch, err := analytics.CollectWorkflowRuns(c.Request.Context(), h.GitHubClient(project), project.ID, project.Username, project.Repos(), since, until)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "fetching workflow runs", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Upstream.New("Failed to fetch workflow runs from GitHub"))
+		return
+	}
+
+	if err := h.Workflows.SaveWorkflowRuns(c.Request.Context(), batch.Runs, batch.Jobs); err != nil {
+		slog.ErrorContext(c.Request.Context(), "saving workflow runs", "project_id", project.ID, "err", err)
+		problem.Abort(c, problem.Internal.New("Failed to save workflow runs"))
+		return
+	}
+
+	c.JSON(http.StatusOK, WorkflowSyncResponse{
+		Since:          since,
+		Until:          until,
+		Runs:           len(batch.Runs),
+		Jobs:           len(batch.Jobs),
+		TruncatedRepos: batch.Truncated,
+	})
+}
+
+// GetWorkflowStats summarizes the stored workflow runs of a project
+// @Summary Workflow pass rates and durations
+// @Description Pass rate, median and p90 duration, median job queue time and flakiest jobs for each workflow, computed from ingested runs created in the date range.
+// @Tags Workflows
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Success 200 {object} WorkflowStatsResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/workflows [get]
+func (h *ProjectHandler) GetWorkflowStats(c *gin.Context) {
+	runs, jobs, from, to, ok := h.loadWorkflowData(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, WorkflowStatsResponse{
+		From:      from,
+		To:        to,
+		Workflows: analytics.WorkflowSummaries(runs, jobs),
+	})
+}
+
+// GetWorkflowTrend returns the daily or weekly trend of one workflow
+// @Summary Workflow trend
+// @Description Runs, pass rate, median duration and median queue time of a workflow per day or week.
+// @Tags Workflows
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param workflow_id path int true "GitHub workflow ID"
+// @Param interval query string false "Bucket size: day (default) or week"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Success 200 {object} WorkflowTrendResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/workflows/{workflow_id}/trends [get]
+func (h *ProjectHandler) GetWorkflowTrend(c *gin.Context) {
+	workflowID, err := strconv.ParseInt(c.Param("workflow_id"), 10, 64)
+	if err != nil {
+		problem.Abort(c, problem.InvalidParameter.New("Invalid workflow ID"))
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "day")
+	if interval != "day" && interval != "week" {
+		problem.Abort(c, problem.InvalidParameter.New("Invalid interval, expected 'day' or 'week'"))
+		return
+	}
+
+	runs, jobs, from, to, ok := h.loadWorkflowData(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, WorkflowTrendResponse{
+		WorkflowID: workflowID,
+		Interval:   interval,
+		From:       from,
+		To:         to,
+		Points:     analytics.WorkflowTrend(runs, jobs, workflowID, interval),
+	})
+}
+
+// GetFlakyJobs lists jobs that failed and then passed on the same commit
+// @Summary Flaky job detector
+// @Description Jobs that failed and later passed on the same commit (for example after a re-run), computed from ingested runs created in the date range. Most recent first.
+// @Tags Workflows
+// @Produce json,application/problem+json
+// @Param id path int true "Project ID"
+// @Param from query string false "Start date (YYYY-MM-DD or RFC 3339), defaults to 30 days before 'to'"
+// @Param to query string false "End date (YYYY-MM-DD or RFC 3339), defaults to now"
+// @Success 200 {object} FlakyJobsResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Security BearerAuth
+// @Router /v1/projects/{id}/workflows/flaky [get]
+func (h *ProjectHandler) GetFlakyJobs(c *gin.Context) {
+	runs, jobs, from, to, ok := h.loadWorkflowData(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, FlakyJobsResponse{
+		From: from,
+		To:   to,
+		Jobs: analytics.FlakyJobs(runs, jobs),
+	})
+}
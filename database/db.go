@@ -3,25 +3,104 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"time"
+
+	"avidlogic/config"
 
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-var DB *pgx.Conn
+var DB *pgxpool.Pool
+
+// ConnectDB initializes the connection pool to PostgreSQL. The pool settings
+// of cfg take precedence over pool_* parameters in its URL.
+func ConnectDB(cfg config.Database) error {
+	poolCfg, err := poolConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
 
-// ConnectDB initializes the connection to PostgreSQL
-func ConnectDB() {
-	var err error
-	DB, err = pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	DB, err = pgxpool.ConnectConfig(context.Background(), poolCfg)
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v\n", err)
+		return fmt.Errorf("unable to connect to database: %w", err)
+	}
+	slog.Info("connected to the database")
+	return nil
+}
+
+// poolConfig parses the connection string and applies the pool settings that are set
+func poolConfig(cfg config.Database) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolCfg.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
 	}
-	fmt.Println("Connected to the database successfully")
+	if cfg.HealthCheckPeriod > 0 {
+		poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+	if cfg.ConnectTimeout > 0 {
+		poolCfg.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	}
+	if cfg.TraceQueries {
+		poolCfg.ConnConfig.Logger = queryTracer{}
+		poolCfg.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
+
+	if poolCfg.MinConns > poolCfg.MaxConns {
+		return nil, fmt.Errorf("DB_MIN_CONNS (%d) exceeds DB_MAX_CONNS (%d)", poolCfg.MinConns, poolCfg.MaxConns)
+	}
+	return poolCfg, nil
+}
+
+// PoolStats is a snapshot of the connection pool usage
+type PoolStats struct {
+	MaxConns             int32
+	TotalConns           int32
+	AcquiredConns        int32
+	IdleConns            int32
+	ConstructingConns    int32
+	AcquireCount         int64
+	EmptyAcquireCount    int64 // acquires that had to wait for a connection
+	CanceledAcquireCount int64
+	AcquireDuration      time.Duration // total time spent waiting for connections
+}
+
+// Stats returns the current connection pool statistics
+func Stats() PoolStats {
+	s := DB.Stat()
+	return PoolStats{
+		MaxConns:             s.MaxConns(),
+		TotalConns:           s.TotalConns(),
+		AcquiredConns:        s.AcquiredConns(),
+		IdleConns:            s.IdleConns(),
+		ConstructingConns:    s.ConstructingConns(),
+		AcquireCount:         s.AcquireCount(),
+		EmptyAcquireCount:    s.EmptyAcquireCount(),
+		CanceledAcquireCount: s.CanceledAcquireCount(),
+		AcquireDuration:      s.AcquireDuration(),
+	}
+}
+
+// Ping checks that a connection to the database can be acquired and used
+func Ping(ctx context.Context) error {
+	return DB.Ping(ctx)
 }
 
-// CloseDB closes the database connection
+// CloseDB closes all connections of the pool
 func CloseDB() {
-	DB.Close(context.Background())
+	DB.Close()
 }
@@ -0,0 +1,47 @@
+package database
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	poolConnsDesc = prometheus.NewDesc("db_pool_connections", "Connections of the pool, by state.",
+		[]string{"state"}, nil)
+	poolMaxConnsDesc      = prometheus.NewDesc("db_pool_max_connections", "Maximum size of the pool.", nil, nil)
+	poolAcquiresDesc      = prometheus.NewDesc("db_pool_acquires_total", "Successful connection acquires.", nil, nil)
+	poolEmptyAcquiresDesc = prometheus.NewDesc("db_pool_empty_acquires_total",
+		"Successful acquires that had to wait for a connection.", nil, nil)
+	poolCanceledAcquiresDesc = prometheus.NewDesc("db_pool_canceled_acquires_total",
+		"Acquires canceled before a connection was available.", nil, nil)
+	poolAcquireWaitDesc = prometheus.NewDesc("db_pool_acquire_duration_seconds_total",
+		"Total time spent acquiring connections.", nil, nil)
+)
+
+// PoolCollector exports the connection pool statistics to Prometheus
+type PoolCollector struct{}
+
+// Describe implements prometheus.Collector
+func (PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolConnsDesc
+	ch <- poolMaxConnsDesc
+	ch <- poolAcquiresDesc
+	ch <- poolEmptyAcquiresDesc
+	ch <- poolCanceledAcquiresDesc
+	ch <- poolAcquireWaitDesc
+}
+
+// Collect implements prometheus.Collector
+func (PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	if DB == nil {
+		return
+	}
+	s := Stats()
+
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(s.AcquiredConns), "acquired")
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(s.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(s.ConstructingConns), "constructing")
+	ch <- prometheus.MustNewConstMetric(poolMaxConnsDesc, prometheus.GaugeValue, float64(s.MaxConns))
+
+	ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue, float64(s.AcquireCount))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue, float64(s.EmptyAcquireCount))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquiresDesc, prometheus.CounterValue, float64(s.CanceledAcquireCount))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWaitDesc, prometheus.CounterValue, s.AcquireDuration.Seconds())
+}
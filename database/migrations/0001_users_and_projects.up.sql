@@ -1,4 +1,8 @@
-CREATE TABLE users (
+-- Tables used to be created by hand from scripts.sql, so this migration only
+-- creates what is missing and can adopt an existing database.
+CREATE EXTENSION IF NOT EXISTS "pgcrypto";
+
+CREATE TABLE IF NOT EXISTS users (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     username VARCHAR(255) UNIQUE NOT NULL,
     email VARCHAR(255) UNIQUE NOT NULL,
@@ -7,14 +11,16 @@ CREATE TABLE users (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE EXTENSION IF NOT EXISTS "pgcrypto";
-
-CREATE TABLE user_projects (
+CREATE TABLE IF NOT EXISTS user_projects (
     id SERIAL PRIMARY KEY,
     user_id UUID REFERENCES users(id),
-    project_type VARCHAR(50) NOT NULL, -- 'personal' or 'org'
+    project_type VARCHAR(50) NOT NULL, -- 'personal' or 'org' (GitLab group, Bitbucket workspace)
     username VARCHAR(100) NOT NULL,
     pat TEXT NOT NULL, -- Store encrypted PAT
     repo_names TEXT NOT NULL, -- Comma-separated repo names
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
+
+ALTER TABLE user_projects
+    ADD COLUMN IF NOT EXISTS provider VARCHAR(20) NOT NULL DEFAULT 'github', -- 'github', 'gitlab', 'bitbucket' or 'gitea'
+    ADD COLUMN IF NOT EXISTS base_url TEXT; -- self-hosted instance, NULL for the public service
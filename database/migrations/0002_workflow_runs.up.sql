@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id BIGINT NOT NULL, -- GitHub run ID
+    project_id INTEGER NOT NULL REFERENCES user_projects(id) ON DELETE CASCADE,
+    repo_name VARCHAR(255) NOT NULL,
+    workflow_id BIGINT NOT NULL,
+    workflow_name VARCHAR(255) NOT NULL,
+    head_sha VARCHAR(40) NOT NULL,
+    head_branch VARCHAR(255),
+    event VARCHAR(50),
+    status VARCHAR(50) NOT NULL,
+    conclusion VARCHAR(50),
+    run_attempt INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL,
+    run_started_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (project_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS workflow_runs_project_created_idx ON workflow_runs (project_id, created_at);
+
+CREATE TABLE IF NOT EXISTS workflow_jobs (
+    id BIGINT NOT NULL, -- GitHub job ID
+    project_id INTEGER NOT NULL,
+    run_id BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    head_sha VARCHAR(40) NOT NULL,
+    run_attempt INTEGER NOT NULL DEFAULT 1,
+    status VARCHAR(50) NOT NULL,
+    conclusion VARCHAR(50),
+    created_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    PRIMARY KEY (project_id, id),
+    FOREIGN KEY (project_id, run_id) REFERENCES workflow_runs(project_id, id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    project_id INTEGER NOT NULL REFERENCES user_projects(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL, -- 'pull_requests', 'contributors', 'issues' or 'workflows'
+    format VARCHAR(10) NOT NULL, -- 'csv', 'jsonl' or 'pdf'
+    status VARCHAR(20) NOT NULL, -- 'pending', 'running', 'completed' or 'failed'
+    error TEXT,
+    from_date TIMESTAMP NOT NULL,
+    to_date TIMESTAMP NOT NULL,
+    content_type VARCHAR(100),
+    artifact BYTEA,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
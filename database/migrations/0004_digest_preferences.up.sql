@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_digest_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    channel VARCHAR(20) NOT NULL DEFAULT 'email', -- 'email' or 'webhook'
+    webhook_url TEXT,
+    weekday SMALLINT NOT NULL DEFAULT 1, -- 0 = Sunday, UTC
+    hour SMALLINT NOT NULL DEFAULT 8, -- UTC
+    last_sent_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
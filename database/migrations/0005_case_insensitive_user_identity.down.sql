@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_username_lower_key;
+DROP INDEX IF EXISTS users_email_lower_key;
@@ -0,0 +1,6 @@
+-- Emails are stored normalized from now on; fold the existing ones the same way.
+-- This fails if two accounts only differ by case, which must be resolved by hand.
+UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+
+CREATE UNIQUE INDEX users_email_lower_key ON users (LOWER(email));
+CREATE UNIQUE INDEX users_username_lower_key ON users (LOWER(username));
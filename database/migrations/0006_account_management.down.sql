@@ -0,0 +1,12 @@
+ALTER TABLE user_projects
+    DROP CONSTRAINT IF EXISTS user_projects_user_id_fkey,
+    ADD CONSTRAINT user_projects_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+
+DROP INDEX IF EXISTS users_email_verification_hash_key;
+
+ALTER TABLE users
+    DROP COLUMN email_verification_expires_at,
+    DROP COLUMN email_verification_hash,
+    DROP COLUMN pending_email,
+    DROP COLUMN disabled_at,
+    DROP COLUMN is_admin;
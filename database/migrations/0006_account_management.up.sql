@@ -0,0 +1,14 @@
+-- Grant administrator rights with: UPDATE users SET is_admin = TRUE WHERE email = '...';
+ALTER TABLE users
+    ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN disabled_at TIMESTAMP,
+    ADD COLUMN pending_email VARCHAR(255), -- new address awaiting verification
+    ADD COLUMN email_verification_hash CHAR(64), -- SHA-256 of the emailed token
+    ADD COLUMN email_verification_expires_at TIMESTAMP;
+
+CREATE UNIQUE INDEX users_email_verification_hash_key ON users (email_verification_hash);
+
+-- Deleting an account removes its projects (and, through them, their workflow runs and reports)
+ALTER TABLE user_projects
+    DROP CONSTRAINT IF EXISTS user_projects_user_id_fkey,
+    ADD CONSTRAINT user_projects_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
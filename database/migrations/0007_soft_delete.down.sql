@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS user_projects_deleted_at_idx;
+DROP INDEX IF EXISTS users_deleted_at_idx;
+
+ALTER TABLE user_projects DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
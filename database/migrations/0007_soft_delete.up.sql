@@ -0,0 +1,6 @@
+-- Deleted accounts and projects are hidden at once and purged after the retention window
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE user_projects ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX user_projects_deleted_at_idx ON user_projects (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,33 @@
+-- Security-relevant events. Each row carries the SHA-256 of its content chained
+-- to the previous row's hash, so edits and deletions are detectable.
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP NOT NULL,
+    actor_id UUID, -- no foreign key: events outlive purged accounts
+    actor VARCHAR(255) NOT NULL DEFAULT '', -- username or attempted email
+    action VARCHAR(100) NOT NULL, -- e.g. 'user.login', 'project.create'
+    target_type VARCHAR(50) NOT NULL DEFAULT '',
+    target_id VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    outcome VARCHAR(20) NOT NULL, -- 'success' or 'failure'
+    details JSONB NOT NULL DEFAULT '{}',
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_events_actor_idx ON audit_events (actor_id, occurred_at);
+CREATE INDEX audit_events_action_idx ON audit_events (action, occurred_at);
+CREATE INDEX audit_events_target_idx ON audit_events (target_type, target_id);
+
+-- The log is append-only
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,10 @@
+-- Token buckets of the rate limiter, shared by every server instance. A bucket
+-- past expires_at is full and can be dropped.
+CREATE TABLE rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY, -- policy name and client, e.g. 'login:ip:203.0.113.7'
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_expires_idx ON rate_limit_buckets (expires_at);
@@ -0,0 +1,11 @@
+-- This fails while a deleted account shares its username or email with a live
+-- one, until the deleted account is purged.
+DROP INDEX IF EXISTS users_email_lower_key;
+DROP INDEX IF EXISTS users_username_lower_key;
+
+CREATE UNIQUE INDEX users_email_lower_key ON users (LOWER(email));
+CREATE UNIQUE INDEX users_username_lower_key ON users (LOWER(username));
+
+ALTER TABLE users
+    ADD CONSTRAINT users_username_key UNIQUE (username),
+    ADD CONSTRAINT users_email_key UNIQUE (email);
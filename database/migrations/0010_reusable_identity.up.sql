@@ -0,0 +1,11 @@
+-- Deleted accounts keep their row until they are purged. Only live accounts
+-- need a unique username and email, so that a deleted one can sign up again.
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_username_key,
+    DROP CONSTRAINT IF EXISTS users_email_key;
+
+DROP INDEX IF EXISTS users_email_lower_key;
+DROP INDEX IF EXISTS users_username_lower_key;
+
+CREATE UNIQUE INDEX users_email_lower_key ON users (LOWER(email)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_username_lower_key ON users (LOWER(username)) WHERE deleted_at IS NULL;
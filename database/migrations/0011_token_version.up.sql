@@ -0,0 +1,4 @@
+-- Tokens carry the version of their account at login; bumping it revokes
+-- every token issued before, e.g. on a password change
+ALTER TABLE users
+    ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- The server generating a report refreshes heartbeat_at while it holds the
+-- report, so that any server can tell a report left behind by a crashed one
+-- from a report another running server is still generating
+ALTER TABLE reports
+    ADD COLUMN heartbeat_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
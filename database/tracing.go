@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("avidlogic/database")
+
+// queryTracer records a span for every query. pgx v4 has no tracing hooks,
+// but it logs each query with its context and duration once it completes,
+// so the span is recorded after the fact with the original start time.
+// Arguments are left out as they may hold passwords and tokens.
+type queryTracer struct{}
+
+// Log implements pgx.Logger
+func (queryTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if msg != "Query" && msg != "Exec" && msg != "SendBatch" {
+		return
+	}
+	elapsed, _ := data["time"].(time.Duration)
+	end := time.Now()
+	sql, _ := data["sql"].(string)
+
+	operation := msg
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	_, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(end.Add(-elapsed)),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", sql),
+		))
+	if err, ok := data["err"].(error); ok && err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
@@ -0,0 +1,257 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"avidlogic/analytics"
+	"avidlogic/github"
+	"avidlogic/models"
+	"avidlogic/store"
+)
+
+// Period is the time window covered by a digest
+const Period = 7 * 24 * time.Hour
+
+// stuckAfter is how long an open pull request may wait without any review before it is reported as stuck
+const stuckAfter = 72 * time.Hour
+
+// patExpiryWarning is how close to its expiration a PAT is flagged
+const patExpiryWarning = 14 * 24 * time.Hour
+
+// PullRequestSummary is a pull request listed in a digest
+type PullRequestSummary struct {
+	Repo    string  `json:"repo"`
+	Number  int     `json:"number"`
+	Title   string  `json:"title"`
+	Author  string  `json:"author"`
+	URL     string  `json:"url"`
+	AgeDays float64 `json:"age_days"`
+}
+
+// IssueSummary is an issue listed in a digest
+type IssueSummary struct {
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+}
+
+// WorkflowFailure is a workflow that failed at least once during the period
+type WorkflowFailure struct {
+	Repo     string  `json:"repo"`
+	Workflow string  `json:"workflow"`
+	Runs     int     `json:"runs"`
+	Failed   int     `json:"failed"`
+	PassRate float64 `json:"pass_rate"`
+}
+
+// PATHealth reports whether the project's PAT still works and when it expires
+type PATHealth struct {
+	Valid              bool       `json:"valid"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	RateLimitRemaining int        `json:"rate_limit_remaining"`
+	Warning            string     `json:"warning,omitempty"`
+}
+
+// Digest is the weekly activity summary of one project
+type Digest struct {
+	ProjectID          int                  `json:"project_id"`
+	Owner              string               `json:"owner"`
+	Repos              []string             `json:"repos"`
+	From               time.Time            `json:"from"`
+	To                 time.Time            `json:"to"`
+	MergedPullRequests []PullRequestSummary `json:"merged_pull_requests"`
+	StuckPullRequests  []PullRequestSummary `json:"stuck_pull_requests"`
+	NewIssues          []IssueSummary       `json:"new_issues"`
+	FailingWorkflows   []WorkflowFailure    `json:"failing_workflows"`
+	PAT                PATHealth            `json:"pat"`
+}
+
+// Compile gathers the activity of a project during the Period ending at now,
+// from GitHub through client and from the runs ingested in workflows. GitHub
+// sections are left empty when the project's PAT is no longer valid.
+func Compile(ctx context.Context, client *github.Client, workflows store.WorkflowStore, project models.UserProject, now time.Time) (*Digest, error) {
+	d := &Digest{
+		ProjectID:          project.ID,
+		Owner:              project.Username,
+		Repos:              project.Repos(),
+		From:               now.Add(-Period),
+		To:                 now,
+		MergedPullRequests: []PullRequestSummary{},
+		StuckPullRequests:  []PullRequestSummary{},
+		NewIssues:          []IssueSummary{},
+		FailingWorkflows:   []WorkflowFailure{},
+	}
+
+	token, err := client.TokenStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.PAT = patHealth(token, now)
+
+	if token.Valid {
+		for _, repo := range d.Repos {
+			if err := d.addPullRequests(ctx, client, repo, now); err != nil {
+				return nil, err
+			}
+		}
+
+		issues, _, err := analytics.CollectIssues(ctx, client, d.Owner, d.Repos, "all", d.From)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			if !issue.CreatedAt.Before(d.From) {
+				d.NewIssues = append(d.NewIssues, IssueSummary{Repo: issue.Repo, Number: issue.Number, Title: issue.Title, URL: issue.HTMLURL})
+			}
+		}
+	}
+
+	// Workflow failures come from ingested runs, so they are reported even when the PAT is broken
+	runs, err := workflows.ListWorkflowRuns(ctx, project.ID, d.From, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, stats := range analytics.WorkflowSummaries(runs, nil) {
+		if stats.Failed > 0 {
+			d.FailingWorkflows = append(d.FailingWorkflows, WorkflowFailure{
+				Repo: stats.Repo, Workflow: stats.Workflow, Runs: stats.Runs, Failed: stats.Failed, PassRate: stats.PassRate,
+			})
+		}
+	}
+
+	return d, nil
+}
+
+// addPullRequests collects the pull requests merged during the period and the
+// open ones that have been waiting for a first review for longer than stuckAfter.
+func (d *Digest) addPullRequests(ctx context.Context, client *github.Client, repo string, now time.Time) error {
+	updated, err := client.ListUpdatedPullRequests(ctx, d.Owner, repo, d.From)
+	if err != nil {
+		return err
+	}
+	for _, pr := range updated {
+		if pr.MergedAt != nil && !pr.MergedAt.Before(d.From) {
+			d.MergedPullRequests = append(d.MergedPullRequests, summarize(repo, pr, now))
+		}
+	}
+
+	open, err := client.ListOpenPullRequests(ctx, d.Owner, repo)
+	if err != nil {
+		return err
+	}
+	var waiting []github.PullRequest
+	for _, pr := range open {
+		if !pr.Draft && now.Sub(pr.CreatedAt) >= stuckAfter {
+			waiting = append(waiting, pr)
+		}
+	}
+	// Every pull request costs a request for its reviews, so only the oldest are checked
+	if len(waiting) > analytics.PullRequestLimit {
+		slog.WarnContext(ctx, "too many open pull requests, only the oldest are checked for reviews",
+			"repo", d.Owner+"/"+repo, "limit", analytics.PullRequestLimit)
+		waiting = waiting[:analytics.PullRequestLimit]
+	}
+
+	stuck := make([]bool, len(waiting))
+	err = analytics.ForEach(ctx, len(waiting), func(ctx context.Context, i int) error {
+		reviews, err := client.ListReviews(ctx, d.Owner, repo, waiting[i].Number)
+		if err != nil {
+			return err
+		}
+		stuck[i] = !hasReview(waiting[i], reviews)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, pr := range waiting {
+		if stuck[i] {
+			d.StuckPullRequests = append(d.StuckPullRequests, summarize(repo, pr, now))
+		}
+	}
+
+	sort.Slice(d.StuckPullRequests, func(i, j int) bool { return d.StuckPullRequests[i].AgeDays > d.StuckPullRequests[j].AgeDays })
+	return nil
+}
+
+func hasReview(pr github.PullRequest, reviews []github.Review) bool {
+	for _, review := range reviews {
+		if review.State != "PENDING" && review.User.Login != pr.User.Login {
+			return true
+		}
+	}
+	return false
+}
+
+func summarize(repo string, pr github.PullRequest, now time.Time) PullRequestSummary {
+	return PullRequestSummary{
+		Repo:    repo,
+		Number:  pr.Number,
+		Title:   pr.Title,
+		Author:  pr.User.Login,
+		URL:     pr.HTMLURL,
+		AgeDays: math.Round(now.Sub(pr.CreatedAt).Hours()/24*10) / 10,
+	}
+}
+
+func patHealth(token *github.TokenStatus, now time.Time) PATHealth {
+	health := PATHealth{Valid: token.Valid, ExpiresAt: token.ExpiresAt, RateLimitRemaining: token.RateLimitRemaining}
+	switch {
+	case !token.Valid:
+		health.Warning = "The PAT was rejected by GitHub; replace it to keep analytics working"
+	case token.ExpiresAt != nil && token.ExpiresAt.Before(now):
+		health.Warning = "The PAT has expired"
+	case token.ExpiresAt != nil && token.ExpiresAt.Sub(now) < patExpiryWarning:
+		health.Warning = fmt.Sprintf("The PAT expires in %d days", int(token.ExpiresAt.Sub(now).Hours()/24))
+	}
+	return health
+}
+
+// Subject returns the subject line of a message carrying the given digests
+func Subject(digests []*Digest, now time.Time) string {
+	return fmt.Sprintf("AvidLogic weekly digest (%d projects) - week of %s", len(digests), now.Add(-Period).Format("2006-01-02"))
+}
+
+// Text renders a plain-text version of the digests, suitable for email bodies and chat webhooks
+func Text(digests []*Digest) string {
+	var b strings.Builder
+	for i, d := range digests {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "== %s (%s) ==\n", d.Owner, strings.Join(d.Repos, ", "))
+		fmt.Fprintf(&b, "%s to %s\n\n", d.From.Format("2006-01-02"), d.To.Format("2006-01-02"))
+
+		if d.PAT.Warning != "" {
+			fmt.Fprintf(&b, "! %s\n\n", d.PAT.Warning)
+		}
+
+		fmt.Fprintf(&b, "Merged pull requests: %d\n", len(d.MergedPullRequests))
+		for _, pr := range d.MergedPullRequests {
+			fmt.Fprintf(&b, "  - %s#%d %s (@%s)\n", pr.Repo, pr.Number, pr.Title, pr.Author)
+		}
+
+		fmt.Fprintf(&b, "Pull requests waiting for review: %d\n", len(d.StuckPullRequests))
+		for _, pr := range d.StuckPullRequests {
+			fmt.Fprintf(&b, "  - %s#%d %s (@%s, %.1f days)\n", pr.Repo, pr.Number, pr.Title, pr.Author, pr.AgeDays)
+		}
+
+		fmt.Fprintf(&b, "New issues: %d\n", len(d.NewIssues))
+		for _, issue := range d.NewIssues {
+			fmt.Fprintf(&b, "  - %s#%d %s\n", issue.Repo, issue.Number, issue.Title)
+		}
+
+		fmt.Fprintf(&b, "Failing workflows: %d\n", len(d.FailingWorkflows))
+		for _, wf := range d.FailingWorkflows {
+			fmt.Fprintf(&b, "  - %s / %s: %d of %d runs failed\n", wf.Repo, wf.Workflow, wf.Failed, wf.Runs)
+		}
+	}
+	return b.String()
+}
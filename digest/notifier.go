@@ -0,0 +1,97 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"avidlogic/mail"
+	"avidlogic/netguard"
+)
+
+// Delivery channels a user can choose in their digest preference
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Recipient is where a digest is delivered
+type Recipient struct {
+	Username   string
+	Email      string
+	WebhookURL string
+}
+
+// Message is a compiled digest ready to be delivered
+type Message struct {
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	Digests []*Digest `json:"digests"`
+}
+
+// Notifier delivers digest messages over one channel
+type Notifier interface {
+	Notify(ctx context.Context, to Recipient, msg Message) error
+}
+
+// SMTPNotifier sends digests as plain-text email
+type SMTPNotifier struct {
+	mail.SMTPSender
+}
+
+// Notify sends msg to the recipient's email address
+func (n *SMTPNotifier) Notify(ctx context.Context, to Recipient, msg Message) error {
+	if to.Email == "" {
+		return errors.New("digest: recipient has no email address")
+	}
+	return n.Send(ctx, to.Email, msg.Subject, msg.Text)
+}
+
+// WebhookNotifier posts digests as JSON to the recipient's webhook URL. The
+// payload carries a top-level "text" field, so Slack-compatible incoming
+// webhooks display it as is, and the structured digests for other consumers.
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+// NewWebhookNotifier returns a WebhookNotifier with a bounded HTTP timeout
+// that only connects to the public https addresses guard allows
+func NewWebhookNotifier(guard *netguard.Guard) *WebhookNotifier {
+	return &WebhookNotifier{Client: guard.Client(15*time.Second, nil)}
+}
+
+// Notify posts msg to the recipient's webhook URL
+func (n *WebhookNotifier) Notify(ctx context.Context, to Recipient, msg Message) error {
+	if to.WebhookURL == "" {
+		return errors.New("digest: recipient has no webhook URL")
+	}
+
+	payload, err := json.Marshal(struct {
+		Message
+		Text string `json:"text"`
+	}{msg, "*" + msg.Subject + "*\n" + msg.Text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("digest: webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
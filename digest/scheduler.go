@@ -0,0 +1,126 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"avidlogic/health"
+	"avidlogic/metrics"
+	"avidlogic/providers"
+	"avidlogic/store"
+)
+
+// checkInterval is how often the scheduler looks for due digests. Digests are
+// scheduled by weekday and hour, so anything below an hour works.
+const checkInterval = 10 * time.Minute
+
+// resendGuard prevents a digest from being sent twice within the same scheduled hour
+const resendGuard = 24 * time.Hour
+
+// Scheduler delivers weekly digests to opted-in users at their chosen weekday and hour
+type Scheduler struct {
+	Projects  store.ProjectStore
+	Digests   store.DigestStore
+	Workflows store.WorkflowStore
+	Forges    providers.Forges
+	Notifiers map[string]Notifier // keyed by channel
+}
+
+// NewScheduler returns a scheduler reading preferences, projects and workflow
+// runs from the given stores, compiling digests from forges and delivering
+// through the given notifiers
+func NewScheduler(projects store.ProjectStore, digests store.DigestStore, workflows store.WorkflowStore,
+	forges providers.Forges, notifiers map[string]Notifier) *Scheduler {
+	return &Scheduler{Projects: projects, Digests: digests, Workflows: workflows, Forges: forges, Notifiers: notifiers}
+}
+
+// Run checks for due digests until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	health.Register("digest", 3*checkInterval)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx, time.Now())
+		health.Beat("digest")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce delivers every digest due at now. Digests are claimed before they
+// are sent, so that servers checking at the same time do not send one twice,
+// and released when they fail so that a later check retries them. Failures
+// are logged per user so one broken webhook does not block the others.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) {
+	start, failed := time.Now(), false
+	defer func() { metrics.Job("digest", start, failed) }()
+
+	recipients, err := s.Digests.ClaimDueDigests(ctx, now, resendGuard)
+	if err != nil {
+		slog.ErrorContext(ctx, "claiming due digests", "err", err)
+		failed = true
+		return
+	}
+
+	for _, r := range recipients {
+		to := Recipient{Username: r.Username, Email: r.Email, WebhookURL: r.Preference.WebhookURL}
+		if err := s.Send(ctx, r.Preference.UserID, r.Preference.Channel, to, now); err != nil {
+			slog.ErrorContext(ctx, "sending digest", "target_user_id", r.Preference.UserID, "err", err)
+			metrics.DigestDeliveries.WithLabelValues(metrics.Failure).Inc()
+			// Released even on shutdown, which may be what interrupted the send
+			if err := s.Digests.ReleaseDigest(context.WithoutCancel(ctx), r.Preference.UserID, now, r.Preference.LastSentAt); err != nil {
+				slog.ErrorContext(ctx, "releasing digest", "target_user_id", r.Preference.UserID, "err", err)
+			}
+			continue
+		}
+		metrics.DigestDeliveries.WithLabelValues(metrics.Success).Inc()
+	}
+}
+
+// Send compiles the digests of every project of a user and delivers them over channel
+func (s *Scheduler) Send(ctx context.Context, userID, channel string, to Recipient, now time.Time) error {
+	notifier, ok := s.Notifiers[channel]
+	if !ok {
+		return fmt.Errorf("channel %q is not configured", channel)
+	}
+
+	msg, err := s.Build(ctx, userID, now)
+	if err != nil {
+		return err
+	}
+	if len(msg.Digests) == 0 {
+		return nil
+	}
+
+	return notifier.Notify(ctx, to, msg)
+}
+
+// Build compiles a message with one digest per GitHub project of the user
+func (s *Scheduler) Build(ctx context.Context, userID string, now time.Time) (Message, error) {
+	projects, err := s.Projects.ListProjects(ctx, userID)
+	if err != nil {
+		return Message{}, err
+	}
+
+	digests := make([]*Digest, 0, len(projects))
+	for _, project := range projects {
+		// Digests are built from the GitHub API only
+		if project.Provider != providers.GitHub {
+			continue
+		}
+		d, err := Compile(ctx, s.Forges.GitHubClient(project), s.Workflows, project, now)
+		if err != nil {
+			return Message{}, fmt.Errorf("project %d: %w", project.ID, err)
+		}
+		digests = append(digests, d)
+	}
+
+	return Message{Subject: Subject(digests, now), Text: Text(digests), Digests: digests}, nil
+}
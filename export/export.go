@@ -0,0 +1,150 @@
+// Package export assembles the archive of everything stored about a user,
+// answering data-subject access requests.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"avidlogic/models"
+	"avidlogic/store"
+)
+
+// Source reads the records of a user stored besides their account, projects
+// and audit events. store.PostgresStore and store.MemoryStore implement it.
+type Source interface {
+	GetDigestPreference(ctx context.Context, userID string) (models.DigestPreference, error)
+	ListUserReports(ctx context.Context, userID string) ([]models.Report, error)
+	ListWorkflowRuns(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowRun, error)
+	ListWorkflowJobs(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowJob, error)
+}
+
+// Project is a project as exported, without its access token
+type Project struct {
+	ID          int       `json:"id"`
+	Provider    string    `json:"provider"`
+	BaseURL     string    `json:"base_url,omitempty"`
+	ProjectType string    `json:"project_type"`
+	Username    string    `json:"username"`
+	RepoNames   []string  `json:"repo_names"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// readme describes the archive layout
+const readme = `This archive contains the data AvidLogic holds about your account.
+
+profile.json             your account
+projects.json            your projects (access tokens are never exported)
+digest_preferences.json  your weekly digest settings, if you ever saved them
+reports.json             the reports you generated (metadata only)
+audit_events.json        security events of your account (logins, changes)
+workflow_runs.json       GitHub Actions runs ingested for your projects
+workflow_jobs.json       GitHub Actions jobs ingested for your projects
+`
+
+// Build returns a ZIP archive of the user's data. projects and events are the
+// user's projects and the audit events they were the actor of; the rest is
+// read from source.
+func Build(ctx context.Context, source Source, user models.User, projects []models.UserProject, events []models.AuditEvent, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	add := func(name string, value any) error {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("export: writing %s: %w", name, err)
+		}
+		return nil
+	}
+
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "README.txt", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte(readme)); err != nil {
+		return nil, err
+	}
+
+	if err := add("profile.json", user); err != nil {
+		return nil, err
+	}
+
+	exported := make([]Project, 0, len(projects))
+	runs := []models.WorkflowRun{}
+	jobs := []models.WorkflowJob{}
+	for _, p := range projects {
+		exported = append(exported, Project{
+			ID:          p.ID,
+			Provider:    p.Provider,
+			BaseURL:     p.BaseURL,
+			ProjectType: p.ProjectType,
+			Username:    p.Username,
+			RepoNames:   p.Repos(),
+			CreatedAt:   p.CreatedAt,
+		})
+
+		projectRuns, err := source.ListWorkflowRuns(ctx, p.ID, time.Time{}, now)
+		if err != nil {
+			return nil, fmt.Errorf("export: workflow runs of project %d: %w", p.ID, err)
+		}
+		runs = append(runs, projectRuns...)
+
+		projectJobs, err := source.ListWorkflowJobs(ctx, p.ID, time.Time{}, now)
+		if err != nil {
+			return nil, fmt.Errorf("export: workflow jobs of project %d: %w", p.ID, err)
+		}
+		jobs = append(jobs, projectJobs...)
+	}
+	if err := add("projects.json", exported); err != nil {
+		return nil, err
+	}
+
+	pref, err := source.GetDigestPreference(ctx, user.ID.String())
+	if err == nil {
+		if err := add("digest_preferences.json", pref); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("export: digest preferences: %w", err)
+	}
+
+	reports, err := source.ListUserReports(ctx, user.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("export: reports: %w", err)
+	}
+	if reports == nil {
+		reports = []models.Report{}
+	}
+	if err := add("reports.json", reports); err != nil {
+		return nil, err
+	}
+
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	if err := add("audit_events.json", events); err != nil {
+		return nil, err
+	}
+
+	if err := add("workflow_runs.json", runs); err != nil {
+		return nil, err
+	}
+	if err := add("workflow_jobs.json", jobs); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
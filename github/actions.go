@@ -0,0 +1,58 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// WorkflowRun is a GitHub Actions workflow run
+type WorkflowRun struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	WorkflowID   int64      `json:"workflow_id"`
+	HeadSHA      string     `json:"head_sha"`
+	HeadBranch   string     `json:"head_branch"`
+	Event        string     `json:"event"`
+	Status       string     `json:"status"`     // queued, in_progress or completed
+	Conclusion   string     `json:"conclusion"` // success, failure, cancelled, ... once completed
+	RunAttempt   int        `json:"run_attempt"`
+	HTMLURL      string     `json:"html_url"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RunStartedAt *time.Time `json:"run_started_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// WorkflowJob is a job of a workflow run attempt
+type WorkflowJob struct {
+	ID          int64      `json:"id"`
+	RunID       int64      `json:"run_id"`
+	Name        string     `json:"name"`
+	HeadSHA     string     `json:"head_sha"`
+	RunAttempt  int        `json:"run_attempt"`
+	Status      string     `json:"status"`
+	Conclusion  string     `json:"conclusion"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// ListWorkflowRuns returns the workflow runs of a repository created between
+// from and to, the most recent first. A positive limit caps how many are read.
+func (c *Client) ListWorkflowRuns(ctx context.Context, owner, repo string, from, to time.Time, limit int) ([]WorkflowRun, error) {
+	query := url.Values{}
+	query.Set("created", from.UTC().Format(time.RFC3339)+".."+to.UTC().Format(time.RFC3339))
+
+	path := fmt.Sprintf("/repos/%s/%s/actions/runs", url.PathEscape(owner), url.PathEscape(repo))
+	return listAllIn[WorkflowRun](ctx, c, path, query, "workflow_runs", limit)
+}
+
+// ListWorkflowJobs returns the jobs of every attempt of a workflow run
+func (c *Client) ListWorkflowJobs(ctx context.Context, owner, repo string, runID int64) ([]WorkflowJob, error) {
+	query := url.Values{}
+	query.Set("filter", "all")
+
+	path := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/jobs", url.PathEscape(owner), url.PathEscape(repo), runID)
+	return listAllIn[WorkflowJob](ctx, c, path, query, "jobs", 0)
+}
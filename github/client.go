@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"avidlogic/metrics"
+	"avidlogic/tracing"
+)
+
+// DefaultBaseURL is the public GitHub REST API endpoint
+const DefaultBaseURL = "https://api.github.com"
+
+// perPage is the maximum page size accepted by the GitHub REST API
+const perPage = "100"
+
+// Client is a small GitHub REST API client authenticated with a PAT
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// APIError is returned when GitHub answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Path       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github: %s returned %d: %s", e.Path, e.StatusCode, e.Message)
+}
+
+// NewClient creates a client for the given PAT against the API at baseURL,
+// such as GitHub Enterprise or a local fake server. An empty baseURL uses
+// DefaultBaseURL.
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second, Transport: tracing.Transport(metrics.GitHubTransport(nil))},
+	}
+}
+
+// do performs a GET request against an API path or an absolute URL and decodes the JSON body into out.
+// It returns the URL of the next page when the response is paginated.
+func (c *Client) do(ctx context.Context, pathOrURL string, query url.Values, out interface{}) (string, error) {
+	target := pathOrURL
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = c.BaseURL + pathOrURL
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return "", &APIError{StatusCode: resp.StatusCode, Path: req.URL.Path, Message: body.Message}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", fmt.Errorf("github: decoding %s: %w", req.URL.Path, err)
+	}
+
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPageURL extracts the rel="next" URL from a GitHub Link header
+func nextPageURL(link string) string {
+	match := linkNextPattern.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// listAllIn is like listAll for endpoints that wrap each page in an object,
+// such as {"total_count": 2, "workflow_runs": [...]}. key names the array field.
+// A positive limit stops pagination once that many items were read.
+func listAllIn[T any](ctx context.Context, c *Client, path string, query url.Values, key string, limit int) ([]T, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", perPage)
+
+	var items []T
+	next := path
+	for next != "" {
+		var envelope map[string]json.RawMessage
+		var err error
+		if next == path {
+			next, err = c.do(ctx, path, query, &envelope)
+		} else {
+			next, err = c.do(ctx, next, nil, &envelope)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var page []T
+		if raw, ok := envelope[key]; ok {
+			if err := json.Unmarshal(raw, &page); err != nil {
+				return nil, fmt.Errorf("github: decoding %s: %w", key, err)
+			}
+		}
+		items = append(items, page...)
+		if limit > 0 && len(items) >= limit {
+			return items[:limit], nil
+		}
+	}
+
+	return items, nil
+}
+
+// listAll walks every page of a list endpoint. keep is called for each item in
+// order; returning false stops pagination after the current page.
+func listAll[T any](ctx context.Context, c *Client, path string, query url.Values, keep func(T) bool) ([]T, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", perPage)
+
+	var items []T
+	next := path
+	for next != "" {
+		var page []T
+		var err error
+		if next == path {
+			next, err = c.do(ctx, path, query, &page)
+		} else {
+			next, err = c.do(ctx, next, nil, &page)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range page {
+			if keep != nil && !keep(item) {
+				return items, nil
+			}
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// CommitAuthor is the git author recorded in a commit
+type CommitAuthor struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// CommitFile is a file touched by a commit
+type CommitFile struct {
+	Filename  string `json:"filename"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// Commit is a GitHub commit. Author is nil when the git email is not linked to
+// a GitHub account. Stats and Files are only populated by GetCommit.
+type Commit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Author CommitAuthor `json:"author"`
+	} `json:"commit"`
+	Author *User `json:"author"`
+	Stats  struct {
+		Additions int `json:"additions"`
+		Deletions int `json:"deletions"`
+	} `json:"stats"`
+	Files []CommitFile `json:"files"`
+}
+
+// ListCommits returns the commits on the default branch authored between since
+// and until, newest first. A positive limit caps how many are returned.
+func (c *Client) ListCommits(ctx context.Context, owner, repo string, since, until time.Time, limit int) ([]Commit, error) {
+	query := url.Values{}
+	query.Set("since", since.UTC().Format(time.RFC3339))
+	query.Set("until", until.UTC().Format(time.RFC3339))
+
+	path := fmt.Sprintf("/repos/%s/%s/commits", url.PathEscape(owner), url.PathEscape(repo))
+	read := 0
+	return listAll(ctx, c, path, query, func(Commit) bool {
+		read++
+		return limit <= 0 || read <= limit
+	})
+}
+
+// GetCommit returns a single commit including its line stats and changed files
+func (c *Client) GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error) {
+	var commit Commit
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s", url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(sha))
+	if _, err := c.do(ctx, path, nil, &commit); err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
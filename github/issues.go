@@ -0,0 +1,58 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Label is an issue label
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Issue is a GitHub issue. The issues API also returns pull requests, which
+// have PullRequest set.
+type Issue struct {
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	State       string     `json:"state"`
+	HTMLURL     string     `json:"html_url"`
+	User        User       `json:"user"`
+	Labels      []Label    `json:"labels"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	PullRequest *struct{}  `json:"pull_request"`
+}
+
+// ListIssues returns issues (excluding pull requests) in the given state
+// ("open", "closed" or "all"), newest first. When since is non-zero only
+// issues updated at or after it are returned. A positive limit caps how many
+// are returned.
+func (c *Client) ListIssues(ctx context.Context, owner, repo, state string, since time.Time, limit int) ([]Issue, error) {
+	query := url.Values{}
+	query.Set("state", state)
+	query.Set("sort", "created")
+	query.Set("direction", "desc")
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+
+	path := fmt.Sprintf("/repos/%s/%s/issues", url.PathEscape(owner), url.PathEscape(repo))
+	var issues []Issue
+	_, err := listAll(ctx, c, path, query, func(item Issue) bool {
+		if limit > 0 && len(issues) >= limit {
+			return false
+		}
+		if item.PullRequest == nil {
+			issues = append(issues, item)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
@@ -0,0 +1,103 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// User is the subset of a GitHub account returned inside other resources
+type User struct {
+	Login string `json:"login"`
+}
+
+// PullRequest is a GitHub pull request. Additions, Deletions and ChangedFiles
+// are only populated by GetPullRequest, not by the list endpoint.
+type PullRequest struct {
+	Number       int        `json:"number"`
+	Title        string     `json:"title"`
+	State        string     `json:"state"`
+	HTMLURL      string     `json:"html_url"`
+	Draft        bool       `json:"draft"`
+	User         User       `json:"user"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	MergedAt     *time.Time `json:"merged_at"`
+	Additions    int        `json:"additions"`
+	Deletions    int        `json:"deletions"`
+	ChangedFiles int        `json:"changed_files"`
+}
+
+// Review is a pull request review
+type Review struct {
+	User        User      `json:"user"`
+	State       string    `json:"state"` // APPROVED, CHANGES_REQUESTED, COMMENTED, DISMISSED or PENDING
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// ListPullRequests returns pull requests in any state, newest first, created
+// between since and until. A positive limit caps how many are returned.
+func (c *Client) ListPullRequests(ctx context.Context, owner, repo string, since, until time.Time, limit int) ([]PullRequest, error) {
+	query := url.Values{}
+	query.Set("state", "all")
+	query.Set("sort", "created")
+	query.Set("direction", "desc")
+
+	path := fmt.Sprintf("/repos/%s/%s/pulls", url.PathEscape(owner), url.PathEscape(repo))
+	var pulls []PullRequest
+	_, err := listAll(ctx, c, path, query, func(pr PullRequest) bool {
+		if pr.CreatedAt.Before(since) || (limit > 0 && len(pulls) >= limit) {
+			return false
+		}
+		if !pr.CreatedAt.After(until) {
+			pulls = append(pulls, pr)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pulls, nil
+}
+
+// GetPullRequest returns a single pull request including its size
+func (c *Client) GetPullRequest(ctx context.Context, owner, repo string, number int) (*PullRequest, error) {
+	var pr PullRequest
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d", url.PathEscape(owner), url.PathEscape(repo), number)
+	if _, err := c.do(ctx, path, nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// ListReviews returns every review submitted on a pull request in chronological order
+func (c *Client) ListReviews(ctx context.Context, owner, repo string, number int) ([]Review, error) {
+	path := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", url.PathEscape(owner), url.PathEscape(repo), number)
+	return listAll[Review](ctx, c, path, nil, nil)
+}
+
+// ListOpenPullRequests returns every open pull request, oldest first
+func (c *Client) ListOpenPullRequests(ctx context.Context, owner, repo string) ([]PullRequest, error) {
+	query := url.Values{}
+	query.Set("state", "open")
+	query.Set("sort", "created")
+	query.Set("direction", "asc")
+
+	path := fmt.Sprintf("/repos/%s/%s/pulls", url.PathEscape(owner), url.PathEscape(repo))
+	return listAll[PullRequest](ctx, c, path, query, nil)
+}
+
+// ListUpdatedPullRequests returns pull requests in any state updated at or after since, most recently updated first
+func (c *Client) ListUpdatedPullRequests(ctx context.Context, owner, repo string, since time.Time) ([]PullRequest, error) {
+	query := url.Values{}
+	query.Set("state", "all")
+	query.Set("sort", "updated")
+	query.Set("direction", "desc")
+
+	path := fmt.Sprintf("/repos/%s/%s/pulls", url.PathEscape(owner), url.PathEscape(repo))
+	return listAll(ctx, c, path, query, func(pr PullRequest) bool {
+		return !pr.UpdatedAt.Before(since)
+	})
+}
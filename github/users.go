@@ -0,0 +1,70 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenExpirationLayouts are the formats seen in the GitHub-Authentication-Token-Expiration header
+var tokenExpirationLayouts = []string{"2006-01-02 15:04:05 -0700", "2006-01-02 15:04:05 MST"}
+
+// TokenStatus describes the health of the client's PAT
+type TokenStatus struct {
+	Valid              bool       `json:"valid"`
+	Login              string     `json:"login,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"` // nil for tokens without an expiration
+	Scopes             []string   `json:"scopes,omitempty"`     // only reported for classic tokens
+	RateLimitRemaining int        `json:"rate_limit_remaining"`
+}
+
+// TokenStatus checks the PAT against /user. A rejected token is reported as
+// invalid rather than as an error; errors are reserved for transport failures.
+func (c *Client) TokenStatus(ctx context.Context) (*TokenStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/user", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "token "+c.Token)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	status := &TokenStatus{Valid: resp.StatusCode == http.StatusOK}
+	status.RateLimitRemaining, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if !status.Valid {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return status, nil
+		}
+		return nil, &APIError{StatusCode: resp.StatusCode, Path: "/user"}
+	}
+
+	if expiration := resp.Header.Get("GitHub-Authentication-Token-Expiration"); expiration != "" {
+		for _, layout := range tokenExpirationLayouts {
+			if t, err := time.Parse(layout, expiration); err == nil {
+				status.ExpiresAt = &t
+				break
+			}
+		}
+	}
+	if scopes := resp.Header.Get("X-OAuth-Scopes"); scopes != "" {
+		for _, scope := range strings.Split(scopes, ",") {
+			status.Scopes = append(status.Scopes, strings.TrimSpace(scope))
+		}
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	status.Login = user.Login
+
+	return status, nil
+}
@@ -0,0 +1,126 @@
+// Package health reports whether the server can take traffic: the database is
+// reachable and migrated, the background workers are alive and the server is
+// not shutting down.
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"avidlogic/database"
+)
+
+// Component and overall statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// checkTimeout bounds the database checks of a readiness probe
+const checkTimeout = 2 * time.Second
+
+// Component is the health of one dependency
+type Component struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the health of every dependency. Status is down if any component is.
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+// heartbeat is the last sign of life of a background worker
+type heartbeat struct {
+	at     time.Time
+	maxAge time.Duration
+}
+
+var (
+	mu         sync.Mutex
+	heartbeats = make(map[string]heartbeat)
+	draining   atomic.Bool
+)
+
+// Register starts tracking the named worker, which must then call Beat at
+// least every maxAge to be considered alive
+func Register(name string, maxAge time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	heartbeats[name] = heartbeat{at: time.Now(), maxAge: maxAge}
+}
+
+// Beat records that the named worker is alive
+func Beat(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if hb, ok := heartbeats[name]; ok {
+		hb.at = time.Now()
+		heartbeats[name] = hb
+	}
+}
+
+// Drain marks the server as shutting down, failing readiness from now on
+func Drain() {
+	draining.Store(true)
+}
+
+// Ready checks every dependency of the server
+func Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	components := map[string]Component{
+		"server": {Status: StatusUp},
+	}
+	if draining.Load() {
+		components["server"] = Component{Status: StatusDown, Message: "shutting down"}
+	}
+
+	if err := database.Ping(ctx); err != nil {
+		slog.ErrorContext(ctx, "readiness: database unreachable", "err", err)
+		components["database"] = Component{Status: StatusDown, Message: "unreachable"}
+		components["migrations"] = Component{Status: StatusDown, Message: "database unavailable"}
+	} else {
+		components["database"] = Component{Status: StatusUp}
+		components["migrations"] = migrations(ctx)
+	}
+
+	now := time.Now()
+	mu.Lock()
+	for name, hb := range heartbeats {
+		age := now.Sub(hb.at).Round(time.Second)
+		if age > hb.maxAge {
+			components["worker:"+name] = Component{Status: StatusDown, Message: fmt.Sprintf("no heartbeat for %s", age)}
+		} else {
+			components["worker:"+name] = Component{Status: StatusUp}
+		}
+	}
+	mu.Unlock()
+
+	report := Report{Status: StatusUp, Components: components}
+	for _, component := range components {
+		if component.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// migrations reports pending migrations, which the running code may depend on
+func migrations(ctx context.Context) Component {
+	pending, err := database.PendingMigrations(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "readiness: checking migrations", "err", err)
+		return Component{Status: StatusDown, Message: "status unknown"}
+	}
+	if len(pending) > 0 {
+		return Component{Status: StatusDown, Message: fmt.Sprintf("pending migrations: %v", pending)}
+	}
+	return Component{Status: StatusUp}
+}
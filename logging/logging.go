@@ -0,0 +1,101 @@
+// Package logging sets up structured logging with log/slog. Log lines carry
+// the attributes attached to their context, such as the request ID and the
+// user ID, and the trace they belong to. Attributes named like credentials
+// are redacted.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"avidlogic/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute names whose values are never logged. Keys
+// ending in _password, _token or _secret are redacted too.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"pat":           true,
+	"token":         true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"api_key":       true,
+}
+
+// Setup installs the default logger, which the log package writes through too
+func Setup(cfg config.Logging) {
+	slog.SetDefault(slog.New(newHandler(os.Stdout, cfg)))
+}
+
+func newHandler(w io.Writer, cfg config.Logging) slog.Handler {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redact}
+	if cfg.Format == config.LogFormatText {
+		return contextHandler{slog.NewTextHandler(w, opts)}
+	}
+	return contextHandler{slog.NewJSONHandler(w, opts)}
+}
+
+// Sensitive reports whether an attribute with the given name must be redacted
+func Sensitive(key string) bool {
+	key = strings.ToLower(key)
+	return sensitiveKeys[key] || strings.HasSuffix(key, "_password") ||
+		strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret")
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if Sensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+type contextKey struct{}
+
+// With returns a context whose log lines carry the given attributes, as
+// key-value pairs or slog.Attr values, on top of those ctx already carries
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append([]slog.Attr(nil), attrsFrom(ctx)...)
+	attrs = append(attrs, slog.Group("", args...).Value.Group()...)
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the context attributes and trace IDs to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(attrsFrom(ctx)...)
+	if ctx != nil {
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
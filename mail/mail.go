@@ -0,0 +1,114 @@
+// Package mail sends plain-text email.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sender delivers one plain-text email
+type Sender interface {
+	Send(ctx context.Context, to, subject, text string) error
+}
+
+// SMTPSender sends email through an SMTP relay
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string // optional, enables PLAIN auth
+	Password string
+	From     string
+}
+
+// Send sends a plain-text email to a single address. Cancelling ctx, or
+// reaching its deadline, aborts the SMTP session.
+func (s *SMTPSender) Send(ctx context.Context, to, subject, text string) (err error) {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", s.From)
+	fmt.Fprintf(&body, "To: %s\r\n", to)
+	fmt.Fprintf(&body, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+	if err != nil {
+		return err
+	}
+	// The SMTP client blocks on the connection, so the end of ctx ends the
+	// session by expiring the connection's deadline
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer func() {
+		if !stop() && err != nil {
+			err = fmt.Errorf("mail: %w", context.Cause(ctx))
+		}
+	}()
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	return s.send(client, to, body.Bytes())
+}
+
+// send runs the SMTP session of smtp.SendMail on client
+func (s *SMTPSender) send(client *smtp.Client, to string, msg []byte) error {
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("mail: server doesn't support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// LogSender writes emails to the log instead of sending them, for
+// development setups without an SMTP relay
+type LogSender struct{}
+
+// Send logs the email. The body may hold verification links, so it is
+// only logged at debug level.
+func (LogSender) Send(ctx context.Context, to, subject, text string) error {
+	slog.InfoContext(ctx, "email not sent, no SMTP relay", "to", to, "subject", subject)
+	slog.DebugContext(ctx, "email body", "to", to, "body", text)
+	return nil
+}
@@ -1,58 +1,276 @@
 package main
 
 import (
+	"avidlogic/audit"
+	"avidlogic/auth"
+	"avidlogic/config"
 	"avidlogic/controllers"
 	"avidlogic/database"
-	_ "avidlogic/docs"
+	"avidlogic/digest"
+	_ "avidlogic/docs/v1"
+	"avidlogic/logging"
+	"avidlogic/mail"
+	"avidlogic/metrics"
 	"avidlogic/middleware" // Import JWT and Logging middleware
-	"log"
+	"avidlogic/netguard"
+	"avidlogic/problem"
+	"avidlogic/providers"
+	"avidlogic/ratelimit"
+	"avidlogic/reports"
+	"avidlogic/retention"
+	"avidlogic/store"
+	"avidlogic/tracing"
+	"avidlogic/validation"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // @title AvidLogic API
 // @version 1.0
 // @description This is a user management API for AvidLogic.
+// @description Errors are RFC 7807 problem details served as application/problem+json, with a stable code field to branch on.
+// @description Rate limited routes send RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers, and answer 429 with Retry-After once the limit is reached.
+// @description Routes are versioned under /v1. The unversioned paths of the same routes are deprecated aliases: their responses carry Deprecation, Sunset and a successor-version Link header, and they stop working at the sunset date.
 // @host localhost:8080
 // @BasePath /
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
 func main() {
-	// Load environment variables from .env file
-	err := godotenv.Load()
+	// Load the configuration from the optional .env file, the environment and the flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fatal("loading configuration", err)
+	}
+
+	// Log JSON lines through slog, also for the standard log package
+	logging.Setup(cfg.Logging)
+
+	// Set up tracing before anything records spans
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		fatal("setting up tracing", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("flushing traces", "err", err)
+		}
+	}()
 
 	// Connect to the database
-	database.ConnectDB()
+	if err := database.ConnectDB(cfg.Database); err != nil {
+		fatal("connecting to the database", err)
+	}
 	defer database.CloseDB()
 
-	router := gin.Default()
+	// Run the migrate subcommand instead of the server
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(args[1:]); err != nil {
+			database.CloseDB()
+			fatal("migration failed", err)
+		}
+		return
+	}
+
+	// Bring the schema up to date before serving when AUTO_MIGRATE is set
+	if cfg.AutoMigrate {
+		applied, err := database.Migrate(context.Background())
+		if err != nil {
+			database.CloseDB()
+			fatal("migration failed", err)
+		}
+		slog.Info("applied migrations", "count", len(applied))
+	}
+
+	// Handlers and background jobs persist through the Postgres store
+	db := store.NewPostgresStore(database.DB)
+	tokens := auth.NewJWT(cfg.JWTSecret, cfg.JWTTTL)
+	authRequired := middleware.AuthMiddleware(tokens, db)
+	recorder := audit.NewRecorder(db)
+	// New passwords are checked against the breached password list when one is configured
+	var breached *validation.BreachedList
+	if cfg.BreachedList != "" {
+		list, err := validation.OpenBreachedList(cfg.BreachedList)
+		if err != nil {
+			database.CloseDB()
+			fatal("opening breached password list", err)
+		}
+		defer list.Close()
+		breached = list
+	}
+	// Webhooks and self-hosted forges are only reached on public addresses
+	// and the configured internal networks
+	guard := &netguard.Guard{Allowed: cfg.AllowedNetworks}
+	forges := providers.Forges{GitHubAPIURL: cfg.GitHubAPIURL, Guard: guard}
+	reportQueue := reports.NewQueue(db, db)
+	userHandler := controllers.NewUserHandler(db, db, db, mailSender(cfg.SMTP), recorder, tokens, breached)
+	projectHandler := controllers.NewProjectHandler(db, db, db, reportQueue, forges, recorder)
+	digestHandler := controllers.NewDigestHandler(db, guard)
+	reportHandler := controllers.NewReportHandler(db)
+	auditHandler := controllers.NewAuditHandler(recorder)
+
+	// Background jobs run until the server shuts down
+	jobs, stopJobs := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	runInBackground := func(run func(ctx context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(jobs)
+		}()
+	}
+
+	// Start the background report generators
+	runInBackground(func(ctx context.Context) { reportQueue.Run(ctx, 2) })
+
+	// Start the weekly digest scheduler
+	runInBackground(digest.NewScheduler(db, db, db, forges, digestNotifiers(cfg.SMTP, guard)).Run)
+
+	// Start purging deleted accounts and projects
+	runInBackground(retention.NewPurger(db, db, cfg.RetentionWindow).Run)
+
+	// Rate limit buckets live in this instance's memory or, shared by every
+	// instance, in Postgres. A nil limiter lets everything through.
+	var limiter *ratelimit.Limiter
+	switch cfg.RateLimitStore {
+	case config.RateLimitMemory:
+		limiter = ratelimit.NewLimiter(store.NewMemoryStore())
+	case config.RateLimitPostgres:
+		limiter = ratelimit.NewLimiter(db)
+	}
+	if limiter != nil {
+		runInBackground(limiter.Run)
+	}
+
+	router := gin.New()
+
+	// Only trust X-Forwarded-For from the configured proxies, as client IPs end up in the audit log
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("invalid TRUSTED_PROXIES", err)
+	}
+
+	// Add tracing, request ID, request logging, metrics and panic recovery
+	// middleware. Probes and scrapes are not traced.
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/healthz" && r.URL.Path != "/readyz" && r.URL.Path != "/metrics"
+	})))
+	router.Use(middleware.RequestID(), middleware.RequestLogger(), metrics.Middleware(), middleware.Recovery())
 
-	// Add request logging middleware
-	router.Use(middleware.RequestLogger())
+	// Unknown routes get problem details like every other error
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		problem.Abort(c, problem.NotFound.New("No route for "+c.Request.URL.Path))
+	})
+	router.NoMethod(func(c *gin.Context) {
+		problem.Abort(c, problem.MethodNotAllowed.New(c.Request.Method+" is not allowed on "+c.Request.URL.Path))
+	})
 
-	// Swagger route
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Swagger routes, one spec per API version
+	router.GET("/swagger", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/swagger/v1/index.html")
+	})
+	router.GET("/swagger/v1/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.InstanceName("v1")))
 
-	// User routes
-	router.POST("/users", controllers.CreateUser)
-	router.POST("/login", controllers.Login)
+	// Monitoring routes
+	router.GET("/healthz", controllers.Healthz)
+	router.GET("/readyz", controllers.Readyz)
+	router.GET("/metrics", metrics.Handler())
+	prometheus.MustRegister(database.PoolCollector{})
 
-	// Project routes (Add project - requires JWT)
-	router.POST("/projects", middleware.AuthMiddleware(), controllers.AddProject)
+	// API routes, under /v1 with deprecated unversioned aliases
+	registerRoutes(router, userHandler, projectHandler, digestHandler, reportHandler, auditHandler, authRequired, limiter)
+
+	server := &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+
+	// Serve until SIGINT or SIGTERM. A second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err = serve(ctx, server, cfg.HTTP.ShutdownDelay, cfg.HTTP.ShutdownTimeout)
+	stop()
+
+	// Once no request can enqueue work, stop the background jobs. The
+	// deferred CloseDB closes the pool last.
+	stopJobs()
+	if !waitTimeout(&background, cfg.HTTP.ShutdownTimeout) {
+		slog.Error("background jobs did not stop in time")
+	}
+
+	if err != nil {
+		database.CloseDB()
+		fatal("server failed", err)
+	}
+	slog.Info("server stopped")
+}
+
+// fatal logs the error and exits. Deferred calls do not run, so callers
+// close the database pool first once it is open.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// smtpSender returns the configured SMTP relay, or nil if SMTP_HOST is unset
+func smtpSender(cfg config.SMTP) *mail.SMTPSender {
+	if cfg.Host == "" {
+		return nil
+	}
+	return &mail.SMTPSender{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	}
+}
+
+// mailSender returns the SMTP relay, or a sender that only logs emails when
+// no relay is configured
+func mailSender(cfg config.SMTP) mail.Sender {
+	if sender := smtpSender(cfg); sender != nil {
+		return sender
+	}
+	slog.Warn("SMTP_HOST is not set, emails will only be logged")
+	return mail.LogSender{}
+}
+
+// digestNotifiers returns the digest delivery channels. Webhooks are always
+// available; email requires an SMTP relay.
+func digestNotifiers(cfg config.SMTP, guard *netguard.Guard) map[string]digest.Notifier {
+	notifiers := map[string]digest.Notifier{
+		digest.ChannelWebhook: digest.NewWebhookNotifier(guard),
+	}
 
-	// Protected routes (JWT required)
-	protected := router.Group("/protected")
-	protected.Use(middleware.AuthMiddleware()) // Use JWT middleware
-	{
-		protected.GET("/profile", controllers.UserProfile) // Example protected route
+	if sender := smtpSender(cfg); sender != nil {
+		notifiers[digest.ChannelEmail] = &digest.SMTPNotifier{SMTPSender: *sender}
 	}
 
-	router.Run(":8080")
+	return notifiers
 }
@@ -0,0 +1,167 @@
+// Package metrics defines the Prometheus metrics of the server and the
+// helpers recording them. Metrics are registered with the default registry,
+// which also exports the Go runtime and process metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Outcome labels
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route template and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_login_attempts_total",
+		Help: "Login attempts, by outcome.",
+	}, []string{"outcome"})
+
+	githubRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_requests_total",
+		Help: "GitHub API calls, by status. Transport errors have status \"error\".",
+	}, []string{"method", "status"})
+
+	githubDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "github_api_request_duration_seconds",
+		Help:    "GitHub API call latency.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"method"})
+
+	githubRateLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "github_api_rate_limit_remaining",
+		Help: "Requests left in the rate limit window, as last reported by GitHub, by resource.",
+	}, []string{"resource"})
+
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "background_job_runs_total",
+		Help: "Background job runs, by job and outcome.",
+	}, []string{"job", "outcome"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "background_job_duration_seconds",
+		Help:    "Background job run time.",
+		Buckets: []float64{.1, .5, 1, 5, 15, 30, 60, 120, 300, 600},
+	}, []string{"job"})
+
+	// DeprecatedRequests counts the requests to deprecated routes, by route, to
+	// tell when clients have moved on
+	DeprecatedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_deprecated_requests_total",
+		Help: "Requests to deprecated routes, by route template.",
+	}, []string{"route"})
+
+	// RateLimited counts the requests rejected by the rate limiter, by policy
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limited_total",
+		Help: "Requests rejected by the rate limiter, by policy.",
+	}, []string{"policy"})
+
+	// ReportQueue is the number of reports waiting for a worker
+	ReportQueue = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "reports_queue_length",
+		Help: "Reports waiting for a worker.",
+	})
+
+	// DigestDeliveries counts digest deliveries by outcome
+	DigestDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "digest_deliveries_total",
+		Help: "Weekly digest deliveries, by outcome.",
+	}, []string{"outcome"})
+
+	// Purged counts the deleted accounts and projects permanently removed, by kind
+	Purged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "retention_purged_total",
+		Help: "Soft-deleted records permanently removed, by kind (user or project).",
+	}, []string{"kind"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware counts and times every request. Requests are labelled with the
+// route template, such as /projects/:id, to keep the number of series bounded.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Login records a login attempt
+func Login(outcome string) {
+	logins.WithLabelValues(outcome).Inc()
+}
+
+// Job records a background job run that started at start and failed if failed is set
+func Job(job string, start time.Time, failed bool) {
+	outcome := Success
+	if failed {
+		outcome = Failure
+	}
+	jobRuns.WithLabelValues(job, outcome).Inc()
+	jobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+}
+
+// GitHubTransport wraps next to count and time GitHub API calls and track the
+// remaining rate limit
+func GitHubTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		githubDuration.WithLabelValues(req.Method).Observe(time.Since(start).Seconds())
+		if err != nil {
+			githubRequests.WithLabelValues(req.Method, "error").Inc()
+			return resp, err
+		}
+
+		githubRequests.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+		if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+			resource := resp.Header.Get("X-RateLimit-Resource")
+			if resource == "" {
+				resource = "core"
+			}
+			githubRateLimit.WithLabelValues(resource).Set(float64(remaining))
+		}
+		return resp, nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -1,21 +1,25 @@
 package middleware
 
 import (
-	"net/http"
+	"errors"
 	"strings"
 
 	"avidlogic/auth" // Import the auth package for JWT validation
+	"avidlogic/logging"
+	"avidlogic/problem"
+	"avidlogic/store"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-// AuthMiddleware checks the JWT token for protected routes
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware checks the JWT token for protected routes and that its
+// account still exists, is not disabled and has not revoked the token
+func AuthMiddleware(tokens *auth.JWT, users store.UserStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
+			problem.Abort(c, problem.Unauthenticated.New("Authorization header is missing"))
 			return
 		}
 
@@ -28,15 +32,51 @@ func AuthMiddleware() gin.HandlerFunc {
 		token := strings.Split(authHeader, "Bearer ")[1]
 
 		// Validate the token
-		claims, err := auth.ValidateJWT(token)
+		claims, err := tokens.Validate(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			problem.Abort(c, problem.InvalidToken.New(""))
+			return
+		}
+
+		// Tokens outlive deleted and disabled accounts, so check the account itself
+		userID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			problem.Abort(c, problem.InvalidToken.New(""))
+			return
+		}
+		user, err := users.GetUserByID(c.Request.Context(), userID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Abort(c, problem.InvalidToken.New("User not found"))
+			return
+		} else if err != nil {
+			problem.Abort(c, problem.Internal.New("Failed to load user"))
+			return
+		}
+		if user.DisabledAt != nil {
+			problem.Abort(c, problem.AccountDisabled.New(""))
+			return
+		}
+		if claims.Version != user.TokenVersion {
+			problem.Abort(c, problem.InvalidToken.New("Token has been revoked"))
 			return
 		}
 
 		// Store user ID from the token in the context for further use
 		c.Set("userID", claims.UserID)
+		c.Set("isAdmin", user.IsAdmin)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", claims.UserID))
+
+		c.Next()
+	}
+}
+
+// AdminMiddleware restricts routes to administrators. It must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("isAdmin") {
+			problem.Abort(c, problem.Forbidden.New("Administrator access required"))
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"avidlogic/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks the responses of a route replaced by successor, a route
+// template such as /v1/projects/:id. It sets the Deprecation header of RFC
+// 9745, the Sunset header of RFC 8594 after which the route is removed, and a
+// Link to the successor with the parameters of the request filled in.
+func Deprecated(successor string, deprecatedAt, sunset time.Time) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunsetDate)
+		c.Header("Link", "<"+fillParams(successor, c)+`>; rel="successor-version"`)
+		metrics.DeprecatedRequests.WithLabelValues(c.FullPath()).Inc()
+
+		c.Next()
+	}
+}
+
+// fillParams replaces the :name segments of the route template with the request parameters
+func fillParams(template string, c *gin.Context) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = c.Param(name)
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -1,13 +1,15 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RequestLogger logs details about each incoming request
+// RequestLogger logs details about each incoming request. It must run after
+// RequestID so that the line carries the request ID.
 func RequestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Start time for the request
@@ -16,16 +18,24 @@ func RequestLogger() gin.HandlerFunc {
 		// Process the request
 		c.Next()
 
-		// Duration of the request
-		duration := time.Since(startTime)
+		// Server errors are logged as errors, client errors as warnings
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
 
-		// Log the request details
-		log.Printf("[Request] %s %s | Status: %d | Duration: %v | IP: %s",
-			c.Request.Method,
-			c.Request.URL.Path,
-			c.Writer.Status(),
-			duration,
-			c.ClientIP(),
+		// The request context also carries the user ID set by AuthMiddleware
+		slog.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(startTime).Milliseconds(),
+			"ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
 		)
 	}
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"avidlogic/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery turns a panicking handler into a 500 response and logs the panic
+// with its stack. It runs after RequestID so that the line carries the request ID.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				// The server aborts the connection for this one on purpose
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				slog.ErrorContext(c.Request.Context(), "handler panicked", "panic", err, "stack", string(debug.Stack()))
+				problem.Abort(c, problem.Internal.New(""))
+			}
+		}()
+
+		c.Next()
+	}
+}
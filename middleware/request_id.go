@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"regexp"
+
+	"avidlogic/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits accepted client request IDs to a safe length and charset
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID identifies each request by the client's X-Request-ID, or a new
+// UUID if it sent none, echoes it in the response and attaches it, with the
+// route, to the log lines of the request
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set("requestID", id)
+		c.Header(RequestIDHeader, id)
+
+		ctx := logging.With(c.Request.Context(), "request_id", id)
+		if route := c.FullPath(); route != "" {
+			ctx = logging.With(ctx, "route", route)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
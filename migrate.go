@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"avidlogic/database"
+)
+
+// runMigrate implements the "migrate" subcommand:
+//
+//	avidlogic migrate [up]          apply all pending migrations
+//	avidlogic migrate down [-n N]   revert the last N migrations (default 1)
+//	avidlogic migrate status        list migrations and when they were applied
+func runMigrate(args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := database.Migrate(ctx)
+		for _, version := range applied {
+			fmt.Printf("Applied migration %d\n", version)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Database schema is up to date")
+		}
+		return err
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("n", 1, "number of migrations to revert")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return fmt.Errorf("-n must be at least 1")
+		}
+		reverted, err := database.Rollback(ctx, *steps)
+		for _, version := range reverted {
+			fmt.Printf("Reverted migration %d\n", version)
+		}
+		return err
+
+	case "status":
+		statuses, err := database.MigrationStatuses(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+
+	default:
+		fmt.Fprintln(os.Stderr, "usage: avidlogic migrate [up | down [-n N] | status]")
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
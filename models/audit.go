@@ -0,0 +1,71 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit event outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditGenesisHash is the PrevHash of the first event of the log
+var AuditGenesisHash = strings.Repeat("0", 64)
+
+// AuditEvent is an entry of the append-only audit log
+type AuditEvent struct {
+	ID         int64             `json:"id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	ActorID    string            `json:"actor_id,omitempty"`
+	Actor      string            `json:"actor,omitempty"` // username or attempted email
+	Action     string            `json:"action"`
+	TargetType string            `json:"target_type,omitempty"`
+	TargetID   string            `json:"target_id,omitempty"`
+	IP         string            `json:"ip,omitempty"`
+	UserAgent  string            `json:"user_agent,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Details    map[string]string `json:"details,omitempty"`
+	PrevHash   string            `json:"prev_hash"`
+	Hash       string            `json:"hash"`
+}
+
+// ChainHash returns the hex SHA-256 of the event's content and PrevHash. The
+// ID is not covered since it is only assigned on insert.
+func (e AuditEvent) ChainHash() string {
+	h := sha256.New()
+	field := func(value string) {
+		// Length-prefixed so that field boundaries cannot be shifted
+		h.Write([]byte(strconv.Itoa(len(value))))
+		h.Write([]byte{':'})
+		h.Write([]byte(value))
+	}
+
+	field(e.PrevHash)
+	field(e.OccurredAt.UTC().Format(time.RFC3339Nano))
+	field(e.ActorID)
+	field(e.Actor)
+	field(e.Action)
+	field(e.TargetType)
+	field(e.TargetID)
+	field(e.IP)
+	field(e.UserAgent)
+	field(e.Outcome)
+
+	keys := make([]string, 0, len(e.Details))
+	for key := range e.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	field(strings.Join(keys, ","))
+	for _, key := range keys {
+		field(e.Details[key])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
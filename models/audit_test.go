@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChainHash(t *testing.T) {
+	base := AuditEvent{
+		ID:         1,
+		OccurredAt: time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC),
+		ActorID:    "42",
+		Actor:      "alice",
+		Action:     "user.login",
+		IP:         "192.0.2.1",
+		Outcome:    AuditSuccess,
+		Details:    map[string]string{"a": "1", "b": "2"},
+		PrevHash:   AuditGenesisHash,
+	}
+	hash := base.ChainHash()
+	if len(hash) != 64 {
+		t.Fatalf("got %q, want a hex SHA-256", hash)
+	}
+
+	tests := []struct {
+		name   string
+		change func(e *AuditEvent)
+		same   bool
+	}{
+		{"id", func(e *AuditEvent) { e.ID = 2 }, true},
+		{"stored hash", func(e *AuditEvent) { e.Hash = "edited" }, true},
+		{"time zone", func(e *AuditEvent) { e.OccurredAt = e.OccurredAt.In(time.FixedZone("CEST", 2*60*60)) }, true},
+		{"details order", func(e *AuditEvent) { e.Details = map[string]string{"b": "2", "a": "1"} }, true},
+		{"previous hash", func(e *AuditEvent) { e.PrevHash = "1" + AuditGenesisHash[1:] }, false},
+		{"time", func(e *AuditEvent) { e.OccurredAt = e.OccurredAt.Add(time.Microsecond) }, false},
+		{"actor", func(e *AuditEvent) { e.Actor = "mallory" }, false},
+		{"outcome", func(e *AuditEvent) { e.Outcome = AuditFailure }, false},
+		{"detail value", func(e *AuditEvent) { e.Details = map[string]string{"a": "1", "b": "3"} }, false},
+		{"detail removed", func(e *AuditEvent) { e.Details = map[string]string{"a": "1"} }, false},
+		{"shifted field boundary", func(e *AuditEvent) { e.ActorID, e.Actor = "42a", "lice" }, false},
+		{"shifted detail boundary", func(e *AuditEvent) { e.Details = map[string]string{"a": "12", "b": ""} }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := base
+			tt.change(&e)
+			if got := e.ChainHash(); (got == hash) != tt.same {
+				t.Errorf("hash changed: %v, want %v", got != hash, !tt.same)
+			}
+		})
+	}
+}
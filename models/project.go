@@ -1,14 +1,32 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
-// UserProject represents a GitHub project added by a user
+// UserProject represents a project (a set of repositories on a forge) added by a user
 type UserProject struct {
-	ID          int       `json:"id"`
-	UserID      string    `json:"user_id"`
-	ProjectType string    `json:"project_type"` // 'personal' or 'org'
-	Username    string    `json:"username"`
-	PAT         string    `json:"pat"`        // Store encrypted PAT
-	RepoNames   string    `json:"repo_names"` // Comma-separated repo names
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int        `json:"id"`
+	UserID      string     `json:"user_id"`
+	Provider    string     `json:"provider"`           // 'github', 'gitlab', 'bitbucket' or 'gitea'
+	BaseURL     string     `json:"base_url,omitempty"` // self-hosted instance, empty for the public service
+	ProjectType string     `json:"project_type"`       // 'personal' or 'org'
+	Username    string     `json:"username"`
+	PAT         string     `json:"pat"`        // Store encrypted PAT
+	RepoNames   string     `json:"repo_names"` // Comma-separated repo names
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // purged after the retention window
+}
+
+// Repos returns the project's repository names with surrounding whitespace removed
+func (p UserProject) Repos() []string {
+	var repos []string
+	for _, repo := range strings.Split(p.RepoNames, ",") {
+		repo = strings.TrimSpace(repo)
+		if repo != "" {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
 }
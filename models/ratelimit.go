@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RateBucket is the token bucket of one rate limit key
+type RateBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time // zero for a new bucket
+	ExpiresAt time.Time // when the bucket is full again, and equivalent to no bucket at all
+}
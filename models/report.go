@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Report statuses
+const (
+	ReportPending   = "pending"
+	ReportRunning   = "running"
+	ReportCompleted = "completed"
+	ReportFailed    = "failed"
+)
+
+// Report is an asynchronously generated export of project analytics
+type Report struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      string     `json:"user_id"`
+	ProjectID   int        `json:"project_id"`
+	Kind        string     `json:"kind"`   // pull_requests, contributors, issues or workflows
+	Format      string     `json:"format"` // csv, jsonl or pdf
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	From        time.Time  `json:"from"`
+	To          time.Time  `json:"to"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
@@ -6,10 +6,30 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uuid.UUID  `json:"id"`
+	Username     string     `json:"username"`
+	Email        string     `json:"email"`
+	PendingEmail string     `json:"pending_email,omitempty"` // new email awaiting verification
+	PasswordHash string     `json:"-"`
+	IsAdmin      bool       `json:"is_admin"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"` // purged after the retention window
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	TokenVersion int        `json:"-"` // tokens issued for another version are revoked
+
+	// EmailVerificationHash is the SHA-256 of the token sent to PendingEmail
+	EmailVerificationHash      string     `json:"-"`
+	EmailVerificationExpiresAt *time.Time `json:"-"`
+}
+
+// DigestPreference holds a user's opt-in to the weekly project activity digest
+type DigestPreference struct {
+	UserID     string     `json:"user_id"`
+	Enabled    bool       `json:"enabled"`
+	Channel    string     `json:"channel"` // 'email' or 'webhook'
+	WebhookURL string     `json:"webhook_url,omitempty"`
+	Weekday    int        `json:"weekday"` // 0 = Sunday, in UTC
+	Hour       int        `json:"hour"`    // 0-23, in UTC
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
 }
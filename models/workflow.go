@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// WorkflowRun is a GitHub Actions workflow run ingested for a project
+type WorkflowRun struct {
+	ID           int64      `json:"id"` // GitHub run ID
+	ProjectID    int        `json:"project_id"`
+	RepoName     string     `json:"repo_name"`
+	WorkflowID   int64      `json:"workflow_id"`
+	WorkflowName string     `json:"workflow_name"`
+	HeadSHA      string     `json:"head_sha"`
+	HeadBranch   string     `json:"head_branch"`
+	Event        string     `json:"event"`
+	Status       string     `json:"status"`
+	Conclusion   string     `json:"conclusion"`
+	RunAttempt   int        `json:"run_attempt"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RunStartedAt *time.Time `json:"run_started_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// WorkflowJob is a job of a workflow run attempt ingested for a project
+type WorkflowJob struct {
+	ID          int64      `json:"id"` // GitHub job ID
+	ProjectID   int        `json:"project_id"`
+	RunID       int64      `json:"run_id"`
+	Name        string     `json:"name"`
+	HeadSHA     string     `json:"head_sha"`
+	RunAttempt  int        `json:"run_attempt"`
+	Status      string     `json:"status"`
+	Conclusion  string     `json:"conclusion"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
@@ -0,0 +1,138 @@
+// Package netguard keeps the requests made to user-supplied URLs, such as
+// digest webhooks and self-hosted forges, off the server's own networks, so
+// that they cannot be used to reach internal services (SSRF). URLs must be
+// https, and addresses are checked when connecting, after DNS resolution, so
+// that a name resolving to a public address when checked and to an internal
+// one when used (DNS rebinding) is refused too.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// maxRedirects is how many redirects a guarded client follows
+const maxRedirects = 3
+
+// ErrInsecureURL is returned for a URL that is not https
+var ErrInsecureURL = errors.New("netguard: URL must use https")
+
+// ErrForbiddenAddress is returned for an address that is not public
+var ErrForbiddenAddress = errors.New("netguard: address is not public")
+
+// nonPublic lists the ranges besides loopback, private, link-local,
+// multicast and unspecified addresses that are not reachable on the internet
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use IPv4/IPv6 translation
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds any IPv4 address
+	netip.MustParsePrefix("2001::/32"),       // Teredo, likewise
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+}
+
+// Guard decides which addresses outbound requests may connect to. The zero
+// value, like a nil *Guard, only allows public addresses.
+type Guard struct {
+	// Allowed are internal networks requests may reach anyway, such as the
+	// one of a self-hosted forge
+	Allowed []netip.Prefix
+}
+
+// Allows reports whether connecting to ip is allowed
+func (g *Guard) Allows(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if g != nil {
+		for _, prefix := range g.Allowed {
+			if prefix.Contains(ip) {
+				return true
+			}
+		}
+	}
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL reports whether raw is an https URL whose host, if it is an IP
+// address, is allowed. Host names are checked when connecting.
+func (g *Guard) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return ErrInsecureURL
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !g.Allows(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// control refuses connections to addresses that are not allowed. It runs on
+// the resolved address of every connection attempt.
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !g.Allows(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// Transport returns an HTTP transport that only connects to allowed
+// addresses. It ignores the proxy environment variables, as a proxy would
+// connect on its behalf without the check.
+func (g *Guard) Transport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: g.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// Client returns an HTTP client with the given timeout sending requests
+// through wrap(g.Transport()), or g.Transport() when wrap is nil. It follows
+// at most three redirects, to https URLs only.
+func (g *Guard) Client(timeout time.Duration, wrap func(http.RoundTripper) http.RoundTripper) *http.Client {
+	var transport http.RoundTripper = g.Transport()
+	if wrap != nil {
+		transport = wrap(transport)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport, CheckRedirect: checkRedirect}
+}
+
+// checkRedirect stops redirect chains that are too long or leave https
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return fmt.Errorf("netguard: stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "https" {
+		return ErrInsecureURL
+	}
+	return nil
+}
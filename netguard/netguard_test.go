@@ -0,0 +1,109 @@
+package netguard
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestAllows(t *testing.T) {
+	guard := &Guard{Allowed: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"10.1.2.3", true}, // allowed network
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"2002:7f00:1::", false}, // 6to4 of 127.0.0.1
+	}
+	for _, tt := range tests {
+		if got := guard.Allows(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	var guard *Guard
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://hooks.example.com/T000/B000", nil},
+		{"https://93.184.216.34/hook", nil},
+		{"http://hooks.example.com/hook", ErrInsecureURL},
+		{"ftp://hooks.example.com/hook", ErrInsecureURL},
+		{"https:///hook", ErrInsecureURL},
+		{"https://127.0.0.1/hook", ErrForbiddenAddress},
+		{"https://[::1]:8443/hook", ErrForbiddenAddress},
+		{"https://169.254.169.254/latest/meta-data", ErrForbiddenAddress},
+	}
+	for _, tt := range tests {
+		if err := guard.CheckURL(tt.url); !errors.Is(err, tt.want) {
+			t.Errorf("CheckURL(%s) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached the internal server")
+	}))
+	defer srv.Close()
+
+	// The check happens when dialing, whatever the URL looked like
+	var guard *Guard
+	_, err := guard.Client(time.Second, nil).Get(srv.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("got %v, want %v", err, ErrForbiddenAddress)
+	}
+}
+
+func TestClientRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plain":
+			http.Redirect(w, r, "http://"+r.Host+"/", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	guard := &Guard{Allowed: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}
+	client := guard.Client(time.Second, func(transport http.RoundTripper) http.RoundTripper {
+		transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+		return transport
+	})
+
+	if resp, err := client.Get(srv.URL + "/ok"); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("allowed network: got %v, %v", resp, err)
+	}
+	if _, err := client.Get(srv.URL + "/plain"); !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("redirect to http: got %v, want %v", err, ErrInsecureURL)
+	}
+	if _, err := client.Get(srv.URL + "/loop"); err == nil {
+		t.Error("redirect loop: got no error")
+	}
+}
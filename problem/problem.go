@@ -0,0 +1,246 @@
+// Package problem defines the API error model: RFC 7807 problem details,
+// served as application/problem+json, with a stable machine-readable code
+// for every kind of error.
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"avidlogic/github"
+	"avidlogic/providers"
+	"avidlogic/reports"
+	"avidlogic/store"
+	"avidlogic/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// typePrefix turns a code into the problem type URI
+const typePrefix = "urn:avidlogic:problem:"
+
+// Kind is an entry of the error catalog. Its code never changes once published.
+type Kind struct {
+	Code   string
+	Status int
+	Title  string
+}
+
+// The error catalog
+var (
+	InvalidInput       = Kind{"invalid_input", http.StatusBadRequest, "The request body is malformed"}
+	ValidationFailed   = Kind{"validation_failed", http.StatusBadRequest, "The request has invalid fields"}
+	InvalidParameter   = Kind{"invalid_parameter", http.StatusBadRequest, "A path or query parameter is invalid"}
+	ProviderRejected   = Kind{"provider_rejected", http.StatusBadRequest, "The forge rejected the project details"}
+	Unsupported        = Kind{"unsupported", http.StatusBadRequest, "The operation is not supported"}
+	Unauthenticated    = Kind{"unauthenticated", http.StatusUnauthorized, "Authentication is required"}
+	InvalidToken       = Kind{"invalid_token", http.StatusUnauthorized, "The access token is invalid or expired"}
+	InvalidCredentials = Kind{"invalid_credentials", http.StatusUnauthorized, "The credentials are incorrect"}
+	AccountDisabled    = Kind{"account_disabled", http.StatusForbidden, "The account is disabled"}
+	Forbidden          = Kind{"forbidden", http.StatusForbidden, "The operation is not allowed"}
+	NotFound           = Kind{"not_found", http.StatusNotFound, "The resource does not exist"}
+	MethodNotAllowed   = Kind{"method_not_allowed", http.StatusMethodNotAllowed, "The method is not allowed"}
+	Conflict           = Kind{"conflict", http.StatusConflict, "A unique value is already taken"}
+	NotReady           = Kind{"not_ready", http.StatusConflict, "The resource is not ready yet"}
+	RateLimited        = Kind{"rate_limited", http.StatusTooManyRequests, "Too many requests"}
+	Internal           = Kind{"internal_error", http.StatusInternalServerError, "An unexpected error occurred"}
+	Upstream           = Kind{"upstream_error", http.StatusBadGateway, "A remote service failed"}
+	Unavailable        = Kind{"unavailable", http.StatusServiceUnavailable, "The service is temporarily unavailable"}
+	Timeout            = Kind{"timeout", http.StatusGatewayTimeout, "The operation timed out"}
+)
+
+// Catalog lists every kind of error
+var Catalog = []Kind{
+	InvalidInput, ValidationFailed, InvalidParameter, ProviderRejected, Unsupported,
+	Unauthenticated, InvalidToken, InvalidCredentials, AccountDisabled, Forbidden,
+	NotFound, MethodNotAllowed, Conflict, NotReady, RateLimited, Internal, Upstream, Unavailable, Timeout,
+}
+
+// Problem is an RFC 7807 problem details object
+// @Description RFC 7807 problem details, served as application/problem+json. Clients should branch on code, which is stable; title and detail are for humans.
+type Problem struct {
+	Type      string       `json:"type" example:"urn:avidlogic:problem:validation_failed"`
+	Title     string       `json:"title" example:"The request has invalid fields"`
+	Status    int          `json:"status" example:"400"`
+	Detail    string       `json:"detail,omitempty" example:"email must be a valid email address"`
+	Instance  string       `json:"instance,omitempty" example:"/users"`
+	Code      string       `json:"code" example:"validation_failed"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes one invalid field of the request
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Code    string `json:"code" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Code + ": " + p.Detail
+	}
+	return p.Code
+}
+
+// New returns a problem of this kind with a detail for humans
+func (k Kind) New(detail string) *Problem {
+	return &Problem{Type: typePrefix + k.Code, Title: k.Title, Status: k.Status, Detail: detail, Code: k.Code}
+}
+
+// Newf is New with a formatted detail
+func (k Kind) Newf(format string, args ...any) *Problem {
+	return k.New(fmt.Sprintf(format, args...))
+}
+
+// Field returns a problem of kind k about one invalid field
+func Field(k Kind, field, code, message string) *Problem {
+	p := k.New(field + " " + message)
+	p.Errors = []FieldError{{Field: field, Code: code, Message: message}}
+	return p
+}
+
+// FromError maps a domain error to its problem. Errors the catalog does not
+// cover become internal errors, without details that could leak internals.
+func FromError(err error) *Problem {
+	var p *Problem
+	var conflict *store.ConflictError
+	var apiErr *github.APIError
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.Is(err, store.ErrNotFound):
+		return NotFound.New("")
+	case errors.As(err, &conflict):
+		if conflict.Field == "" {
+			return Conflict.New("This value is already taken")
+		}
+		return Field(Conflict, conflict.Field, "taken", "is already taken")
+	case errors.Is(err, store.ErrConflict):
+		return Conflict.New("")
+	case errors.Is(err, reports.ErrQueueFull):
+		return Unavailable.New("Too many reports in progress, try again later")
+	case errors.Is(err, providers.ErrSelfHostedUnsupported):
+		return Unsupported.New("Self-hosted instances are not supported for this provider")
+	case errors.As(err, &apiErr):
+		return Upstream.Newf("GitHub answered %d", apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout.New("")
+	default:
+		return Internal.New("")
+	}
+}
+
+// Binding maps an error of gin's ShouldBind* to a problem listing the invalid fields
+func Binding(err error) *Problem {
+	var invalid validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &invalid):
+		p := ValidationFailed.New("")
+		details := make([]string, 0, len(invalid))
+		for _, fe := range invalid {
+			field := FieldError{Field: fe.Field(), Code: fe.Tag(), Message: message(fe)}
+			p.Errors = append(p.Errors, field)
+			details = append(details, field.Field+" "+field.Message)
+		}
+		p.Detail = strings.Join(details, "; ")
+		return p
+	case errors.As(err, &typeErr):
+		p := ValidationFailed.Newf("%s must be a %s", typeErr.Field, typeErr.Type.Kind())
+		p.Errors = []FieldError{{Field: typeErr.Field, Code: "type", Message: "must be a " + typeErr.Type.Kind().String()}}
+		return p
+	case errors.Is(err, io.EOF):
+		return InvalidInput.New("The request body is empty")
+	default:
+		return InvalidInput.New("The request body is not valid JSON")
+	}
+}
+
+// message describes a failed validation rule
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return "must be at least " + fe.Param() + " characters long"
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String {
+			return "must be at most " + fe.Param() + " characters long"
+		}
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		if msg, ok := validation.Messages[fe.Tag()]; ok {
+			return msg
+		}
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
+
+// Abort sends the problem, stamped with the request path and ID, and stops the handler chain
+func Abort(c *gin.Context, p *Problem) {
+	p.Instance = c.Request.URL.Path
+	p.RequestID = c.GetString("requestID")
+
+	level := slog.LevelWarn
+	if p.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(c.Request.Context(), level, p.Title, "code", p.Code, "status", p.Status, "detail", p.Detail)
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		body = []byte(`{"type":"` + typePrefix + Internal.Code + `","status":500,"code":"` + Internal.Code + `"}`)
+	}
+	c.Abort()
+	c.Data(p.Status, ContentType, body)
+}
+
+// Error maps err with FromError and sends the problem. Internal errors are
+// logged with the underlying error.
+func Error(c *gin.Context, err error) {
+	p := FromError(err)
+	if p.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Request.Context(), "request failed", "err", err)
+	}
+	Abort(c, p)
+}
+
+// init reports validation errors under the JSON names of the fields
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return field.Name
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// send calls fn with a gin test context for a request to path and returns the response
+func send(t *testing.T, path string, fn func(c *gin.Context)) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, path, nil)
+	c.Set("requestID", "req-1")
+	fn(c)
+	if !c.IsAborted() {
+		t.Error("the handler chain was not aborted")
+	}
+	return w
+}
+
+// decode returns the problem in a response, checking its content type
+func decode(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("got content type %q, want %s", got, ContentType)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	return p
+}
+
+func TestAbort(t *testing.T) {
+	w := send(t, "/v1/projects/7", func(c *gin.Context) {
+		Abort(c, NotFound.New("Project not found"))
+	})
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want 404", w.Code)
+	}
+	want := Problem{
+		Type:      "urn:avidlogic:problem:not_found",
+		Title:     NotFound.Title,
+		Status:    http.StatusNotFound,
+		Detail:    "Project not found",
+		Instance:  "/v1/projects/7",
+		Code:      "not_found",
+		RequestID: "req-1",
+	}
+	if got := decode(t, w); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAbortField(t *testing.T) {
+	w := send(t, "/v1/users", func(c *gin.Context) {
+		Abort(c, Field(ValidationFailed, "password", "breached", "appears in a known data breach"))
+	})
+
+	p := decode(t, w)
+	if w.Code != http.StatusBadRequest || p.Status != http.StatusBadRequest || p.Detail != "password appears in a known data breach" {
+		t.Errorf("got %d %+v, want 400 about the password", w.Code, p)
+	}
+	if len(p.Errors) != 1 || p.Errors[0] != (FieldError{Field: "password", Code: "breached", Message: "appears in a known data breach"}) {
+		t.Errorf("got field errors %+v", p.Errors)
+	}
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"not found", fmt.Errorf("loading: %w", store.ErrNotFound), http.StatusNotFound, "not_found", ""},
+		{"conflict", &store.ConflictError{Field: "email"}, http.StatusConflict, "conflict", "email is already taken"},
+		{"problem", Forbidden.New("Not yours"), http.StatusForbidden, "forbidden", "Not yours"},
+		// Internal errors do not leak their message
+		{"internal", errors.New("pq: connection refused"), http.StatusInternalServerError, "internal_error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(t, "/v1/me", func(c *gin.Context) { Error(c, tt.err) })
+			p := decode(t, w)
+			if w.Code != tt.status || p.Status != tt.status || p.Code != tt.code || p.Detail != tt.detail {
+				t.Errorf("got %d %+v, want %d %s %q", w.Code, p, tt.status, tt.code, tt.detail)
+			}
+			if p.Type != "urn:avidlogic:problem:"+tt.code || p.Title == "" || p.Instance != "/v1/me" {
+				t.Errorf("got type %q, title %q, instance %q", p.Type, p.Title, p.Instance)
+			}
+		})
+	}
+}
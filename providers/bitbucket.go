@@ -0,0 +1,61 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// bitbucketAPIURL is the Bitbucket Cloud REST API root
+const bitbucketAPIURL = "https://api.bitbucket.org/2.0"
+
+// bitbucketWebURL is the only base URL accepted for Bitbucket projects
+const bitbucketWebURL = "https://bitbucket.org"
+
+type bitbucketProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+func (p *bitbucketProvider) Name() string           { return Bitbucket }
+func (p *bitbucketProvider) DisplayName() string    { return "Bitbucket" }
+func (p *bitbucketProvider) OrgTerm() string        { return "workspace" }
+func (p *bitbucketProvider) CredentialTerm() string { return "app password or access token" }
+
+// auth authenticates with an app password given as "username:app_password",
+// or with a workspace/repository access token otherwise
+func (p *bitbucketProvider) auth(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		if username, password, ok := strings.Cut(token, ":"); ok {
+			req.SetBasicAuth(username, password)
+			return
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// ValidatePAT checks the app password or access token. Access tokens are not
+// tied to a user, so they are checked against the repositories they can list.
+func (p *bitbucketProvider) ValidatePAT(ctx context.Context, token string) (bool, error) {
+	if strings.Contains(token, ":") {
+		return check(ctx, p.client, p.apiURL+"/user", p.auth(token))
+	}
+	return check(ctx, p.client, p.apiURL+"/repositories?role=member&pagelen=1", p.auth(token))
+}
+
+// ValidateUser checks the personal workspace of a Bitbucket user, which is
+// where their repositories live
+func (p *bitbucketProvider) ValidateUser(ctx context.Context, token, username string) (bool, error) {
+	return p.ValidateOrg(ctx, token, username)
+}
+
+// ValidateOrg checks if the Bitbucket workspace exists and is visible to the credentials
+func (p *bitbucketProvider) ValidateOrg(ctx context.Context, token, workspace string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/workspaces/"+url.PathEscape(workspace), p.auth(token))
+}
+
+// ValidateRepoAccess checks if the credentials can access workspace/repo
+func (p *bitbucketProvider) ValidateRepoAccess(ctx context.Context, token, workspace, repo string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/repositories/"+url.PathEscape(workspace)+"/"+url.PathEscape(repo), p.auth(token))
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// giteaDefaultURL is the public Gitea instance
+const giteaDefaultURL = "https://gitea.com"
+
+type giteaProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+// giteaAPIURL maps a Gitea instance address to its REST API root
+func giteaAPIURL(baseURL string) string {
+	if baseURL == "" {
+		baseURL = giteaDefaultURL
+	}
+	return baseURL + "/api/v1"
+}
+
+func (p *giteaProvider) Name() string           { return Gitea }
+func (p *giteaProvider) DisplayName() string    { return "Gitea" }
+func (p *giteaProvider) OrgTerm() string        { return "organization" }
+func (p *giteaProvider) CredentialTerm() string { return "access token" }
+
+func (p *giteaProvider) auth(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+token)
+	}
+}
+
+// ValidatePAT checks the access token against /user
+func (p *giteaProvider) ValidatePAT(ctx context.Context, token string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/user", p.auth(token))
+}
+
+// ValidateUser checks if the Gitea user exists
+func (p *giteaProvider) ValidateUser(ctx context.Context, token, username string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/users/"+url.PathEscape(username), p.auth(token))
+}
+
+// ValidateOrg checks if the Gitea organization exists and is visible to the token
+func (p *giteaProvider) ValidateOrg(ctx context.Context, token, org string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/orgs/"+url.PathEscape(org), p.auth(token))
+}
+
+// ValidateRepoAccess checks if the token can access the given repository
+func (p *giteaProvider) ValidateRepoAccess(ctx context.Context, token, owner, repo string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(repo), p.auth(token))
+}
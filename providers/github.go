@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"avidlogic/github"
+	"avidlogic/metrics"
+	"avidlogic/models"
+	"avidlogic/tracing"
+)
+
+type gitHubProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+// gitHubAPIURL maps a GitHub Enterprise Server address to its REST API root.
+// An empty base URL means github.com, served by the API at publicAPIURL.
+func gitHubAPIURL(baseURL, publicAPIURL string) string {
+	if !gitHubSelfHosted(baseURL) {
+		if publicAPIURL != "" {
+			return publicAPIURL
+		}
+		return github.DefaultBaseURL
+	}
+	return baseURL + "/api/v3"
+}
+
+// gitHubSelfHosted reports whether baseURL is a GitHub Enterprise Server
+// instance rather than github.com
+func gitHubSelfHosted(baseURL string) bool {
+	return baseURL != "" && baseURL != "https://github.com"
+}
+
+// gitHubTransport instruments requests to the GitHub API
+func gitHubTransport(next http.RoundTripper) http.RoundTripper {
+	return tracing.Transport(metrics.GitHubTransport(next))
+}
+
+// GitHubClient returns an API client for a GitHub project, pointed at its
+// Enterprise Server instance when the project has a base URL.
+func (f Forges) GitHubClient(project models.UserProject) *github.Client {
+	baseURL := strings.TrimRight(project.BaseURL, "/")
+	client := github.NewClient(gitHubAPIURL(baseURL, f.GitHubAPIURL), project.PAT)
+	if gitHubSelfHosted(baseURL) {
+		client.HTTPClient = f.Guard.Client(client.HTTPClient.Timeout, gitHubTransport)
+	}
+	return client
+}
+
+func (p *gitHubProvider) Name() string           { return GitHub }
+func (p *gitHubProvider) DisplayName() string    { return "GitHub" }
+func (p *gitHubProvider) OrgTerm() string        { return "organization" }
+func (p *gitHubProvider) CredentialTerm() string { return "PAT" }
+
+func (p *gitHubProvider) auth(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+token)
+	}
+}
+
+// ValidatePAT checks if the provided PAT is valid by calling the /user GitHub API
+func (p *gitHubProvider) ValidatePAT(ctx context.Context, token string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/user", p.auth(token))
+}
+
+// ValidateUser checks if the GitHub user exists
+func (p *gitHubProvider) ValidateUser(ctx context.Context, token, username string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/users/"+url.PathEscape(username), p.auth(token))
+}
+
+// ValidateOrg checks if the GitHub organization exists and is visible to the PAT
+func (p *gitHubProvider) ValidateOrg(ctx context.Context, token, org string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/orgs/"+url.PathEscape(org), p.auth(token))
+}
+
+// ValidateRepoAccess checks if the PAT can access the given repository
+func (p *gitHubProvider) ValidateRepoAccess(ctx context.Context, token, owner, repo string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(repo), p.auth(token))
+}
@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// gitLabDefaultURL is the public GitLab instance
+const gitLabDefaultURL = "https://gitlab.com"
+
+type gitLabProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+// gitLabAPIURL maps a GitLab instance address to its REST API root
+func gitLabAPIURL(baseURL string) string {
+	if baseURL == "" {
+		baseURL = gitLabDefaultURL
+	}
+	return baseURL + "/api/v4"
+}
+
+func (p *gitLabProvider) Name() string           { return GitLab }
+func (p *gitLabProvider) DisplayName() string    { return "GitLab" }
+func (p *gitLabProvider) OrgTerm() string        { return "group" }
+func (p *gitLabProvider) CredentialTerm() string { return "PAT" }
+
+func (p *gitLabProvider) auth(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+}
+
+// ValidatePAT checks the personal access token against /user
+func (p *gitLabProvider) ValidatePAT(ctx context.Context, token string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/user", p.auth(token))
+}
+
+// ValidateUser checks if the GitLab user exists. GitLab has no lookup by
+// username, so this searches and expects exactly one match.
+func (p *gitLabProvider) ValidateUser(ctx context.Context, token, username string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+"/users?username="+url.QueryEscape(username), nil)
+	if err != nil {
+		return false, err
+	}
+	p.auth(token)(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if ok, err := validity(resp); !ok {
+		return false, err
+	}
+
+	var users []struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return false, err
+	}
+	return len(users) == 1, nil
+}
+
+// ValidateOrg checks if the GitLab group (or subgroup path, e.g. "parent/child") is accessible
+func (p *gitLabProvider) ValidateOrg(ctx context.Context, token, group string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/groups/"+url.PathEscape(group), p.auth(token))
+}
+
+// ValidateRepoAccess checks if the token can access the project owner/repo
+func (p *gitLabProvider) ValidateRepoAccess(ctx context.Context, token, owner, repo string) (bool, error) {
+	return check(ctx, p.client, p.apiURL+"/projects/"+url.PathEscape(owner+"/"+repo), p.auth(token))
+}
@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"avidlogic/netguard"
+	"avidlogic/tracing"
+)
+
+// Supported providers
+const (
+	GitHub    = "github"
+	GitLab    = "gitlab"
+	Bitbucket = "bitbucket"
+	Gitea     = "gitea"
+)
+
+// ErrSelfHostedUnsupported is returned for a base URL on a provider that only has a cloud service
+var ErrSelfHostedUnsupported = errors.New("providers: self-hosted instances are not supported")
+
+// Provider validates the credentials, owners and repositories of a project on one forge
+type Provider interface {
+	// Name is the provider identifier stored with the project
+	Name() string
+	// DisplayName is used in user-facing messages, e.g. "GitHub"
+	DisplayName() string
+	// OrgTerm is what the forge calls an organization, e.g. "organization" or "group"
+	OrgTerm() string
+	// CredentialTerm is what the forge calls the credential, e.g. "PAT" or "app password"
+	CredentialTerm() string
+
+	ValidatePAT(ctx context.Context, token string) (bool, error)
+	ValidateUser(ctx context.Context, token, username string) (bool, error)
+	ValidateOrg(ctx context.Context, token, org string) (bool, error)
+	ValidateRepoAccess(ctx context.Context, token, owner, repo string) (bool, error)
+}
+
+// Forges builds the providers and API clients of projects
+type Forges struct {
+	// GitHubAPIURL is the REST API root of github.com projects; empty uses
+	// github.DefaultBaseURL. Set it to point at a local fake server.
+	GitHubAPIURL string
+	// Guard decides which addresses self-hosted instances may be reached on;
+	// nil allows public addresses only
+	Guard *netguard.Guard
+}
+
+// New returns the provider with the given name. baseURL is the web address of a
+// self-hosted instance; leave it empty for the public service. A base URL that
+// is not https, or whose address the guard refuses, returns an error wrapping
+// netguard.ErrInsecureURL or netguard.ErrForbiddenAddress.
+func (f Forges) New(name, baseURL string) (Provider, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL != "" {
+		if err := f.Guard.CheckURL(baseURL); err != nil {
+			return nil, fmt.Errorf("providers: base URL: %w", err)
+		}
+	}
+	client := f.httpClient(baseURL != "", 15*time.Second, tracing.Transport)
+
+	switch name {
+	case GitHub, "":
+		client = f.httpClient(gitHubSelfHosted(baseURL), 15*time.Second, gitHubTransport)
+		return &gitHubProvider{apiURL: gitHubAPIURL(baseURL, f.GitHubAPIURL), client: client}, nil
+	case GitLab:
+		return &gitLabProvider{apiURL: gitLabAPIURL(baseURL), client: client}, nil
+	case Bitbucket:
+		// Bitbucket Data Center has a different API, only Bitbucket Cloud is supported
+		if baseURL != "" && baseURL != bitbucketWebURL {
+			return nil, ErrSelfHostedUnsupported
+		}
+		return &bitbucketProvider{apiURL: bitbucketAPIURL, client: client}, nil
+	case Gitea:
+		return &giteaProvider{apiURL: giteaAPIURL(baseURL), client: client}, nil
+	default:
+		return nil, fmt.Errorf("providers: unknown provider %q", name)
+	}
+}
+
+// httpClient returns the HTTP client for the API of an instance. The requests
+// to a self-hosted instance go through the guard, as its address comes from
+// the user; wrap instruments the transport.
+func (f Forges) httpClient(selfHosted bool, timeout time.Duration, wrap func(http.RoundTripper) http.RoundTripper) *http.Client {
+	if selfHosted {
+		return f.Guard.Client(timeout, wrap)
+	}
+	return &http.Client{Timeout: timeout, Transport: wrap(http.DefaultTransport)}
+}
+
+// check performs an authenticated GET and reports whether it answered 200.
+// 401, 403 and 404 mean "not valid" rather than an error; any other status,
+// such as a rate limit or an outage, is an error.
+func check(ctx context.Context, client *http.Client, url string, authenticate func(*http.Request)) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if authenticate != nil {
+		authenticate(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	return validity(resp)
+}
+
+// validity maps the status of a validation request to its outcome, see check
+func validity(resp *http.Response) (bool, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("providers: %s answered %d", resp.Request.URL.Redacted(), resp.StatusCode)
+	}
+}
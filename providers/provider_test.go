@@ -0,0 +1,218 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"avidlogic/netguard"
+)
+
+// forge is a fake forge API answering each path with a status, and 404 for
+// paths it does not know. Requests without the expected credentials get 401.
+type forge struct {
+	auth     func(*http.Request) bool
+	statuses map[string]int
+	bodies   map[string]string
+}
+
+func (f *forge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.auth(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	status, ok := f.statuses[path]
+	if !ok {
+		status = http.StatusNotFound
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(f.bodies[path]))
+}
+
+// validation is one call to a provider and the outcome it should have
+type validation struct {
+	name    string
+	call    func(context.Context, Provider) (bool, error)
+	want    bool
+	wantErr bool
+}
+
+func runValidations(t *testing.T, provider Provider, tests []validation) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call(context.Background(), provider)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitLabProvider(t *testing.T) {
+	srv := httptest.NewServer(&forge{
+		auth: func(r *http.Request) bool { return r.Header.Get("PRIVATE-TOKEN") == "secret" },
+		statuses: map[string]int{
+			"/api/v4/user":                     http.StatusOK,
+			"/api/v4/users?username=alice":     http.StatusOK,
+			"/api/v4/users?username=nobody":    http.StatusOK,
+			"/api/v4/users?username=broken":    http.StatusServiceUnavailable,
+			"/api/v4/groups/acme%2Fplatform":   http.StatusOK,
+			"/api/v4/groups/hidden":            http.StatusForbidden,
+			"/api/v4/projects/acme%2Fapi":      http.StatusOK,
+			"/api/v4/projects/acme%2Fthrottle": http.StatusTooManyRequests,
+		},
+		bodies: map[string]string{
+			"/api/v4/users?username=alice":  `[{"username":"alice"}]`,
+			"/api/v4/users?username=nobody": `[]`,
+		},
+	})
+	defer srv.Close()
+	provider := &gitLabProvider{apiURL: gitLabAPIURL(srv.URL), client: srv.Client()}
+
+	runValidations(t, provider, []validation{
+		{"valid token", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "secret") }, true, false},
+		{"invalid token is 401", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "wrong") }, false, false},
+		{"user", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateUser(ctx, "secret", "alice") }, true, false},
+		{"user without match", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateUser(ctx, "secret", "nobody") }, false, false},
+		{"user lookup outage", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateUser(ctx, "secret", "broken") }, false, true},
+		{"subgroup", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateOrg(ctx, "secret", "acme/platform")
+		}, true, false},
+		{"group is 403", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateOrg(ctx, "secret", "hidden") }, false, false},
+		{"project", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateRepoAccess(ctx, "secret", "acme", "api")
+		}, true, false},
+		{"project is 404", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateRepoAccess(ctx, "secret", "acme", "missing")
+		}, false, false},
+		{"rate limited", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateRepoAccess(ctx, "secret", "acme", "throttle")
+		}, false, true},
+	})
+}
+
+func TestBitbucketProvider(t *testing.T) {
+	srv := httptest.NewServer(&forge{
+		auth: func(r *http.Request) bool {
+			if username, password, ok := r.BasicAuth(); ok {
+				return username == "alice" && password == "app-password"
+			}
+			return r.Header.Get("Authorization") == "Bearer access-token"
+		},
+		statuses: map[string]int{
+			"/2.0/user": http.StatusOK,
+			"/2.0/repositories?role=member&pagelen=1": http.StatusOK,
+			"/2.0/workspaces/alice":                   http.StatusOK,
+			"/2.0/workspaces/acme":                    http.StatusOK,
+			"/2.0/workspaces/private":                 http.StatusForbidden,
+			"/2.0/repositories/acme/api":              http.StatusOK,
+			"/2.0/repositories/acme/down":             http.StatusBadGateway,
+		},
+	})
+	defer srv.Close()
+	provider := &bitbucketProvider{apiURL: srv.URL + "/2.0", client: srv.Client()}
+
+	runValidations(t, provider, []validation{
+		{"app password", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "alice:app-password") }, true, false},
+		{"wrong app password is 401", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "alice:wrong") }, false, false},
+		{"access token", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "access-token") }, true, false},
+		{"wrong access token is 401", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "wrong") }, false, false},
+		{"personal workspace", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateUser(ctx, "access-token", "alice")
+		}, true, false},
+		{"workspace", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateOrg(ctx, "access-token", "acme") }, true, false},
+		{"workspace is 403", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateOrg(ctx, "access-token", "private")
+		}, false, false},
+		{"workspace is 404", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateOrg(ctx, "access-token", "nobody")
+		}, false, false},
+		{"repository", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateRepoAccess(ctx, "alice:app-password", "acme", "api")
+		}, true, false},
+		{"repository outage", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateRepoAccess(ctx, "alice:app-password", "acme", "down")
+		}, false, true},
+	})
+}
+
+func TestGiteaProvider(t *testing.T) {
+	srv := httptest.NewServer(&forge{
+		auth: func(r *http.Request) bool { return r.Header.Get("Authorization") == "token secret" },
+		statuses: map[string]int{
+			"/api/v1/user":            http.StatusOK,
+			"/api/v1/users/alice":     http.StatusOK,
+			"/api/v1/orgs/acme":       http.StatusOK,
+			"/api/v1/orgs/private":    http.StatusForbidden,
+			"/api/v1/repos/acme/api":  http.StatusOK,
+			"/api/v1/repos/acme/down": http.StatusInternalServerError,
+		},
+	})
+	defer srv.Close()
+	provider := &giteaProvider{apiURL: giteaAPIURL(srv.URL), client: srv.Client()}
+
+	runValidations(t, provider, []validation{
+		{"valid token", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "secret") }, true, false},
+		{"invalid token is 401", func(ctx context.Context, p Provider) (bool, error) { return p.ValidatePAT(ctx, "wrong") }, false, false},
+		{"user", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateUser(ctx, "secret", "alice") }, true, false},
+		{"user is 404", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateUser(ctx, "secret", "nobody") }, false, false},
+		{"organization", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateOrg(ctx, "secret", "acme") }, true, false},
+		{"organization is 403", func(ctx context.Context, p Provider) (bool, error) { return p.ValidateOrg(ctx, "secret", "private") }, false, false},
+		{"repository", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateRepoAccess(ctx, "secret", "acme", "api")
+		}, true, false},
+		{"repository outage", func(ctx context.Context, p Provider) (bool, error) {
+			return p.ValidateRepoAccess(ctx, "secret", "acme", "down")
+		}, false, true},
+	})
+}
+
+func TestNewRejectsBaseURLs(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    error
+	}{
+		{"http://gitlab.example.com", netguard.ErrInsecureURL},
+		{"https://127.0.0.1", netguard.ErrForbiddenAddress},
+		{"https://10.0.0.5:8443", netguard.ErrForbiddenAddress},
+		{"https://169.254.169.254", netguard.ErrForbiddenAddress},
+		{"https://[::1]", netguard.ErrForbiddenAddress},
+		{"https://gitlab.example.com", nil},
+	}
+	for _, name := range []string{GitHub, GitLab, Gitea} {
+		for _, tt := range tests {
+			if _, err := (Forges{}).New(name, tt.baseURL); !errors.Is(err, tt.want) {
+				t.Errorf("New(%s, %s) = %v, want %v", name, tt.baseURL, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestSelfHostedRequestsAreGuarded(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached an internal server")
+	}))
+	defer srv.Close()
+
+	// localhost passes the URL check as a name, but resolves to loopback
+	baseURL := "https://localhost:" + strconv.Itoa(srv.Listener.Addr().(*net.TCPAddr).Port)
+	provider, err := (Forges{}).New(Gitea, baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.ValidatePAT(context.Background(), "secret"); !errors.Is(err, netguard.ErrForbiddenAddress) {
+		t.Errorf("got %v, want %v", err, netguard.ErrForbiddenAddress)
+	}
+}
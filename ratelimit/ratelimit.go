@@ -0,0 +1,159 @@
+// Package ratelimit limits how often clients may call a route, with a token
+// bucket per policy and client. Anonymous routes are keyed by client IP and
+// authenticated ones by user. Responses carry the RateLimit-* headers of the
+// IETF RateLimit header fields draft.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"avidlogic/metrics"
+	"avidlogic/models"
+	"avidlogic/problem"
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// purgeInterval is how often expired buckets are removed
+const purgeInterval = 10 * time.Minute
+
+// Policy allows Limit requests per Window. Unused tokens refill continuously,
+// so a client may burst up to Limit requests and then one every Window/Limit.
+type Policy struct {
+	Name   string // part of the bucket key, unique per policy
+	Limit  int
+	Window time.Duration
+}
+
+// rate returns how many tokens are refilled per second
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Window.Seconds()
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int           // whole tokens left
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, when not allowed
+}
+
+// KeyFunc identifies the client of a request
+type KeyFunc func(c *gin.Context) string
+
+// ByIP keys requests by client IP, for anonymous routes
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser keys requests by authenticated user, falling back to the client IP.
+// It must run after AuthMiddleware.
+func ByUser(c *gin.Context) string {
+	if userID := c.GetString("userID"); userID != "" {
+		return "user:" + userID
+	}
+	return ByIP(c)
+}
+
+// Limiter takes tokens from buckets kept in a store. A nil Limiter allows everything.
+type Limiter struct {
+	Store store.RateLimitStore
+	now   func() time.Time
+}
+
+// NewLimiter returns a limiter keeping its buckets in s
+func NewLimiter(s store.RateLimitStore) *Limiter {
+	return &Limiter{Store: s, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Allow takes a token from the bucket of key under policy
+func (l *Limiter) Allow(ctx context.Context, policy Policy, key string) (Result, error) {
+	res := Result{Limit: policy.Limit}
+	capacity, rate := float64(policy.Limit), policy.rate()
+	err := l.Store.UpdateRateBucket(ctx, policy.Name+":"+key, func(bucket *models.RateBucket) {
+		now := l.now()
+		if bucket.UpdatedAt.IsZero() {
+			bucket.Tokens = capacity
+		} else {
+			elapsed := math.Max(0, now.Sub(bucket.UpdatedAt).Seconds())
+			bucket.Tokens = math.Min(capacity, bucket.Tokens+elapsed*rate)
+		}
+		bucket.UpdatedAt = now
+
+		if bucket.Tokens >= 1 {
+			bucket.Tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = seconds((1 - bucket.Tokens) / rate)
+		}
+		res.Remaining = int(bucket.Tokens)
+		res.Reset = seconds((capacity - bucket.Tokens) / rate)
+		bucket.ExpiresAt = now.Add(res.Reset)
+	})
+	return res, err
+}
+
+// Middleware limits the requests of each client, as identified by key, to
+// the policy. Rejected requests get a 429 problem with Retry-After. If the
+// store fails, requests are let through rather than failing the API.
+func (l *Limiter) Middleware(policy Policy, key KeyFunc) gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		res, err := l.Allow(c.Request.Context(), policy, key(c))
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "rate limiting", "policy", policy.Name, "err", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", strconv.Itoa(policy.Limit)+";w="+strconv.Itoa(int(policy.Window.Seconds())))
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+		if !res.Allowed {
+			metrics.RateLimited.WithLabelValues(policy.Name).Inc()
+			retryAfter := int(res.RetryAfter.Seconds())
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			problem.Abort(c, problem.RateLimited.Newf("Too many requests, retry in %d seconds", retryAfter))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Run removes expired buckets until ctx is cancelled
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		purged, err := l.Store.PurgeRateBuckets(ctx, l.now())
+		metrics.Job("ratelimit_purge", start, err != nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "purging rate limit buckets", "err", err)
+		} else if purged > 0 {
+			slog.DebugContext(ctx, "purged rate limit buckets", "count", purged)
+		}
+	}
+}
+
+// seconds rounds s up to whole seconds, so that clients waiting that long succeed
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"avidlogic/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestLimiter returns a limiter over a memory store whose clock is advanced by hand
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(store.NewMemoryStore())
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAllowBurstAndRefill(t *testing.T) {
+	l, now := newTestLimiter()
+	policy := Policy{Name: "test", Limit: 3, Window: 3 * time.Minute} // one token a minute
+	ctx := context.Background()
+
+	// A new client may burst up to the limit
+	for i := range 3 {
+		res, err := l.Allow(ctx, policy, "ip:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d: got allowed %v with %d remaining, want allowed with %d", i+1, res.Allowed, res.Remaining, 2-i)
+		}
+	}
+	res, _ := l.Allow(ctx, policy, "ip:1")
+	if res.Allowed || res.RetryAfter != time.Minute || res.Reset != 3*time.Minute {
+		t.Fatalf("over the limit: got allowed %v, retry after %v, reset %v, want rejected, 1m0s, 3m0s", res.Allowed, res.RetryAfter, res.Reset)
+	}
+
+	// Tokens refill continuously, one per Window/Limit
+	*now = now.Add(30 * time.Second)
+	if res, _ := l.Allow(ctx, policy, "ip:1"); res.Allowed || res.RetryAfter != 30*time.Second {
+		t.Errorf("after 30s: got allowed %v, retry after %v, want rejected, 30s", res.Allowed, res.RetryAfter)
+	}
+	*now = now.Add(30 * time.Second)
+	if res, _ := l.Allow(ctx, policy, "ip:1"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("after 1m: got allowed %v with %d remaining, want allowed with 0", res.Allowed, res.Remaining)
+	}
+
+	// An idle bucket fills up to the limit, not beyond
+	*now = now.Add(time.Hour)
+	for i := range 4 {
+		res, _ := l.Allow(ctx, policy, "ip:1")
+		if want := i < 3; res.Allowed != want {
+			t.Errorf("after an hour, request %d: got allowed %v, want %v", i+1, res.Allowed, want)
+		}
+	}
+}
+
+func TestAllowSeparatesKeysAndPolicies(t *testing.T) {
+	l, _ := newTestLimiter()
+	one := Policy{Name: "one", Limit: 1, Window: time.Hour}
+	two := Policy{Name: "two", Limit: 1, Window: time.Hour}
+	ctx := context.Background()
+
+	if res, _ := l.Allow(ctx, one, "user:a"); !res.Allowed {
+		t.Fatal("first request rejected")
+	}
+	for _, tc := range []struct {
+		policy Policy
+		key    string
+		want   bool
+	}{
+		{one, "user:a", false},
+		{one, "user:b", true},
+		{two, "user:a", true},
+	} {
+		if res, _ := l.Allow(ctx, tc.policy, tc.key); res.Allowed != tc.want {
+			t.Errorf("%s %s: got allowed %v, want %v", tc.policy.Name, tc.key, res.Allowed, tc.want)
+		}
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name   string
+		key    KeyFunc
+		userID string
+		want   string
+	}{
+		{"ip", ByIP, "", "ip:192.0.2.1"},
+		{"ip ignores the user", ByIP, "42", "ip:192.0.2.1"},
+		{"user", ByUser, "42", "user:42"},
+		{"anonymous user", ByUser, "", "ip:192.0.2.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.RemoteAddr = "192.0.2.1:1234"
+			if tc.userID != "" {
+				c.Set("userID", tc.userID)
+			}
+			if got := tc.key(c); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, now := newTestLimiter()
+	router := gin.New()
+	router.GET("/", l.Middleware(Policy{Name: "test", Limit: 2, Window: time.Minute}, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	for range 2 {
+		if w := get(); w.Code != http.StatusNoContent || w.Header().Get("Retry-After") != "" {
+			t.Fatalf("got %d with Retry-After %q, want 204 without", w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+	w := get()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("over the limit: got %d with Retry-After %q, want 429 with 30", w.Code, w.Header().Get("Retry-After"))
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("got content type %q, want application/problem+json", got)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Policy":    "2;w=60",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("got %s %q, want %q", header, got, want)
+		}
+	}
+
+	// Waiting as long as Retry-After says succeeds
+	*now = now.Add(30 * time.Second)
+	if w := get(); w.Code != http.StatusNoContent {
+		t.Errorf("after Retry-After: got %d, want 204", w.Code)
+	}
+}
+
+func TestNilLimiterAllows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var l *Limiter
+	router := gin.New()
+	router.GET("/", l.Middleware(Policy{Name: "test", Limit: 1, Window: time.Hour}, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	for range 3 {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("got %d, want 204", w.Code)
+		}
+	}
+}
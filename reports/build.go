@@ -0,0 +1,241 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"avidlogic/analytics"
+	"avidlogic/github"
+	"avidlogic/models"
+	"avidlogic/store"
+)
+
+// Report kinds, one per analytics area
+const (
+	KindPullRequests = "pull_requests"
+	KindContributors = "contributors"
+	KindIssues       = "issues"
+	KindWorkflows    = "workflows"
+)
+
+// staleIssueDays is the inactivity threshold used in issue reports
+const staleIssueDays = 30
+
+// Build computes the analytics of the given kind for a project, fetched with
+// client or, for workflows, read from the ingested runs, and lays them out as a Report
+func Build(ctx context.Context, client *github.Client, workflows store.WorkflowStore, project models.UserProject, kind string, from, to time.Time) (*Report, error) {
+	owner, repos := project.Username, project.Repos()
+
+	report := &Report{
+		Subtitle:    fmt.Sprintf("%s (%s) - %s to %s", owner, strings.Join(repos, ", "), from.Format("2006-01-02"), to.Format("2006-01-02")),
+		GeneratedAt: time.Now(),
+	}
+
+	switch kind {
+	case KindPullRequests:
+		records, err := analytics.CollectPullRequests(ctx, client, owner, repos, from, to)
+		if err != nil {
+			return nil, err
+		}
+		report.Title = "Pull request cycle times"
+		report.Tables = []Table{
+			pullRequestTable("By repository", analytics.SummarizePullRequests(records, analytics.ByRepo)),
+			pullRequestTable("By author", analytics.SummarizePullRequests(records, analytics.ByAuthor)),
+		}
+
+	case KindContributors:
+		commits, err := analytics.CollectCommits(ctx, client, owner, repos, from, to)
+		if err != nil {
+			return nil, err
+		}
+		pulls, err := analytics.CollectPullRequests(ctx, client, owner, repos, from, to)
+		if err != nil {
+			return nil, err
+		}
+		report.Title = "Contributors"
+		report.Tables = []Table{
+			contributorTable(analytics.Contributors(commits, pulls)),
+			busFactorTable(analytics.BusFactor(commits, 2)),
+		}
+
+	case KindIssues:
+		issues, _, err := analytics.CollectIssues(ctx, client, owner, repos, "all", from)
+		if err != nil {
+			return nil, err
+		}
+		// Open issues that were not updated in the window are missing from
+		// the "since" query, so aging and labels use a separate listing.
+		allOpen, _, err := analytics.CollectIssues(ctx, client, owner, repos, "open", time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		report.Title = "Issue backlog health"
+		report.Tables = []Table{
+			issueAgingTable(analytics.IssueAges(repos, allOpen, time.Now())),
+			issueFlowTable(analytics.IssueFlows(repos, issues, from, to)),
+			staleIssueTable(analytics.StaleIssues(allOpen, time.Now(), staleIssueDays)),
+			labelTable(analytics.LabelBreakdowns(repos, allOpen)),
+		}
+
+	case KindWorkflows:
+		runs, err := workflows.ListWorkflowRuns(ctx, project.ID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		jobs, err := workflows.ListWorkflowJobs(ctx, project.ID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		report.Title = "CI workflows"
+		report.Tables = []Table{
+			workflowTable(analytics.WorkflowSummaries(runs, jobs)),
+			flakyJobTable(analytics.FlakyJobs(runs, jobs)),
+		}
+
+	default:
+		return nil, fmt.Errorf("reports: unknown kind %q", kind)
+	}
+
+	return report, nil
+}
+
+func itoa(v int) string { return strconv.Itoa(v) }
+
+func ftoa(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+
+func pullRequestTable(title string, stats []analytics.PullRequestStats) Table {
+	table := Table{
+		Title: title,
+		Columns: []string{"key", "pull_requests", "merged", "first_review_p50_h", "first_review_p90_h",
+			"approval_p50_h", "approval_p90_h", "merge_p50_h", "merge_p90_h", "size_p50", "size_p90"},
+		Chart: &Chart{Title: "Median time to merge (hours)"},
+	}
+	for _, s := range stats {
+		table.Rows = append(table.Rows, []string{s.Key, itoa(s.PullRequests), itoa(s.Merged),
+			ftoa(s.TimeToFirstReview.P50Hours), ftoa(s.TimeToFirstReview.P90Hours),
+			ftoa(s.TimeToApproval.P50Hours), ftoa(s.TimeToApproval.P90Hours),
+			ftoa(s.TimeToMerge.P50Hours), ftoa(s.TimeToMerge.P90Hours),
+			ftoa(s.Size.P50), ftoa(s.Size.P90)})
+		table.Chart.Labels = append(table.Chart.Labels, s.Key)
+		table.Chart.Values = append(table.Chart.Values, s.TimeToMerge.P50Hours)
+	}
+	return table
+}
+
+func contributorTable(contributors []analytics.Contributor) Table {
+	table := Table{
+		Title:   "Leaderboard",
+		Columns: []string{"name", "logins", "commits", "prs_opened", "prs_reviewed", "lines_added", "lines_deleted"},
+		Chart:   &Chart{Title: "Commits per contributor"},
+	}
+	for _, c := range contributors {
+		table.Rows = append(table.Rows, []string{c.Name, strings.Join(c.Logins, " "), itoa(c.Commits),
+			itoa(c.PullRequestsOpened), itoa(c.PullRequestsReviewed), itoa(c.LinesAdded), itoa(c.LinesDeleted)})
+		table.Chart.Labels = append(table.Chart.Labels, c.Name)
+		table.Chart.Values = append(table.Chart.Values, float64(c.Commits))
+	}
+	return table
+}
+
+func busFactorTable(directories []analytics.DirectoryOwnership) Table {
+	table := Table{
+		Title:   "Bus factor",
+		Columns: []string{"directory", "lines_changed", "contributors", "bus_factor", "top_contributor", "top_share"},
+	}
+	for _, d := range directories {
+		table.Rows = append(table.Rows, []string{d.Directory, itoa(d.LinesChanged), itoa(d.Contributors),
+			itoa(d.BusFactor), d.TopContributor, ftoa(d.TopShare)})
+	}
+	return table
+}
+
+func issueAgingTable(aging []analytics.IssueAging) Table {
+	table := Table{
+		Title:   "Open issue age",
+		Columns: []string{"repo", "open", "median_age_days", "0-7d", "7-30d", "30-90d", "90-365d", "365d+"},
+		Chart:   &Chart{Title: "Open issues per repository"},
+	}
+	for _, a := range aging {
+		table.Rows = append(table.Rows, []string{a.Repo, itoa(a.Open), ftoa(a.MedianAgeDays),
+			itoa(a.Buckets["0-7d"]), itoa(a.Buckets["7-30d"]), itoa(a.Buckets["30-90d"]),
+			itoa(a.Buckets["90-365d"]), itoa(a.Buckets["365d+"])})
+		table.Chart.Labels = append(table.Chart.Labels, a.Repo)
+		table.Chart.Values = append(table.Chart.Values, float64(a.Open))
+	}
+	return table
+}
+
+func issueFlowTable(flows []analytics.IssueFlow) Table {
+	table := Table{
+		Title:   "Weekly inflow and outflow",
+		Columns: []string{"repo", "week_start", "opened", "closed", "net"},
+	}
+	for _, flow := range flows {
+		for _, week := range flow.Weeks {
+			table.Rows = append(table.Rows, []string{flow.Repo, week.WeekStart.Format("2006-01-02"),
+				itoa(week.Opened), itoa(week.Closed), itoa(week.Net)})
+		}
+	}
+	return table
+}
+
+func staleIssueTable(issues []analytics.StaleIssue) Table {
+	table := Table{
+		Title:   fmt.Sprintf("Stale issues (no activity for %d days)", staleIssueDays),
+		Columns: []string{"repo", "number", "title", "labels", "days_inactive"},
+	}
+	for _, issue := range issues {
+		table.Rows = append(table.Rows, []string{issue.Repo, itoa(issue.Number), issue.Title,
+			strings.Join(issue.Labels, " "), itoa(issue.DaysInactive)})
+	}
+	return table
+}
+
+func labelTable(breakdowns []analytics.LabelBreakdown) Table {
+	table := Table{
+		Title:   "Open issues per label",
+		Columns: []string{"repo", "label", "open_issues"},
+	}
+	for _, breakdown := range breakdowns {
+		labels := make([]string, 0, len(breakdown.Labels))
+		for label := range breakdown.Labels {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			table.Rows = append(table.Rows, []string{breakdown.Repo, label, itoa(breakdown.Labels[label])})
+		}
+	}
+	return table
+}
+
+func workflowTable(stats []analytics.WorkflowStats) Table {
+	table := Table{
+		Title:   "Workflows",
+		Columns: []string{"repo", "workflow", "runs", "pass_rate", "median_min", "p90_min", "median_queue_s"},
+		Chart:   &Chart{Title: "Pass rate per workflow"},
+	}
+	for _, s := range stats {
+		table.Rows = append(table.Rows, []string{s.Repo, s.Workflow, itoa(s.Runs), ftoa(s.PassRate),
+			ftoa(s.MedianDurationMinutes), ftoa(s.P90DurationMinutes), ftoa(s.MedianQueueSeconds)})
+		table.Chart.Labels = append(table.Chart.Labels, s.Repo+"/"+s.Workflow)
+		table.Chart.Values = append(table.Chart.Values, s.PassRate)
+	}
+	return table
+}
+
+func flakyJobTable(jobs []analytics.FlakyJob) Table {
+	table := Table{
+		Title:   "Flaky jobs",
+		Columns: []string{"repo", "workflow", "job", "head_sha", "failures", "passed_at"},
+	}
+	for _, job := range jobs {
+		table.Rows = append(table.Rows, []string{job.Repo, job.Workflow, job.Job, job.HeadSHA,
+			itoa(job.Failures), job.PassedAt.Format(time.RFC3339)})
+	}
+	return table
+}
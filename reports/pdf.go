@@ -0,0 +1,253 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// A4 page geometry in PDF points
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	marginX      = 40.0
+	marginTop    = 50.0
+	marginBottom = 50.0
+	contentWidth = pageWidth - 2*marginX
+)
+
+const (
+	tableFontSize = 8.0
+	tableRowGap   = 12.0
+	chartBarSize  = 12.0
+	chartBarGap   = 4.0
+	chartLabelW   = 150.0
+	chartMaxBars  = 15
+	// avgCharWidth approximates Helvetica's average glyph width as a fraction of the font size
+	avgCharWidth = 0.5
+)
+
+// pdfWriter lays out text, tables and bar charts on A4 pages using the
+// standard Helvetica fonts, so no font files need to be embedded.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+// RenderPDF lays the report out as a paginated PDF with a bar chart ahead of every table that has one
+func RenderPDF(report *Report) []byte {
+	w := &pdfWriter{}
+	w.newPage()
+
+	w.text(marginX, w.y, 18, true, report.Title)
+	w.y -= 20
+	if report.Subtitle != "" {
+		w.text(marginX, w.y, 10, false, report.Subtitle)
+		w.y -= 14
+	}
+	w.text(marginX, w.y, 9, false, "Generated "+report.GeneratedAt.UTC().Format("2006-01-02 15:04 MST"))
+	w.y -= 30
+
+	for _, table := range report.Tables {
+		w.ensure(60)
+		w.text(marginX, w.y, 13, true, table.Title)
+		w.y -= 20
+
+		if table.Chart != nil && len(table.Chart.Values) > 0 {
+			w.chart(table.Chart)
+		}
+		w.table(table)
+		w.y -= 20
+	}
+
+	return w.bytes()
+}
+
+func (w *pdfWriter) newPage() {
+	w.page = &bytes.Buffer{}
+	w.pages = append(w.pages, w.page)
+	w.y = pageHeight - marginTop
+}
+
+// ensure starts a new page when less than height points are left
+func (w *pdfWriter) ensure(height float64) {
+	if w.y-height < marginBottom {
+		w.newPage()
+	}
+}
+
+func (w *pdfWriter) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(w.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escapePDF(s))
+}
+
+func (w *pdfWriter) rect(x, y, width, height float64, r, g, b float64) {
+	fmt.Fprintf(w.page, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f 0 g\n", r, g, b, x, y, width, height)
+}
+
+func (w *pdfWriter) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(w.page, "0.6 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", x1, y1, x2, y2)
+}
+
+// chart draws a horizontal bar chart of at most chartMaxBars values
+func (w *pdfWriter) chart(chart *Chart) {
+	count := len(chart.Values)
+	if count > chartMaxBars {
+		count = chartMaxBars
+	}
+
+	w.ensure(16 + float64(count)*(chartBarSize+chartBarGap))
+	w.text(marginX, w.y, 10, true, chart.Title)
+	w.y -= 16
+
+	maxValue := 0.0
+	for _, v := range chart.Values[:count] {
+		maxValue = math.Max(maxValue, v)
+	}
+
+	barArea := contentWidth - chartLabelW - 50
+	for i := 0; i < count; i++ {
+		label := ""
+		if i < len(chart.Labels) {
+			label = chart.Labels[i]
+		}
+		value := chart.Values[i]
+
+		w.text(marginX, w.y-chartBarSize+3, tableFontSize, false, truncate(label, chartLabelW, tableFontSize))
+		width := 0.0
+		if maxValue > 0 {
+			width = value / maxValue * barArea
+		}
+		w.rect(marginX+chartLabelW, w.y-chartBarSize, width, chartBarSize, 0.25, 0.47, 0.85)
+		w.text(marginX+chartLabelW+width+4, w.y-chartBarSize+3, tableFontSize, false, formatValue(value))
+		w.y -= chartBarSize + chartBarGap
+	}
+	w.y -= 10
+}
+
+// table draws the rows of a table with equal-width columns, repeating the header on every page
+func (w *pdfWriter) table(table Table) {
+	if len(table.Columns) == 0 {
+		return
+	}
+	colWidth := contentWidth / float64(len(table.Columns))
+
+	header := func() {
+		for i, column := range table.Columns {
+			w.text(marginX+float64(i)*colWidth, w.y, tableFontSize, true, truncate(column, colWidth, tableFontSize))
+		}
+		w.line(marginX, w.y-3, marginX+contentWidth, w.y-3)
+		w.y -= tableRowGap + 2
+	}
+
+	w.ensure(2 * tableRowGap)
+	header()
+
+	if len(table.Rows) == 0 {
+		w.text(marginX, w.y, tableFontSize, false, "No data")
+		w.y -= tableRowGap
+		return
+	}
+
+	for _, row := range table.Rows {
+		if w.y-tableRowGap < marginBottom {
+			w.newPage()
+			header()
+		}
+		for i, cell := range row {
+			if i >= len(table.Columns) {
+				break
+			}
+			w.text(marginX+float64(i)*colWidth, w.y, tableFontSize, false, truncate(cell, colWidth, tableFontSize))
+		}
+		w.y -= tableRowGap
+	}
+}
+
+// bytes assembles the PDF file: catalog, page tree, fonts, then a page and content stream per page
+func (w *pdfWriter) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	const firstPageObject = 5
+	kids := make([]string, len(w.pages))
+	for i := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+2*i)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range w.pages {
+		footer := fmt.Sprintf("BT /F1 8.0 Tf %.2f %.2f Td (Page %d of %d) Tj ET\n", pageWidth-marginX-50, marginBottom/2, i+1, len(w.pages))
+		content := page.String() + footer
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPageObject+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// escapePDF escapes a string for a PDF literal. Characters outside printable
+// ASCII are replaced since only the standard fonts are available.
+func escapePDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncate shortens s so it fits in width points at the given font size
+func truncate(s string, width, size float64) string {
+	maxChars := int(width/(size*avgCharWidth)) - 1
+	if maxChars < 1 {
+		maxChars = 1
+	}
+	runes := []rune(s)
+	if len(runes) <= maxChars {
+		return s
+	}
+	if maxChars <= 3 {
+		return string(runes[:maxChars])
+	}
+	return string(runes[:maxChars-3]) + "..."
+}
+
+func formatValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
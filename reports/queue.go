@@ -0,0 +1,243 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"avidlogic/github"
+	"avidlogic/health"
+	"avidlogic/metrics"
+	"avidlogic/models"
+	"avidlogic/store"
+
+	"github.com/google/uuid"
+)
+
+// queueSize is how many reports can wait for a worker before Enqueue refuses new ones
+const queueSize = 100
+
+// generationTimeout bounds how long a single report may take to build and render
+const generationTimeout = 10 * time.Minute
+
+// failTimeout bounds recording a failure, which happens even once the workers are stopping
+const failTimeout = 5 * time.Second
+
+// ErrQueueFull is returned by Enqueue when too many reports are waiting
+var ErrQueueFull = errors.New("reports: queue is full")
+
+// heartbeatInterval is how often idle workers report they are alive, and
+// how often the queue refreshes the heartbeat of the reports it holds
+const heartbeatInterval = time.Minute
+
+// staleAfter is how long after its last heartbeat an unfinished report is
+// taken to be abandoned by a server that stopped without failing it
+const staleAfter = 5 * heartbeatInterval
+
+// errShutdown is logged for the reports still queued when the workers stop
+var errShutdown = errors.New("reports: workers stopped")
+
+// Failure reasons of the reports interrupted by a shutdown, and of those a
+// server left unfinished when it stopped unexpectedly
+const (
+	shutdownReason = "The server shut down before generating the report"
+	staleReason    = "The server generating the report stopped unexpectedly"
+)
+
+// Job describes a report to generate
+type Job struct {
+	ReportID uuid.UUID
+	Project  models.UserProject
+	Client   *github.Client // fetches the analytics of Project
+	Kind     string
+	Format   string
+	From     time.Time
+	To       time.Time
+}
+
+// Queue generates reports in the background and stores the outcome
+type Queue struct {
+	Reports   store.ReportStore
+	Workflows store.WorkflowStore // ingested workflow runs, for workflow reports
+	jobs      chan Job
+
+	mu   sync.Mutex
+	held map[uuid.UUID]bool // reports queued or being generated here
+}
+
+// NewQueue returns an empty queue storing reports in reports
+func NewQueue(reports store.ReportStore, workflows store.WorkflowStore) *Queue {
+	return &Queue{Reports: reports, Workflows: workflows, jobs: make(chan Job, queueSize), held: make(map[uuid.UUID]bool)}
+}
+
+// Run generates queued reports on n workers until ctx is cancelled, which
+// also cancels the reports being generated. Those and the reports still
+// queued are marked failed since the queue does not survive a restart.
+//
+// Several servers may share the report table, as during a rolling deploy, so
+// the queue keeps the heartbeat of the reports it holds fresh, and only fails
+// the reports of others once their heartbeat is stale: their server stopped
+// without failing them, as when it crashed.
+func (q *Queue) Run(ctx context.Context, n int) {
+	health.Register("reports", generationTimeout+2*heartbeatInterval)
+
+	q.failStale(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := q.Reports.BeatReports(ctx, q.heldReports(), time.Now()); err != nil {
+				slog.ErrorContext(ctx, "refreshing report heartbeats", "err", err)
+			}
+			q.failStale(ctx)
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(heartbeatInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				case job := <-q.jobs:
+					metrics.ReportQueue.Set(float64(len(q.jobs)))
+					q.generate(ctx, job)
+				}
+				health.Beat("reports")
+			}
+		}()
+	}
+	wg.Wait()
+
+	for {
+		select {
+		case job := <-q.jobs:
+			q.fail(ctx, job.ReportID, shutdownReason, errShutdown)
+			q.release(job.ReportID)
+		default:
+			return
+		}
+	}
+}
+
+// failStale fails the unfinished reports whose heartbeat is stale
+func (q *Queue) failStale(ctx context.Context) {
+	failed, err := q.Reports.FailStaleReports(ctx, time.Now().Add(-staleAfter), staleReason)
+	if err != nil {
+		slog.ErrorContext(ctx, "failing stale reports", "err", err)
+	} else if failed > 0 {
+		slog.WarnContext(ctx, "failed reports left unfinished by a stopped server", "count", failed)
+	}
+}
+
+// Enqueue schedules a report for generation without blocking
+func (q *Queue) Enqueue(job Job) error {
+	q.hold(job.ReportID)
+	select {
+	case q.jobs <- job:
+		metrics.ReportQueue.Set(float64(len(q.jobs)))
+		return nil
+	default:
+		q.release(job.ReportID)
+		return ErrQueueFull
+	}
+}
+
+// hold adds a report to those whose heartbeat the queue refreshes
+func (q *Queue) hold(id uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.held[id] = true
+}
+
+// release removes a report from those whose heartbeat the queue refreshes
+func (q *Queue) release(id uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.held, id)
+}
+
+// heldReports returns the IDs of the reports queued or being generated here
+func (q *Queue) heldReports() []uuid.UUID {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ids := make([]uuid.UUID, 0, len(q.held))
+	for id := range q.held {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// generate builds and renders a report and stores the outcome. Cancelling
+// ctx abandons the report.
+func (q *Queue) generate(ctx context.Context, job Job) {
+	defer q.release(job.ReportID)
+	ctx, cancel := context.WithTimeout(ctx, generationTimeout)
+	defer cancel()
+
+	start, failed := time.Now(), true
+	defer func() { metrics.Job("report", start, failed) }()
+
+	if err := q.Reports.SetReportStatus(ctx, job.ReportID, models.ReportRunning); err != nil {
+		slog.ErrorContext(ctx, "updating report status", "report_id", job.ReportID, "err", err)
+	}
+
+	report, err := Build(ctx, job.Client, q.Workflows, job.Project, job.Kind, job.From, job.To)
+	if err != nil {
+		q.fail(ctx, job.ReportID, interrupted(ctx, "Failed to collect analytics"), err)
+		return
+	}
+
+	artifact, contentType, err := Render(report, job.Format)
+	if err != nil {
+		q.fail(ctx, job.ReportID, "Failed to render report", err)
+		return
+	}
+
+	if err := q.Reports.CompleteReport(ctx, job.ReportID, contentType, artifact); err != nil {
+		q.fail(ctx, job.ReportID, interrupted(ctx, "Failed to save report"), err)
+		return
+	}
+	failed = false
+}
+
+// interrupted returns the shutdown reason instead of reason once the workers
+// are stopping, and a timeout reason once the report took too long
+func interrupted(ctx context.Context, reason string) string {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "Generating the report took too long"
+	case ctx.Err() != nil:
+		return shutdownReason
+	default:
+		return reason
+	}
+}
+
+// fail records a user-facing reason and logs the underlying error. It runs
+// on a short context of its own, as ctx may be the cancelled one of the job.
+func (q *Queue) fail(ctx context.Context, id uuid.UUID, reason string, err error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), failTimeout)
+	defer cancel()
+
+	slog.ErrorContext(ctx, "generating report", "report_id", id, "reason", reason, "err", err)
+	if err := q.Reports.FailReport(ctx, id, reason); err != nil {
+		slog.ErrorContext(ctx, "updating report status", "report_id", id, "err", err)
+	}
+}
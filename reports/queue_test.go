@@ -0,0 +1,188 @@
+package reports
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"avidlogic/github"
+	"avidlogic/models"
+	"avidlogic/store"
+
+	"github.com/google/uuid"
+)
+
+// waitForStatus polls the report until it leaves the pending and running statuses
+func waitForStatus(t *testing.T, reports store.ReportStore, id uuid.UUID) models.Report {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		report, err := reports.GetReport(context.Background(), id, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Status == models.ReportCompleted || report.Status == models.ReportFailed {
+			return report
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("the report was not generated in time")
+	return models.Report{}
+}
+
+// enqueue creates a pending report and queues it
+func enqueue(t *testing.T, q *Queue, client *github.Client, kind string) uuid.UUID {
+	t.Helper()
+	report := models.Report{ID: uuid.New(), UserID: "user", ProjectID: 1, Kind: kind, Format: "csv",
+		Status: models.ReportPending, CreatedAt: time.Now()}
+	if err := q.Reports.CreateReport(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+	project := models.UserProject{ID: 1, Username: "octo", RepoNames: "api"}
+	job := Job{ReportID: report.ID, Project: project, Client: client, Kind: kind, Format: "csv",
+		From: time.Now().Add(-time.Hour), To: time.Now()}
+	if err := q.Enqueue(job); err != nil {
+		t.Fatal(err)
+	}
+	return report.ID
+}
+
+func TestQueueGenerates(t *testing.T) {
+	db := store.NewMemoryStore()
+	q := NewQueue(db, db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, 1)
+
+	// Workflow reports are built from the stored runs, without calling GitHub
+	id := enqueue(t, q, nil, KindWorkflows)
+	if report := waitForStatus(t, db, id); report.Status != models.ReportCompleted {
+		t.Fatalf("got %s (%s), want completed", report.Status, report.Error)
+	}
+	if contentType, _, err := db.GetReportArtifact(context.Background(), id, "user"); err != nil || contentType == "" {
+		t.Errorf("got artifact of type %q (%v)", contentType, err)
+	}
+}
+
+func TestQueueShutdownFailsInterruptedReports(t *testing.T) {
+	// GitHub answers only once the request is abandoned
+	requested := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	db := store.NewMemoryStore()
+	q := NewQueue(db, db)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(ctx, 1)
+		close(stopped)
+	}()
+
+	running := enqueue(t, q, github.NewClient(server.URL, "token"), KindPullRequests)
+	<-requested
+	queued := enqueue(t, q, github.NewClient(server.URL, "token"), KindPullRequests)
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the workers did not stop")
+	}
+	for _, id := range []uuid.UUID{running, queued} {
+		report, err := db.GetReport(context.Background(), id, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Status != models.ReportFailed || report.Error != shutdownReason {
+			t.Errorf("got %s (%s), want failed with the shutdown reason", report.Status, report.Error)
+		}
+	}
+}
+
+// createReport stores a report with the given status created at the given time
+func createReport(t *testing.T, reports store.ReportStore, status string, created time.Time) uuid.UUID {
+	t.Helper()
+	report := models.Report{ID: uuid.New(), UserID: "user", ProjectID: 1, Kind: KindWorkflows, Format: "csv", Status: status, CreatedAt: created}
+	if err := reports.CreateReport(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+	return report.ID
+}
+
+func TestQueueFailsStaleReports(t *testing.T) {
+	db := store.NewMemoryStore()
+	ctx := context.Background()
+	stale := time.Now().Add(-staleAfter - time.Minute)
+	abandoned := []uuid.UUID{createReport(t, db, models.ReportPending, stale), createReport(t, db, models.ReportRunning, stale)}
+	done := createReport(t, db, models.ReportPending, stale)
+	if err := db.CompleteReport(ctx, done, "text/csv", []byte("a,b\n")); err != nil {
+		t.Fatal(err)
+	}
+	// Another server is still generating these
+	recent := createReport(t, db, models.ReportRunning, time.Now().Add(-time.Minute))
+	beating := createReport(t, db, models.ReportRunning, stale)
+	if err := db.BeatReports(ctx, []uuid.UUID{beating}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run records the failures before its workers start
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	NewQueue(db, db).Run(stopped, 1)
+
+	for _, id := range abandoned {
+		report, _ := db.GetReport(ctx, id, "user")
+		if report.Status != models.ReportFailed || report.Error != staleReason {
+			t.Errorf("got %s (%s), want failed with the stale reason", report.Status, report.Error)
+		}
+	}
+	if report, _ := db.GetReport(ctx, done, "user"); report.Status != models.ReportCompleted {
+		t.Errorf("a completed report became %s", report.Status)
+	}
+	for _, id := range []uuid.UUID{recent, beating} {
+		if report, _ := db.GetReport(ctx, id, "user"); report.Status != models.ReportRunning {
+			t.Errorf("a report of another server became %s (%s)", report.Status, report.Error)
+		}
+	}
+}
+
+func TestQueueHoldsEnqueuedReports(t *testing.T) {
+	db := store.NewMemoryStore()
+	q := NewQueue(db, db)
+	q.jobs = make(chan Job, 1)
+	ctx := context.Background()
+	stale := time.Now().Add(-staleAfter - time.Minute)
+	queued := createReport(t, db, models.ReportPending, stale)
+	refused := createReport(t, db, models.ReportPending, stale)
+
+	if err := q.Enqueue(Job{ReportID: queued}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(Job{ReportID: refused}); err != ErrQueueFull {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+	if held := q.heldReports(); len(held) != 1 || held[0] != queued {
+		t.Fatalf("holding %v, want [%s]", held, queued)
+	}
+
+	// The heartbeat keeps a queued report from being taken as abandoned
+	if err := db.BeatReports(ctx, q.heldReports(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	q.failStale(ctx)
+	if report, _ := db.GetReport(ctx, queued, "user"); report.Status != models.ReportPending {
+		t.Errorf("the queued report became %s (%s)", report.Status, report.Error)
+	}
+	if report, _ := db.GetReport(ctx, refused, "user"); report.Status != models.ReportFailed {
+		t.Errorf("the refused report is %s, want failed", report.Status)
+	}
+}
@@ -0,0 +1,111 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Supported output formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatPDF   = "pdf"
+)
+
+// Report is a renderer-independent representation of project analytics: a
+// title and a list of tables, each optionally illustrated by a bar chart.
+type Report struct {
+	Title       string
+	Subtitle    string
+	GeneratedAt time.Time
+	Tables      []Table
+}
+
+// Table is one section of a report
+type Table struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+	Chart   *Chart
+}
+
+// Chart is a horizontal bar chart
+type Chart struct {
+	Title  string
+	Labels []string
+	Values []float64
+}
+
+// Render encodes the report in the given format and returns the artifact with its content type
+func Render(report *Report, format string) ([]byte, string, error) {
+	switch format {
+	case FormatCSV:
+		data, err := RenderCSV(report)
+		return data, "text/csv; charset=utf-8", err
+	case FormatJSONL:
+		data, err := RenderJSONL(report)
+		return data, "application/x-ndjson", err
+	case FormatPDF:
+		return RenderPDF(report), "application/pdf", nil
+	default:
+		return nil, "", fmt.Errorf("reports: unsupported format %q", format)
+	}
+}
+
+// Extension returns the file extension used for downloads of the given format
+func Extension(format string) string {
+	return "." + format
+}
+
+// RenderCSV writes every table one after another. Each row starts with the
+// table title in a "section" column so the file stays a single rectangular sheet per section.
+func RenderCSV(report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	for i, table := range report.Tables {
+		if i > 0 {
+			if err := w.Write(nil); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Write(append([]string{"section"}, table.Columns...)); err != nil {
+			return nil, err
+		}
+		for _, row := range table.Rows {
+			if err := w.Write(append([]string{table.Title}, row...)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// RenderJSONL writes one JSON object per table row, keyed by column name, with
+// the table title under "section".
+func RenderJSONL(report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for _, table := range report.Tables {
+		for _, row := range table.Rows {
+			record := make(map[string]string, len(table.Columns)+1)
+			record["section"] = table.Title
+			for i, column := range table.Columns {
+				if i < len(row) {
+					record[column] = row[i]
+				}
+			}
+			if err := enc.Encode(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,74 @@
+// Package retention permanently removes soft-deleted accounts and projects
+// once their retention window has passed.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"avidlogic/health"
+	"avidlogic/metrics"
+	"avidlogic/store"
+)
+
+// DefaultWindow is how long deleted data is kept before it is purged
+const DefaultWindow = 30 * 24 * time.Hour
+
+// checkInterval is how often the purger looks for expired data
+const checkInterval = time.Hour
+
+// Purger hard-deletes users and projects soft-deleted more than Window ago
+type Purger struct {
+	Users    store.UserStore
+	Projects store.ProjectStore
+	Window   time.Duration
+}
+
+// NewPurger returns a purger removing data deleted more than window ago
+func NewPurger(users store.UserStore, projects store.ProjectStore, window time.Duration) *Purger {
+	return &Purger{Users: users, Projects: projects, Window: window}
+}
+
+// Run purges expired data until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	health.Register("retention", 3*checkInterval)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		p.RunOnce(ctx, time.Now())
+		health.Beat("retention")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges everything deleted before now minus the window
+func (p *Purger) RunOnce(ctx context.Context, now time.Time) {
+	start, failed := time.Now(), false
+	defer func() { metrics.Job("retention", start, failed) }()
+	cutoff := now.Add(-p.Window)
+
+	users, err := p.Users.PurgeUsers(ctx, cutoff)
+	if err != nil {
+		slog.ErrorContext(ctx, "purging deleted users", "err", err)
+		failed = true
+	} else if users > 0 {
+		slog.InfoContext(ctx, "purged deleted users", "count", users)
+		metrics.Purged.WithLabelValues("user").Add(float64(users))
+	}
+
+	projects, err := p.Projects.PurgeProjects(ctx, cutoff)
+	if err != nil {
+		slog.ErrorContext(ctx, "purging deleted projects", "err", err)
+		failed = true
+	} else if projects > 0 {
+		slog.InfoContext(ctx, "purged deleted projects", "count", projects)
+		metrics.Purged.WithLabelValues("project").Add(float64(projects))
+	}
+}
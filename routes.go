@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"avidlogic/controllers"
+	"avidlogic/middleware"
+	"avidlogic/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The unversioned routes were deprecated when /v1 was introduced and are
+// removed at their sunset
+var (
+	legacyDeprecated = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	legacySunset     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// versioned registers the routes of an API version along with deprecated
+// aliases at the unversioned paths they replace
+type versioned struct {
+	router *gin.Engine
+}
+
+// handle registers handlers at path in group g and, unless legacy is empty,
+// at the legacy path with the deprecation headers. Aliases run the same
+// middleware as the group.
+func (v versioned) handle(g *gin.RouterGroup, method, path, legacy string, handlers ...gin.HandlerFunc) {
+	g.Handle(method, path, handlers...)
+	if legacy == "" {
+		return
+	}
+	chain := gin.HandlersChain{middleware.Deprecated(g.BasePath()+path, legacyDeprecated, legacySunset)}
+	chain = append(chain, g.Handlers[len(v.router.Handlers):]...)
+	v.router.Handle(method, legacy, append(chain, handlers...)...)
+}
+
+// registerRoutes registers the API under /v1 and the deprecated unversioned aliases
+func registerRoutes(router *gin.Engine, users *controllers.UserHandler, projects *controllers.ProjectHandler,
+	digests *controllers.DigestHandler, reports *controllers.ReportHandler, audits *controllers.AuditHandler,
+	authRequired gin.HandlerFunc, limiter *ratelimit.Limiter) {
+	// Rate limits, per client IP on anonymous routes and per user elsewhere.
+	// Adding projects, syncing and reports spend calls on the user's forge
+	// tokens, and so do the analytics computed live from the forge, which
+	// share a larger budget. The workflow statistics read stored runs only.
+	signupLimit := limiter.Middleware(ratelimit.Policy{Name: "signup", Limit: 5, Window: time.Hour}, ratelimit.ByIP)
+	loginLimit := limiter.Middleware(ratelimit.Policy{Name: "login", Limit: 10, Window: time.Minute}, ratelimit.ByIP)
+	verifyLimit := limiter.Middleware(ratelimit.Policy{Name: "verify_email", Limit: 10, Window: time.Hour}, ratelimit.ByIP)
+	apiLimit := limiter.Middleware(ratelimit.Policy{Name: "api", Limit: 300, Window: time.Minute}, ratelimit.ByUser)
+	forgeLimit := limiter.Middleware(ratelimit.Policy{Name: "forge", Limit: 30, Window: time.Hour}, ratelimit.ByUser)
+	analyticsLimit := limiter.Middleware(ratelimit.Policy{Name: "analytics", Limit: 120, Window: time.Hour}, ratelimit.ByUser)
+
+	v := versioned{router: router}
+	v1 := router.Group("/v1")
+
+	// Signup, login and email verification
+	v.handle(v1, http.MethodPost, "/users", "/users", signupLimit, users.CreateUser)
+	v.handle(v1, http.MethodPost, "/sessions", "/login", loginLimit, users.Login)
+	v.handle(v1, http.MethodPost, "/email-verifications", "/users/verify-email", verifyLimit, users.VerifyEmail)
+
+	// Account routes (JWT required)
+	me := v1.Group("/me", authRequired, apiLimit)
+	v.handle(me, http.MethodGet, "", "/me", users.GetMe)
+	v.handle(me, http.MethodPatch, "", "/me", users.UpdateMe)
+	v.handle(me, http.MethodDelete, "", "/me", users.DeleteMe)
+	v.handle(me, http.MethodPut, "/password", "/me/password", users.ChangePassword)
+	v.handle(me, http.MethodGet, "/export", "/me/export", users.ExportMe)
+	v.handle(me, http.MethodGet, "/digest-preferences", "/digest/preferences", digests.GetDigestPreference)
+	v.handle(me, http.MethodPut, "/digest-preferences", "/digest/preferences", digests.UpdateDigestPreference)
+
+	// The profile example route has no /v1 counterpart, GET /v1/me replaces it
+	router.GET("/protected/profile", middleware.Deprecated("/v1/me", legacyDeprecated, legacySunset),
+		authRequired, apiLimit, users.UserProfile)
+
+	// Admin routes (JWT and administrator required)
+	admin := v1.Group("/admin", authRequired, middleware.AdminMiddleware(), apiLimit)
+	v.handle(admin, http.MethodGet, "/users", "/admin/users", users.ListUsers)
+	v.handle(admin, http.MethodPost, "/users/:user_id/disable", "/admin/users/:user_id/disable", users.DisableUser)
+	v.handle(admin, http.MethodPost, "/users/:user_id/enable", "/admin/users/:user_id/enable", users.EnableUser)
+	v.handle(admin, http.MethodGet, "/audit-events", "/admin/audit", audits.ListAuditEvents)
+	v.handle(admin, http.MethodGet, "/audit-events/verify", "/admin/audit/verify", audits.VerifyAuditLog)
+
+	// Project routes (JWT required)
+	project := v1.Group("/projects", authRequired, apiLimit)
+	v.handle(project, http.MethodPost, "", "/projects", forgeLimit, projects.AddProject)
+	v.handle(project, http.MethodDelete, "/:id", "/projects/:id", projects.DeleteProject)
+
+	// Pull request analytics
+	v.handle(project, http.MethodGet, "/:id/pulls/repositories", "/projects/:id/pulls/repositories", analyticsLimit, projects.GetPullRequestStatsByRepo)
+	v.handle(project, http.MethodGet, "/:id/pulls/authors", "/projects/:id/pulls/authors", analyticsLimit, projects.GetPullRequestStatsByAuthor)
+
+	// Contributor analytics
+	v.handle(project, http.MethodGet, "/:id/contributors", "/projects/:id/contributors", analyticsLimit, projects.GetContributors)
+	v.handle(project, http.MethodGet, "/:id/bus-factor", "/projects/:id/bus-factor", analyticsLimit, projects.GetBusFactor)
+
+	// Issue backlog health
+	v.handle(project, http.MethodGet, "/:id/issues/aging", "/projects/:id/issues/aging", analyticsLimit, projects.GetIssueAging)
+	v.handle(project, http.MethodGet, "/:id/issues/flow", "/projects/:id/issues/flow", analyticsLimit, projects.GetIssueFlow)
+	v.handle(project, http.MethodGet, "/:id/issues/stale", "/projects/:id/issues/stale", analyticsLimit, projects.GetStaleIssues)
+	v.handle(project, http.MethodGet, "/:id/issues/labels", "/projects/:id/issues/labels", analyticsLimit, projects.GetIssueLabels)
+
+	// GitHub Actions analytics
+	v.handle(project, http.MethodPost, "/:id/workflows/sync", "/projects/:id/workflows/sync", forgeLimit, projects.SyncWorkflowRuns)
+	v.handle(project, http.MethodGet, "/:id/workflows", "/projects/:id/workflows", projects.GetWorkflowStats)
+	v.handle(project, http.MethodGet, "/:id/workflows/flaky", "/projects/:id/workflows/flaky", projects.GetFlakyJobs)
+	v.handle(project, http.MethodGet, "/:id/workflows/:workflow_id/trends", "/projects/:id/workflows/:workflow_id/trends", projects.GetWorkflowTrend)
+
+	// Report exports
+	v.handle(project, http.MethodPost, "/:id/reports", "/projects/:id/reports", forgeLimit, projects.CreateReport)
+
+	// Weekly digest preview
+	v.handle(project, http.MethodGet, "/:id/digest", "/projects/:id/digest", analyticsLimit, projects.PreviewDigest)
+
+	// Report routes (JWT required)
+	report := v1.Group("/reports", authRequired, apiLimit)
+	v.handle(report, http.MethodGet, "/:report_id", "/reports/:report_id", reports.GetReportStatus)
+	v.handle(report, http.MethodGet, "/:report_id/download", "/reports/:report_id/download", reports.DownloadReport)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"avidlogic/health"
+)
+
+// serve runs the server until ctx is cancelled. It then fails readiness
+// probes for delay, so load balancers stop routing to it, stops accepting
+// connections and gives in-flight requests up to timeout to complete. It
+// returns early with the error if the server fails.
+func serve(ctx context.Context, server *http.Server, delay, timeout time.Duration) error {
+	failed := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+	slog.Info("listening", "addr", server.Addr)
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	health.Drain()
+	if delay > 0 {
+		slog.Info("shutting down after delay", "delay", delay.String())
+		time.Sleep(delay)
+	}
+
+	slog.Info("shutting down, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("draining requests: %w", err)
+	}
+	return nil
+}
+
+// waitTimeout waits for wg, giving up after timeout. It reports whether wg finished.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"avidlogic/models"
+)
+
+// auditLog is the in-memory audit log embedded in MemoryStore
+type auditLog struct {
+	mu     sync.RWMutex
+	events []models.AuditEvent
+}
+
+// AppendAuditEvent chains the event to the last one and stores it
+func (s *MemoryStore) AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	s.audit.mu.Lock()
+	defer s.audit.mu.Unlock()
+
+	prevHash := models.AuditGenesisHash
+	if n := len(s.audit.events); n > 0 {
+		prevHash = s.audit.events[n-1].Hash
+	}
+	sealAuditEvent(event, prevHash)
+	event.ID = int64(len(s.audit.events) + 1)
+	s.audit.events = append(s.audit.events, *event)
+	return nil
+}
+
+// ListAuditEvents returns one page of events matching the filter, newest first, and the number of matches
+func (s *MemoryStore) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, int, error) {
+	s.audit.mu.RLock()
+	defer s.audit.mu.RUnlock()
+
+	var matches []models.AuditEvent
+	for i := len(s.audit.events) - 1; i >= 0; i-- {
+		e := s.audit.events[i]
+		switch {
+		case filter.ActorID != "" && e.ActorID != filter.ActorID,
+			filter.Action != "" && e.Action != filter.Action,
+			filter.TargetType != "" && e.TargetType != filter.TargetType,
+			filter.TargetID != "" && e.TargetID != filter.TargetID,
+			filter.Outcome != "" && e.Outcome != filter.Outcome,
+			!filter.From.IsZero() && e.OccurredAt.Before(filter.From),
+			!filter.To.IsZero() && e.OccurredAt.After(filter.To):
+			continue
+		}
+		matches = append(matches, e)
+	}
+
+	total := len(matches)
+	start := min(filter.Offset, total)
+	end := min(start+filter.Limit, total)
+	return matches[start:end], total, nil
+}
+
+// WalkAuditEvents calls fn for every event in log order, stopping at the first error
+func (s *MemoryStore) WalkAuditEvents(ctx context.Context, fn func(models.AuditEvent) error) error {
+	s.audit.mu.RLock()
+	events := append([]models.AuditEvent(nil), s.audit.events...)
+	s.audit.mu.RUnlock()
+
+	for _, event := range events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"avidlogic/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// auditLockID is the transaction advisory lock key serializing appends, so
+// that every event is chained to the one actually before it
+const auditLockID = 7244218396
+
+const auditColumns = `id, occurred_at, COALESCE(actor_id::text, ''), actor, action, target_type, target_id, ip,
+    user_agent, outcome, details, prev_hash, hash`
+
+func scanAuditEvent(row pgx.Row) (models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := row.Scan(&e.ID, &e.OccurredAt, &e.ActorID, &e.Actor, &e.Action, &e.TargetType, &e.TargetID, &e.IP,
+		&e.UserAgent, &e.Outcome, &e.Details, &e.PrevHash, &e.Hash)
+	return e, translate(err)
+}
+
+// AppendAuditEvent chains the event to the last one and stores it
+func (s *PostgresStore) AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return s.DB.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockID); err != nil {
+			return err
+		}
+
+		prevHash := models.AuditGenesisHash
+		err := tx.QueryRow(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		sealAuditEvent(event, prevHash)
+
+		details := event.Details
+		if details == nil {
+			details = map[string]string{}
+		}
+		query := `INSERT INTO audit_events (occurred_at, actor_id, actor, action, target_type, target_id, ip, user_agent,
+                      outcome, details, prev_hash, hash)
+                  VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+		return tx.QueryRow(ctx, query, event.OccurredAt, event.ActorID, event.Actor, event.Action, event.TargetType,
+			event.TargetID, event.IP, event.UserAgent, event.Outcome, details, event.PrevHash, event.Hash).Scan(&event.ID)
+	})
+}
+
+// ListAuditEvents returns one page of events matching the filter, newest first, and the number of matches
+func (s *PostgresStore) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, int, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+	if filter.ActorID != "" {
+		add("actor_id::text = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		add("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add("target_id = ?", filter.TargetID)
+	}
+	if filter.Outcome != "" {
+		add("outcome = ?", filter.Outcome)
+	}
+	if !filter.From.IsZero() {
+		add("occurred_at >= ?", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		add("occurred_at <= ?", filter.To.UTC())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.DB.QueryRow(ctx, `SELECT COUNT(*) FROM audit_events`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page := len(args)
+	query := `SELECT ` + auditColumns + ` FROM audit_events` + where +
+		` ORDER BY id DESC LIMIT $` + strconv.Itoa(page+1) + ` OFFSET $` + strconv.Itoa(page+2)
+	rows, err := s.DB.Query(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, rows.Err()
+}
+
+// WalkAuditEvents calls fn for every event in log order, stopping at the first error
+func (s *PostgresStore) WalkAuditEvents(ctx context.Context, fn func(models.AuditEvent) error) error {
+	rows, err := s.DB.Query(ctx, `SELECT `+auditColumns+` FROM audit_events ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/google/uuid"
+)
+
+// digestPreferences are the in-memory digest preferences embedded in MemoryStore
+type digestPreferences struct {
+	mu    sync.RWMutex
+	prefs map[string]models.DigestPreference // keyed by user ID
+}
+
+// GetDigestPreference returns the digest preference of a user
+func (s *MemoryStore) GetDigestPreference(ctx context.Context, userID string) (models.DigestPreference, error) {
+	s.digests.mu.RLock()
+	defer s.digests.mu.RUnlock()
+
+	pref, ok := s.digests.prefs[userID]
+	if !ok {
+		return models.DigestPreference{}, ErrNotFound
+	}
+	return pref, nil
+}
+
+// SaveDigestPreference creates or replaces the digest preference of a user,
+// keeping when the last digest was sent
+func (s *MemoryStore) SaveDigestPreference(ctx context.Context, pref models.DigestPreference) error {
+	s.digests.mu.Lock()
+	defer s.digests.mu.Unlock()
+
+	if s.digests.prefs == nil {
+		s.digests.prefs = make(map[string]models.DigestPreference)
+	}
+	pref.LastSentAt = s.digests.prefs[pref.UserID].LastSentAt
+	s.digests.prefs[pref.UserID] = pref
+	return nil
+}
+
+// ClaimDueDigests marks the digests due at now as sent and returns their recipients
+func (s *MemoryStore) ClaimDueDigests(ctx context.Context, now time.Time, minInterval time.Duration) ([]DigestRecipient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.digests.mu.Lock()
+	defer s.digests.mu.Unlock()
+
+	now = now.UTC()
+	var recipients []DigestRecipient
+	for userID, pref := range s.digests.prefs {
+		if !pref.Enabled || pref.Weekday != int(now.Weekday()) || pref.Hour != now.Hour() {
+			continue
+		}
+		if pref.LastSentAt != nil && !pref.LastSentAt.Before(now.Add(-minInterval)) {
+			continue
+		}
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			continue
+		}
+		if user, ok := s.users[id]; ok && user.DeletedAt == nil && user.DisabledAt == nil {
+			recipients = append(recipients, DigestRecipient{Preference: pref, Email: user.Email, Username: user.Username})
+			claimedAt := now
+			pref.LastSentAt = &claimedAt
+			s.digests.prefs[userID] = pref
+		}
+	}
+	return recipients, nil
+}
+
+// ReleaseDigest restores the last delivery of a user's digest claimed at claimedAt
+func (s *MemoryStore) ReleaseDigest(ctx context.Context, userID string, claimedAt time.Time, lastSentAt *time.Time) error {
+	s.digests.mu.Lock()
+	defer s.digests.mu.Unlock()
+
+	if pref, ok := s.digests.prefs[userID]; ok && pref.LastSentAt != nil && pref.LastSentAt.Equal(claimedAt) {
+		pref.LastSentAt = lastSentAt
+		s.digests.prefs[userID] = pref
+	}
+	return nil
+}
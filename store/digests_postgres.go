@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"avidlogic/models"
+)
+
+// GetDigestPreference returns the digest preference of a user
+func (s *PostgresStore) GetDigestPreference(ctx context.Context, userID string) (models.DigestPreference, error) {
+	var pref models.DigestPreference
+	var webhookURL *string
+	query := `SELECT user_id, enabled, channel, webhook_url, weekday, hour, last_sent_at
+              FROM user_digest_preferences WHERE user_id=$1`
+	err := s.DB.QueryRow(ctx, query, userID).Scan(&pref.UserID, &pref.Enabled, &pref.Channel, &webhookURL,
+		&pref.Weekday, &pref.Hour, &pref.LastSentAt)
+	if webhookURL != nil {
+		pref.WebhookURL = *webhookURL
+	}
+	return pref, translate(err)
+}
+
+// SaveDigestPreference creates or replaces the digest preference of a user
+func (s *PostgresStore) SaveDigestPreference(ctx context.Context, pref models.DigestPreference) error {
+	query := `INSERT INTO user_digest_preferences (user_id, enabled, channel, webhook_url, weekday, hour, updated_at)
+              VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
+              ON CONFLICT (user_id) DO UPDATE SET
+                  enabled = EXCLUDED.enabled, channel = EXCLUDED.channel, webhook_url = EXCLUDED.webhook_url,
+                  weekday = EXCLUDED.weekday, hour = EXCLUDED.hour, updated_at = EXCLUDED.updated_at`
+	_, err := s.DB.Exec(ctx, query, pref.UserID, pref.Enabled, pref.Channel, pref.WebhookURL, pref.Weekday, pref.Hour, time.Now())
+	return err
+}
+
+// ClaimDueDigests marks the digests due at now as sent and returns their
+// recipients. Rows claimed by a concurrent caller are locked and skipped, and
+// found no longer due once it commits.
+func (s *PostgresStore) ClaimDueDigests(ctx context.Context, now time.Time, minInterval time.Duration) ([]DigestRecipient, error) {
+	now = now.UTC()
+	query := `WITH due AS (
+                  SELECT p.user_id, p.last_sent_at
+                  FROM user_digest_preferences p
+                  JOIN users u ON u.id = p.user_id
+                  WHERE p.enabled AND p.weekday = $1 AND p.hour = $2 AND u.deleted_at IS NULL AND u.disabled_at IS NULL
+                      AND (p.last_sent_at IS NULL OR p.last_sent_at < $3)
+                  FOR UPDATE OF p SKIP LOCKED
+              )
+              UPDATE user_digest_preferences p SET last_sent_at = $4
+              FROM due, users u
+              WHERE p.user_id = due.user_id AND u.id = p.user_id
+              RETURNING p.user_id, p.enabled, p.channel, COALESCE(p.webhook_url, ''), p.weekday, p.hour, due.last_sent_at,
+                  u.email, u.username`
+	rows, err := s.DB.Query(ctx, query, int(now.Weekday()), now.Hour(), now.Add(-minInterval), now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []DigestRecipient
+	for rows.Next() {
+		var r DigestRecipient
+		p := &r.Preference
+		if err := rows.Scan(&p.UserID, &p.Enabled, &p.Channel, &p.WebhookURL, &p.Weekday, &p.Hour, &p.LastSentAt,
+			&r.Email, &r.Username); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+
+	return recipients, rows.Err()
+}
+
+// ReleaseDigest restores the last delivery of a user's digest claimed at claimedAt
+func (s *PostgresStore) ReleaseDigest(ctx context.Context, userID string, claimedAt time.Time, lastSentAt *time.Time) error {
+	query := `UPDATE user_digest_preferences SET last_sent_at=$3 WHERE user_id=$1 AND last_sent_at=$2`
+	_, err := s.DB.Exec(ctx, query, userID, claimedAt.UTC(), lastSentAt)
+	return err
+}
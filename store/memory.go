@@ -0,0 +1,377 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore implements every store of this package in memory. It enforces the same uniqueness
+// rules as the Postgres schema, where usernames and emails are only unique among users that are not
+// deleted, and is safe for concurrent use. Methods needing both take mu before the mutex of a table.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	users     map[uuid.UUID]models.User
+	projects  map[int]models.UserProject
+	nextID    int
+	audit     auditLog
+	rates     rateBuckets
+	digests   digestPreferences
+	reports   reportTable
+	workflows workflowTables
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:    make(map[uuid.UUID]models.User),
+		projects: make(map[int]models.UserProject),
+		nextID:   1,
+	}
+}
+
+// CreateUser adds a user, failing with a *ConflictError on a duplicate ID or
+// on the username or email of a user that is not deleted
+func (s *MemoryStore) CreateUser(ctx context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user.Email = NormalizeEmail(user.Email)
+	for _, existing := range s.users {
+		switch {
+		case existing.ID == user.ID:
+			return &ConflictError{Field: "id"}
+		case existing.DeletedAt != nil:
+			continue
+		case existing.Email == user.Email:
+			return &ConflictError{Field: "email"}
+		case strings.EqualFold(existing.Username, user.Username):
+			return &ConflictError{Field: "username"}
+		}
+	}
+	s.users[user.ID] = user
+	return nil
+}
+
+// GetUserByID returns the user with the given ID
+func (s *MemoryStore) GetUserByID(ctx context.Context, id uuid.UUID) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt != nil {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// GetUserByEmail returns the user with the given email
+func (s *MemoryStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	email = NormalizeEmail(email)
+	for _, user := range s.users {
+		if user.Email == email && user.DeletedAt == nil {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// GetUserByEmailVerification returns the user a pending email verification was issued to
+func (s *MemoryStore) GetUserByEmailVerification(ctx context.Context, tokenHash string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if tokenHash != "" && user.EmailVerificationHash == tokenHash && user.DeletedAt == nil {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// updateUser applies change to the live user with the given ID and returns
+// the updated user. change may fail to leave the user as it was.
+func (s *MemoryStore) updateUser(id uuid.UUID, change func(user *models.User) error) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt != nil {
+		return models.User{}, ErrNotFound
+	}
+	if err := change(&user); err != nil {
+		return models.User{}, err
+	}
+	s.users[id] = user
+	return user, nil
+}
+
+// taken reports whether another live user has the field value. s.mu must be held.
+func (s *MemoryStore) taken(id uuid.UUID, field, value string) bool {
+	for _, existing := range s.users {
+		if existing.ID == id || existing.DeletedAt != nil {
+			continue
+		}
+		if field == "email" && existing.Email == value || field == "username" && strings.EqualFold(existing.Username, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUsername renames the user, failing with a *ConflictError if the name is taken
+func (s *MemoryStore) SetUsername(ctx context.Context, id uuid.UUID, username string, now time.Time) (models.User, error) {
+	return s.updateUser(id, func(user *models.User) error {
+		if s.taken(id, "username", username) {
+			return &ConflictError{Field: "username"}
+		}
+		user.Username = username
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+// SetPendingEmail stores an email change awaiting verification
+func (s *MemoryStore) SetPendingEmail(ctx context.Context, id uuid.UUID, email, tokenHash string, expiresAt, now time.Time) (models.User, error) {
+	return s.updateUser(id, func(user *models.User) error {
+		user.PendingEmail = NormalizeEmail(email)
+		user.EmailVerificationHash = tokenHash
+		user.EmailVerificationExpiresAt = &expiresAt
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+// ConfirmEmail applies the pending email change the token hash was issued for
+func (s *MemoryStore) ConfirmEmail(ctx context.Context, id uuid.UUID, tokenHash string, now time.Time) (models.User, error) {
+	return s.updateUser(id, func(user *models.User) error {
+		if user.EmailVerificationHash != tokenHash || user.PendingEmail == "" {
+			return ErrNotFound
+		}
+		if s.taken(id, "email", user.PendingEmail) {
+			return &ConflictError{Field: "email"}
+		}
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+		user.EmailVerificationHash = ""
+		user.EmailVerificationExpiresAt = nil
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+// SetPasswordHash replaces the password hash and revokes every token of the user
+func (s *MemoryStore) SetPasswordHash(ctx context.Context, id uuid.UUID, passwordHash string, now time.Time) (models.User, error) {
+	return s.updateUser(id, func(user *models.User) error {
+		user.PasswordHash = passwordHash
+		user.TokenVersion++
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+// SetDisabled disables the user, revoking their tokens, or re-enables them
+func (s *MemoryStore) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool, now time.Time) (models.User, error) {
+	return s.updateUser(id, func(user *models.User) error {
+		switch {
+		case disabled && user.DisabledAt == nil:
+			user.DisabledAt = &now
+			user.TokenVersion++
+		case !disabled:
+			user.DisabledAt = nil
+		}
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+// DeleteUser soft-deletes the user together with their projects
+func (s *MemoryStore) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || user.DeletedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	s.users[id] = user
+	for projectID, project := range s.projects {
+		if project.UserID == id.String() && project.DeletedAt == nil {
+			project.DeletedAt = &now
+			s.projects[projectID] = project
+		}
+	}
+	return nil
+}
+
+// PurgeUsers permanently removes the users deleted before the given time, with their projects
+func (s *MemoryStore) PurgeUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for id, user := range s.users {
+		if user.DeletedAt == nil || !user.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		delete(s.users, id)
+		for projectID, project := range s.projects {
+			if project.UserID == id.String() {
+				delete(s.projects, projectID)
+				s.purgeProjectData(projectID)
+			}
+		}
+		s.purgeUserData(id.String())
+		purged++
+	}
+	return purged, nil
+}
+
+// ListUsers returns one page of users matching the filter, oldest first, and the number of matches
+func (s *MemoryStore) ListUsers(ctx context.Context, filter UserFilter) ([]models.User, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := strings.ToLower(filter.Query)
+	var matches []models.User
+	for _, user := range s.users {
+		if user.DeletedAt != nil {
+			continue
+		}
+		if strings.Contains(strings.ToLower(user.Username), query) || strings.Contains(user.Email, query) {
+			matches = append(matches, user)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+		}
+		return matches[i].ID.String() < matches[j].ID.String()
+	})
+
+	total := len(matches)
+	start := min(filter.Offset, total)
+	end := min(start+filter.Limit, total)
+	return matches[start:end], total, nil
+}
+
+// CreateProject adds a project and assigns it the next ID
+func (s *MemoryStore) CreateProject(ctx context.Context, project *models.UserProject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project.ID = s.nextID
+	project.BaseURL = strings.TrimRight(project.BaseURL, "/")
+	s.nextID++
+	s.projects[project.ID] = *project
+	return nil
+}
+
+// GetProject returns the project only if it belongs to userID
+func (s *MemoryStore) GetProject(ctx context.Context, id int, userID string) (models.UserProject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	project, ok := s.projects[id]
+	if !ok || project.UserID != userID || project.DeletedAt != nil {
+		return models.UserProject{}, ErrNotFound
+	}
+	return project, nil
+}
+
+// ListProjects returns every project of a user, oldest first
+func (s *MemoryStore) ListProjects(ctx context.Context, userID string) ([]models.UserProject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var projects []models.UserProject
+	for id := 1; id < s.nextID; id++ {
+		if project, ok := s.projects[id]; ok && project.UserID == userID && project.DeletedAt == nil {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+// DeleteProject soft-deletes the project if it belongs to userID
+func (s *MemoryStore) DeleteProject(ctx context.Context, id int, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, ok := s.projects[id]
+	if !ok || project.UserID != userID || project.DeletedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	project.DeletedAt = &now
+	s.projects[id] = project
+	return nil
+}
+
+// PurgeProjects permanently removes the projects deleted before the given time
+func (s *MemoryStore) PurgeProjects(ctx context.Context, deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for id, project := range s.projects {
+		if project.DeletedAt != nil && project.DeletedAt.Before(deletedBefore) {
+			delete(s.projects, id)
+			s.purgeProjectData(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purgeUserData removes the digest preference and reports of a purged user,
+// as the ON DELETE CASCADE foreign keys do in Postgres
+func (s *MemoryStore) purgeUserData(userID string) {
+	s.digests.mu.Lock()
+	delete(s.digests.prefs, userID)
+	s.digests.mu.Unlock()
+
+	s.reports.mu.Lock()
+	for id, stored := range s.reports.reports {
+		if stored.UserID == userID {
+			delete(s.reports.reports, id)
+		}
+	}
+	s.reports.mu.Unlock()
+}
+
+// purgeProjectData removes the reports and workflow activity of a purged
+// project, as the ON DELETE CASCADE foreign keys do in Postgres
+func (s *MemoryStore) purgeProjectData(projectID int) {
+	s.reports.mu.Lock()
+	for id, stored := range s.reports.reports {
+		if stored.ProjectID == projectID {
+			delete(s.reports.reports, id)
+		}
+	}
+	s.reports.mu.Unlock()
+
+	s.workflows.mu.Lock()
+	for key := range s.workflows.runs {
+		if key.projectID == projectID {
+			delete(s.workflows.runs, key)
+		}
+	}
+	for key := range s.workflows.jobs {
+		if key.projectID == projectID {
+			delete(s.workflows.jobs, key)
+		}
+	}
+	s.workflows.mu.Unlock()
+}
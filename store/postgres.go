@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// uniqueViolation is the Postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// PostgresStore implements every store of this package on a Postgres pool
+type PostgresStore struct {
+	DB *pgxpool.Pool
+}
+
+// NewPostgresStore returns a store backed by the given pool
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// conflictFields maps unique constraints to the field they protect
+var conflictFields = map[string]string{
+	"users_email_key":          "email",
+	"users_email_lower_key":    "email",
+	"users_username_key":       "username",
+	"users_username_lower_key": "username",
+}
+
+// translate maps driver errors to the store errors
+func translate(err error) error {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == uniqueViolation:
+		return &ConflictError{Field: conflictFields[pgErr.ConstraintName]}
+	default:
+		return err
+	}
+}
+
+// CreateUser inserts a new user. Uniqueness is left to the database indexes so
+// that concurrent signups cannot both succeed.
+func (s *PostgresStore) CreateUser(ctx context.Context, user models.User) error {
+	query := `INSERT INTO users (id, username, email, password_hash, created_at, updated_at) 
+              VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := s.DB.Exec(ctx, query, user.ID, user.Username, NormalizeEmail(user.Email), user.PasswordHash, user.CreatedAt, user.UpdatedAt)
+	return translate(err)
+}
+
+const userColumns = `id, username, email, COALESCE(pending_email, ''), password_hash, is_admin, disabled_at,
+    created_at, updated_at, COALESCE(email_verification_hash, ''), email_verification_expires_at, token_version`
+
+func scanUser(row pgx.Row) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PendingEmail, &user.PasswordHash, &user.IsAdmin, &user.DisabledAt,
+		&user.CreatedAt, &user.UpdatedAt, &user.EmailVerificationHash, &user.EmailVerificationExpiresAt, &user.TokenVersion)
+	return user, translate(err)
+}
+
+// GetUserByID returns the user with the given ID
+func (s *PostgresStore) GetUserByID(ctx context.Context, id uuid.UUID) (models.User, error) {
+	return scanUser(s.DB.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id=$1 AND deleted_at IS NULL`, id))
+}
+
+// GetUserByEmail returns the user with the given email
+func (s *PostgresStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return scanUser(s.DB.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE LOWER(email)=$1 AND deleted_at IS NULL`, NormalizeEmail(email)))
+}
+
+// GetUserByEmailVerification returns the user a pending email verification was issued to
+func (s *PostgresStore) GetUserByEmailVerification(ctx context.Context, tokenHash string) (models.User, error) {
+	return scanUser(s.DB.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email_verification_hash=$1 AND deleted_at IS NULL`, tokenHash))
+}
+
+// updateUser runs an UPDATE of the live user with the given ID, whose SET
+// clause may refer to $1 as the ID, and returns the updated user
+func (s *PostgresStore) updateUser(ctx context.Context, set string, id uuid.UUID, args ...any) (models.User, error) {
+	query := `UPDATE users SET ` + set + ` WHERE id=$1 AND deleted_at IS NULL RETURNING ` + userColumns
+	return scanUser(s.DB.QueryRow(ctx, query, append([]any{id}, args...)...))
+}
+
+// SetUsername renames the user
+func (s *PostgresStore) SetUsername(ctx context.Context, id uuid.UUID, username string, now time.Time) (models.User, error) {
+	return s.updateUser(ctx, `username=$2, updated_at=$3`, id, username, now)
+}
+
+// SetPendingEmail stores an email change awaiting verification
+func (s *PostgresStore) SetPendingEmail(ctx context.Context, id uuid.UUID, email, tokenHash string, expiresAt, now time.Time) (models.User, error) {
+	return s.updateUser(ctx, `pending_email=$2, email_verification_hash=$3, email_verification_expires_at=$4, updated_at=$5`,
+		id, NormalizeEmail(email), tokenHash, expiresAt, now)
+}
+
+// ConfirmEmail applies the pending email change the token hash was issued for
+func (s *PostgresStore) ConfirmEmail(ctx context.Context, id uuid.UUID, tokenHash string, now time.Time) (models.User, error) {
+	query := `UPDATE users SET email=pending_email, pending_email=NULL, email_verification_hash=NULL,
+                  email_verification_expires_at=NULL, updated_at=$3
+              WHERE id=$1 AND email_verification_hash=$2 AND pending_email IS NOT NULL AND deleted_at IS NULL
+              RETURNING ` + userColumns
+	return scanUser(s.DB.QueryRow(ctx, query, id, tokenHash, now))
+}
+
+// SetPasswordHash replaces the password hash and revokes every token of the user
+func (s *PostgresStore) SetPasswordHash(ctx context.Context, id uuid.UUID, passwordHash string, now time.Time) (models.User, error) {
+	return s.updateUser(ctx, `password_hash=$2, token_version=token_version+1, updated_at=$3`, id, passwordHash, now)
+}
+
+// SetDisabled disables the user, revoking their tokens, or re-enables them
+func (s *PostgresStore) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool, now time.Time) (models.User, error) {
+	if !disabled {
+		return s.updateUser(ctx, `disabled_at=NULL, updated_at=$2`, id, now)
+	}
+	return s.updateUser(ctx, `disabled_at=COALESCE(disabled_at, $2),
+                  token_version=token_version + CASE WHEN disabled_at IS NULL THEN 1 ELSE 0 END, updated_at=$2`, id, now)
+}
+
+// DeleteUser soft-deletes the user and their projects
+func (s *PostgresStore) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return s.DB.BeginFunc(ctx, func(tx pgx.Tx) error {
+		now := time.Now()
+		tag, err := tx.Exec(ctx, `UPDATE users SET deleted_at=$2 WHERE id=$1 AND deleted_at IS NULL`, id, now)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		_, err = tx.Exec(ctx, `UPDATE user_projects SET deleted_at=$2 WHERE user_id=$1 AND deleted_at IS NULL`, id, now)
+		return err
+	})
+}
+
+// PurgeUsers permanently removes the users deleted before the given time.
+// Projects, reports and digest preferences go with them through the
+// ON DELETE CASCADE foreign keys.
+func (s *PostgresStore) PurgeUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM users WHERE deleted_at < $1`, deletedBefore)
+	return int(tag.RowsAffected()), err
+}
+
+// likeEscaper escapes the LIKE wildcards of a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUsers returns one page of users matching the filter, oldest first, and the number of matches
+func (s *PostgresStore) ListUsers(ctx context.Context, filter UserFilter) ([]models.User, int, error) {
+	pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+	where := ` WHERE deleted_at IS NULL AND (username ILIKE $1 OR email ILIKE $1)`
+
+	var total int
+	if err := s.DB.QueryRow(ctx, `SELECT COUNT(*) FROM users`+where, pattern).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.DB.Query(ctx, `SELECT `+userColumns+` FROM users`+where+` ORDER BY created_at, id LIMIT $2 OFFSET $3`,
+		pattern, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// CreateProject inserts a new project and sets its ID
+func (s *PostgresStore) CreateProject(ctx context.Context, project *models.UserProject) error {
+	query := `INSERT INTO user_projects (user_id, provider, base_url, project_type, username, pat, repo_names, created_at) 
+              VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8) RETURNING id`
+	err := s.DB.QueryRow(ctx, query, project.UserID, project.Provider, project.BaseURL, project.ProjectType,
+		project.Username, project.PAT, project.RepoNames, project.CreatedAt).Scan(&project.ID)
+	return translate(err)
+}
+
+const projectColumns = `id, user_id, provider, COALESCE(base_url, ''), project_type, username, pat, repo_names, created_at`
+
+func scanProject(row pgx.Row) (models.UserProject, error) {
+	var p models.UserProject
+	err := row.Scan(&p.ID, &p.UserID, &p.Provider, &p.BaseURL, &p.ProjectType, &p.Username, &p.PAT, &p.RepoNames, &p.CreatedAt)
+	return p, translate(err)
+}
+
+// GetProject returns the project only if it belongs to userID
+func (s *PostgresStore) GetProject(ctx context.Context, id int, userID string) (models.UserProject, error) {
+	return scanProject(s.DB.QueryRow(ctx, `SELECT `+projectColumns+` FROM user_projects WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL`, id, userID))
+}
+
+// ListProjects returns every project of a user, oldest first
+func (s *PostgresStore) ListProjects(ctx context.Context, userID string) ([]models.UserProject, error) {
+	rows, err := s.DB.Query(ctx, `SELECT `+projectColumns+` FROM user_projects WHERE user_id=$1 AND deleted_at IS NULL ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []models.UserProject
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// DeleteProject soft-deletes the project if it belongs to userID
+func (s *PostgresStore) DeleteProject(ctx context.Context, id int, userID string) error {
+	tag, err := s.DB.Exec(ctx, `UPDATE user_projects SET deleted_at=$3 WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL`,
+		id, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeProjects permanently removes the projects deleted before the given
+// time, with their workflow runs and reports
+func (s *PostgresStore) PurgeProjects(ctx context.Context, deletedBefore time.Time) (int, error) {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM user_projects WHERE deleted_at < $1`, deletedBefore)
+	return int(tag.RowsAffected()), err
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"avidlogic/models"
+)
+
+// rateBuckets are the in-memory rate limit buckets embedded in MemoryStore
+type rateBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]models.RateBucket
+}
+
+// UpdateRateBucket calls fn with the bucket stored under key and saves the result
+func (s *MemoryStore) UpdateRateBucket(ctx context.Context, key string, fn func(bucket *models.RateBucket)) error {
+	s.rates.mu.Lock()
+	defer s.rates.mu.Unlock()
+
+	if s.rates.buckets == nil {
+		s.rates.buckets = make(map[string]models.RateBucket)
+	}
+	bucket, ok := s.rates.buckets[key]
+	if !ok {
+		bucket = models.RateBucket{Key: key}
+	}
+	fn(&bucket)
+	s.rates.buckets[key] = bucket
+	return nil
+}
+
+// PurgeRateBuckets removes the buckets that expired before the given time
+func (s *MemoryStore) PurgeRateBuckets(ctx context.Context, expiredBefore time.Time) (int, error) {
+	s.rates.mu.Lock()
+	defer s.rates.mu.Unlock()
+
+	purged := 0
+	for key, bucket := range s.rates.buckets {
+		if bucket.ExpiresAt.Before(expiredBefore) {
+			delete(s.rates.buckets, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
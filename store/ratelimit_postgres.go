@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// UpdateRateBucket calls fn with the bucket stored under key and saves the
+// result. The row stays locked meanwhile, so concurrent requests of every
+// server instance take their tokens one after the other.
+func (s *PostgresStore) UpdateRateBucket(ctx context.Context, key string, fn func(bucket *models.RateBucket)) error {
+	return s.DB.BeginFunc(ctx, func(tx pgx.Tx) error {
+		bucket := models.RateBucket{Key: key}
+		err := tx.QueryRow(ctx, `SELECT tokens, updated_at, expires_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`,
+			key).Scan(&bucket.Tokens, &bucket.UpdatedAt, &bucket.ExpiresAt)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		fn(&bucket)
+
+		// Two first requests can both miss the row, the later one then overwrites
+		// the earlier, which costs at most one token
+		query := `INSERT INTO rate_limit_buckets (key, tokens, updated_at, expires_at) VALUES ($1, $2, $3, $4)
+                  ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, updated_at = EXCLUDED.updated_at,
+                      expires_at = EXCLUDED.expires_at`
+		_, err = tx.Exec(ctx, query, key, bucket.Tokens, bucket.UpdatedAt, bucket.ExpiresAt)
+		return err
+	})
+}
+
+// PurgeRateBuckets removes the buckets that expired before the given time
+func (s *PostgresStore) PurgeRateBuckets(ctx context.Context, expiredBefore time.Time) (int, error) {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE expires_at < $1`, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
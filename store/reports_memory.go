@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/google/uuid"
+)
+
+// reportTable is the in-memory report table embedded in MemoryStore
+type reportTable struct {
+	mu      sync.RWMutex
+	reports map[uuid.UUID]storedReport
+}
+
+// storedReport is a report with its artifact and last heartbeat
+type storedReport struct {
+	models.Report
+	contentType string
+	artifact    []byte
+	heartbeatAt time.Time
+}
+
+// CreateReport stores a new pending report
+func (s *MemoryStore) CreateReport(ctx context.Context, report models.Report) error {
+	s.reports.mu.Lock()
+	defer s.reports.mu.Unlock()
+
+	if s.reports.reports == nil {
+		s.reports.reports = make(map[uuid.UUID]storedReport)
+	}
+	if _, ok := s.reports.reports[report.ID]; ok {
+		return &ConflictError{Field: "id"}
+	}
+	s.reports.reports[report.ID] = storedReport{Report: report, heartbeatAt: report.CreatedAt}
+	return nil
+}
+
+// GetReport returns a report owned by the given user, without its artifact
+func (s *MemoryStore) GetReport(ctx context.Context, id uuid.UUID, userID string) (models.Report, error) {
+	s.reports.mu.RLock()
+	defer s.reports.mu.RUnlock()
+
+	stored, ok := s.reports.reports[id]
+	if !ok || stored.UserID != userID {
+		return models.Report{}, ErrNotFound
+	}
+	return stored.Report, nil
+}
+
+// ListUserReports returns the reports of a user, without their artifacts, oldest first
+func (s *MemoryStore) ListUserReports(ctx context.Context, userID string) ([]models.Report, error) {
+	s.reports.mu.RLock()
+	defer s.reports.mu.RUnlock()
+
+	var reports []models.Report
+	for _, stored := range s.reports.reports {
+		if stored.UserID == userID {
+			reports = append(reports, stored.Report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].CreatedAt.Before(reports[j].CreatedAt) })
+	return reports, nil
+}
+
+// GetReportArtifact returns the content type and bytes of a completed report
+func (s *MemoryStore) GetReportArtifact(ctx context.Context, id uuid.UUID, userID string) (string, []byte, error) {
+	s.reports.mu.RLock()
+	defer s.reports.mu.RUnlock()
+
+	stored, ok := s.reports.reports[id]
+	if !ok || stored.UserID != userID || stored.Status != models.ReportCompleted {
+		return "", nil, ErrNotFound
+	}
+	return stored.contentType, stored.artifact, nil
+}
+
+// SetReportStatus updates the status of a report that is still being generated
+func (s *MemoryStore) SetReportStatus(ctx context.Context, id uuid.UUID, status string) error {
+	return s.updateReport(id, func(stored *storedReport) {
+		stored.Status = status
+	})
+}
+
+// CompleteReport stores the generated artifact and marks the report completed
+func (s *MemoryStore) CompleteReport(ctx context.Context, id uuid.UUID, contentType string, artifact []byte) error {
+	return s.updateReport(id, func(stored *storedReport) {
+		now := time.Now()
+		stored.Status, stored.CompletedAt = models.ReportCompleted, &now
+		stored.contentType, stored.artifact = contentType, artifact
+	})
+}
+
+// FailReport marks a report as failed with the given reason
+func (s *MemoryStore) FailReport(ctx context.Context, id uuid.UUID, reason string) error {
+	return s.updateReport(id, func(stored *storedReport) {
+		now := time.Now()
+		stored.Status, stored.Error, stored.CompletedAt = models.ReportFailed, reason, &now
+	})
+}
+
+// BeatReports records that the reports with the given IDs are still being generated
+func (s *MemoryStore) BeatReports(ctx context.Context, ids []uuid.UUID, now time.Time) error {
+	s.reports.mu.Lock()
+	defer s.reports.mu.Unlock()
+
+	for _, id := range ids {
+		if stored, ok := s.reports.reports[id]; ok && unfinished(stored.Status) {
+			stored.heartbeatAt = now
+			s.reports.reports[id] = stored
+		}
+	}
+	return nil
+}
+
+// FailStaleReports marks the reports still pending or running whose last
+// heartbeat is before the given time as failed
+func (s *MemoryStore) FailStaleReports(ctx context.Context, heartbeatBefore time.Time, reason string) (int, error) {
+	s.reports.mu.Lock()
+	defer s.reports.mu.Unlock()
+
+	now, failed := time.Now(), 0
+	for id, stored := range s.reports.reports {
+		if unfinished(stored.Status) && stored.heartbeatAt.Before(heartbeatBefore) {
+			stored.Status, stored.Error, stored.CompletedAt = models.ReportFailed, reason, &now
+			s.reports.reports[id] = stored
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+// unfinished reports whether a report with the status is still being generated
+func unfinished(status string) bool {
+	return status == models.ReportPending || status == models.ReportRunning
+}
+
+// updateReport applies fn to a stored report. Like the UPDATE statements of
+// PostgresStore, it does nothing for an unknown report.
+func (s *MemoryStore) updateReport(id uuid.UUID, fn func(stored *storedReport)) error {
+	s.reports.mu.Lock()
+	defer s.reports.mu.Unlock()
+
+	if stored, ok := s.reports.reports[id]; ok {
+		fn(&stored)
+		s.reports.reports[id] = stored
+	}
+	return nil
+}
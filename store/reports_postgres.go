@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/google/uuid"
+)
+
+// CreateReport stores a new pending report
+func (s *PostgresStore) CreateReport(ctx context.Context, report models.Report) error {
+	query := `INSERT INTO reports (id, user_id, project_id, kind, format, status, from_date, to_date, created_at, heartbeat_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)`
+	_, err := s.DB.Exec(ctx, query, report.ID, report.UserID, report.ProjectID, report.Kind, report.Format,
+		report.Status, report.From, report.To, report.CreatedAt)
+	return err
+}
+
+// GetReport returns a report owned by the given user, without its artifact
+func (s *PostgresStore) GetReport(ctx context.Context, id uuid.UUID, userID string) (models.Report, error) {
+	var report models.Report
+	var reportError *string
+	query := `SELECT id, user_id, project_id, kind, format, status, error, from_date, to_date, created_at, completed_at
+              FROM reports WHERE id=$1 AND user_id=$2`
+	err := s.DB.QueryRow(ctx, query, id, userID).Scan(&report.ID, &report.UserID, &report.ProjectID, &report.Kind,
+		&report.Format, &report.Status, &reportError, &report.From, &report.To, &report.CreatedAt, &report.CompletedAt)
+	if reportError != nil {
+		report.Error = *reportError
+	}
+	return report, translate(err)
+}
+
+// ListUserReports returns the reports of a user, without their artifacts, oldest first
+func (s *PostgresStore) ListUserReports(ctx context.Context, userID string) ([]models.Report, error) {
+	query := `SELECT id, user_id, project_id, kind, format, status, COALESCE(error, ''), from_date, to_date, created_at, completed_at
+              FROM reports WHERE user_id=$1 ORDER BY created_at`
+	rows, err := s.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.Report
+	for rows.Next() {
+		var report models.Report
+		err := rows.Scan(&report.ID, &report.UserID, &report.ProjectID, &report.Kind, &report.Format, &report.Status,
+			&report.Error, &report.From, &report.To, &report.CreatedAt, &report.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// GetReportArtifact returns the content type and bytes of a completed report
+func (s *PostgresStore) GetReportArtifact(ctx context.Context, id uuid.UUID, userID string) (string, []byte, error) {
+	var contentType string
+	var artifact []byte
+	query := `SELECT content_type, artifact FROM reports WHERE id=$1 AND user_id=$2 AND status=$3`
+	err := s.DB.QueryRow(ctx, query, id, userID, models.ReportCompleted).Scan(&contentType, &artifact)
+	return contentType, artifact, translate(err)
+}
+
+// SetReportStatus updates the status of a report that is still being generated
+func (s *PostgresStore) SetReportStatus(ctx context.Context, id uuid.UUID, status string) error {
+	_, err := s.DB.Exec(ctx, `UPDATE reports SET status=$2 WHERE id=$1`, id, status)
+	return err
+}
+
+// CompleteReport stores the generated artifact and marks the report completed
+func (s *PostgresStore) CompleteReport(ctx context.Context, id uuid.UUID, contentType string, artifact []byte) error {
+	query := `UPDATE reports SET status=$2, content_type=$3, artifact=$4, completed_at=$5 WHERE id=$1`
+	_, err := s.DB.Exec(ctx, query, id, models.ReportCompleted, contentType, artifact, time.Now())
+	return err
+}
+
+// FailReport marks a report as failed with the given reason
+func (s *PostgresStore) FailReport(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `UPDATE reports SET status=$2, error=$3, completed_at=$4 WHERE id=$1`
+	_, err := s.DB.Exec(ctx, query, id, models.ReportFailed, reason, time.Now())
+	return err
+}
+
+// BeatReports records that the reports with the given IDs are still being generated
+func (s *PostgresStore) BeatReports(ctx context.Context, ids []uuid.UUID, now time.Time) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	query := `UPDATE reports SET heartbeat_at=$2 WHERE id = ANY($1::uuid[]) AND status IN ($3, $4)`
+	_, err := s.DB.Exec(ctx, query, keys, now, models.ReportPending, models.ReportRunning)
+	return err
+}
+
+// FailStaleReports marks the reports still pending or running whose last
+// heartbeat is before the given time as failed
+func (s *PostgresStore) FailStaleReports(ctx context.Context, heartbeatBefore time.Time, reason string) (int, error) {
+	query := `UPDATE reports SET status=$1, error=$2, completed_at=$3
+              WHERE status IN ($4, $5) AND heartbeat_at < $6`
+	tag, err := s.DB.Exec(ctx, query, models.ReportFailed, reason, time.Now(), models.ReportPending, models.ReportRunning, heartbeatBefore)
+	return int(tag.RowsAffected()), err
+}
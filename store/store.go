@@ -0,0 +1,198 @@
+// Package store abstracts the persistence of users, projects and everything
+// attached to them so handlers can run against Postgres in production and
+// memory elsewhere.
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("store: not found")
+
+// ErrConflict is returned when a record violates a uniqueness constraint
+var ErrConflict = errors.New("store: already exists")
+
+// ConflictError reports which field of a record is already taken. It matches ErrConflict.
+type ConflictError struct {
+	Field string // e.g. "email" or "username", empty if unknown
+}
+
+func (e *ConflictError) Error() string {
+	if e.Field == "" {
+		return ErrConflict.Error()
+	}
+	return "store: " + e.Field + " already exists"
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// NormalizeEmail returns the form emails are stored and looked up in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// UserStore persists user accounts. Emails and usernames are unique regardless
+// of case; CreateUser fails with a *ConflictError naming the field otherwise.
+type UserStore interface {
+	CreateUser(ctx context.Context, user models.User) error
+	GetUserByID(ctx context.Context, id uuid.UUID) (models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	// GetUserByEmailVerification returns the user a pending email verification was issued to
+	GetUserByEmailVerification(ctx context.Context, tokenHash string) (models.User, error)
+	// The setters below change only their own columns, so that concurrent
+	// changes to an account cannot undo each other. They return the updated
+	// user, or ErrNotFound if it does not exist.
+
+	// SetUsername renames the user, failing with a *ConflictError if the name is taken
+	SetUsername(ctx context.Context, id uuid.UUID, username string, now time.Time) (models.User, error)
+	// SetPendingEmail stores an email change awaiting verification with the
+	// SHA-256 of its token, replacing any previous one
+	SetPendingEmail(ctx context.Context, id uuid.UUID, email, tokenHash string, expiresAt, now time.Time) (models.User, error)
+	// ConfirmEmail applies the pending email change the token hash was issued
+	// for, failing with a *ConflictError if the email was taken meanwhile
+	ConfirmEmail(ctx context.Context, id uuid.UUID, tokenHash string, now time.Time) (models.User, error)
+	// SetPasswordHash replaces the password hash and revokes every token of the user
+	SetPasswordHash(ctx context.Context, id uuid.UUID, passwordHash string, now time.Time) (models.User, error)
+	// SetDisabled disables the user, revoking their tokens, or re-enables them.
+	// Disabling a disabled user changes nothing.
+	SetDisabled(ctx context.Context, id uuid.UUID, disabled bool, now time.Time) (models.User, error)
+	// DeleteUser soft-deletes the user together with their projects. Deleted
+	// users are hidden from every other method until purged.
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	// PurgeUsers permanently removes the users deleted before the given time and returns how many
+	PurgeUsers(ctx context.Context, deletedBefore time.Time) (int, error)
+	// ListUsers returns one page of users matching the filter, oldest first, and the number of matches
+	ListUsers(ctx context.Context, filter UserFilter) ([]models.User, int, error)
+}
+
+// UserFilter selects users in ListUsers
+type UserFilter struct {
+	Query  string // case-insensitive substring of the username or email, empty for all
+	Limit  int
+	Offset int
+}
+
+// ProjectStore persists the projects of users
+type ProjectStore interface {
+	// CreateProject stores the project and sets its ID
+	CreateProject(ctx context.Context, project *models.UserProject) error
+	// GetProject returns the project only if it belongs to userID
+	GetProject(ctx context.Context, id int, userID string) (models.UserProject, error)
+	// ListProjects returns every project of a user, oldest first
+	ListProjects(ctx context.Context, userID string) ([]models.UserProject, error)
+	// DeleteProject soft-deletes the project if it belongs to userID. Deleted
+	// projects are hidden from every other method until purged.
+	DeleteProject(ctx context.Context, id int, userID string) error
+	// PurgeProjects permanently removes the projects deleted before the given time and returns how many
+	PurgeProjects(ctx context.Context, deletedBefore time.Time) (int, error)
+}
+
+// AuditStore persists the append-only audit log
+type AuditStore interface {
+	// AppendAuditEvent chains the event to the last one (setting OccurredAt
+	// precision, PrevHash and Hash) and stores it, setting its ID
+	AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// ListAuditEvents returns one page of events matching the filter, newest first, and the number of matches
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, int, error)
+	// WalkAuditEvents calls fn for every event in log order, stopping at the first error
+	WalkAuditEvents(ctx context.Context, fn func(models.AuditEvent) error) error
+}
+
+// RateLimitStore persists the token buckets of the rate limiter
+type RateLimitStore interface {
+	// UpdateRateBucket calls fn with the bucket stored under key, or a new one
+	// with a zero UpdatedAt, and saves the bucket fn leaves. Updates of a key
+	// are serialized.
+	UpdateRateBucket(ctx context.Context, key string, fn func(bucket *models.RateBucket)) error
+	// PurgeRateBuckets removes the buckets that expired before the given time and returns how many
+	PurgeRateBuckets(ctx context.Context, expiredBefore time.Time) (int, error)
+}
+
+// DigestStore persists the weekly digest preferences of users
+type DigestStore interface {
+	// GetDigestPreference returns the preference of a user, or ErrNotFound if they never saved one
+	GetDigestPreference(ctx context.Context, userID string) (models.DigestPreference, error)
+	// SaveDigestPreference creates or replaces the preference of a user
+	SaveDigestPreference(ctx context.Context, pref models.DigestPreference) error
+	// ClaimDueDigests finds the opted-in users, neither deleted nor disabled,
+	// scheduled for the weekday and hour of now (UTC) who have not received a
+	// digest in the last minInterval, marks their digest sent at now and
+	// returns them with the LastSentAt from before. Each due digest is claimed
+	// once, even by concurrent callers.
+	ClaimDueDigests(ctx context.Context, now time.Time, minInterval time.Duration) ([]DigestRecipient, error)
+	// ReleaseDigest undoes the claim made at claimedAt on the digest of a user
+	// that could not be delivered, restoring lastSentAt so that it is due again
+	ReleaseDigest(ctx context.Context, userID string, claimedAt time.Time, lastSentAt *time.Time) error
+}
+
+// DigestRecipient is an opted-in user whose digest is due
+type DigestRecipient struct {
+	Preference models.DigestPreference
+	Email      string
+	Username   string
+}
+
+// ReportStore persists generated reports and their artifacts
+type ReportStore interface {
+	// CreateReport stores a new pending report
+	CreateReport(ctx context.Context, report models.Report) error
+	// GetReport returns a report owned by userID, without its artifact
+	GetReport(ctx context.Context, id uuid.UUID, userID string) (models.Report, error)
+	// ListUserReports returns the reports of a user, without their artifacts, oldest first
+	ListUserReports(ctx context.Context, userID string) ([]models.Report, error)
+	// GetReportArtifact returns the content type and bytes of a completed report owned by userID
+	GetReportArtifact(ctx context.Context, id uuid.UUID, userID string) (string, []byte, error)
+	// SetReportStatus updates the status of a report that is still being generated
+	SetReportStatus(ctx context.Context, id uuid.UUID, status string) error
+	// CompleteReport stores the generated artifact and marks the report completed
+	CompleteReport(ctx context.Context, id uuid.UUID, contentType string, artifact []byte) error
+	// FailReport marks a report as failed with the given reason
+	FailReport(ctx context.Context, id uuid.UUID, reason string) error
+	// BeatReports records that the reports with the given IDs are still being
+	// generated. A new report starts with a heartbeat at its creation time.
+	BeatReports(ctx context.Context, ids []uuid.UUID, now time.Time) error
+	// FailStaleReports marks the reports still pending or running whose last
+	// heartbeat is before the given time as failed, and returns how many
+	FailStaleReports(ctx context.Context, heartbeatBefore time.Time, reason string) (int, error)
+}
+
+// WorkflowStore persists the GitHub Actions runs and jobs ingested for projects
+type WorkflowStore interface {
+	// SaveWorkflowRuns upserts runs and their jobs, all or none
+	SaveWorkflowRuns(ctx context.Context, runs []models.WorkflowRun, jobs []models.WorkflowJob) error
+	// ListWorkflowRuns returns the runs of a project created between from and to, oldest first
+	ListWorkflowRuns(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowRun, error)
+	// ListWorkflowJobs returns the jobs of the project's runs created between from and to, oldest first
+	ListWorkflowJobs(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowJob, error)
+}
+
+// AuditFilter selects events in ListAuditEvents. Empty fields match everything.
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Outcome    string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+// sealAuditEvent chains event to prevHash. Timestamps are kept to the
+// microsecond, the precision Postgres stores, so hashes can be recomputed.
+func sealAuditEvent(event *models.AuditEvent, prevHash string) {
+	event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+	event.PrevHash = prevHash
+	event.Hash = event.ChainHash()
+}
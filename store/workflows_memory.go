@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"avidlogic/models"
+)
+
+// workflowKey identifies a run or job. GitHub IDs are only unique per project
+// here, as two projects may ingest the same repository.
+type workflowKey struct {
+	projectID int
+	id        int64
+}
+
+// workflowTables are the in-memory workflow runs and jobs embedded in MemoryStore
+type workflowTables struct {
+	mu   sync.RWMutex
+	runs map[workflowKey]models.WorkflowRun
+	jobs map[workflowKey]models.WorkflowJob
+}
+
+// SaveWorkflowRuns upserts workflow runs and their jobs
+func (s *MemoryStore) SaveWorkflowRuns(ctx context.Context, runs []models.WorkflowRun, jobs []models.WorkflowJob) error {
+	s.workflows.mu.Lock()
+	defer s.workflows.mu.Unlock()
+
+	if s.workflows.runs == nil {
+		s.workflows.runs = make(map[workflowKey]models.WorkflowRun)
+		s.workflows.jobs = make(map[workflowKey]models.WorkflowJob)
+	}
+	for _, run := range runs {
+		s.workflows.runs[workflowKey{run.ProjectID, run.ID}] = run
+	}
+	for _, job := range jobs {
+		s.workflows.jobs[workflowKey{job.ProjectID, job.ID}] = job
+	}
+	return nil
+}
+
+// ListWorkflowRuns returns the stored runs of a project created between from and to, oldest first
+func (s *MemoryStore) ListWorkflowRuns(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowRun, error) {
+	s.workflows.mu.RLock()
+	defer s.workflows.mu.RUnlock()
+
+	var runs []models.WorkflowRun
+	for key, run := range s.workflows.runs {
+		if key.projectID == projectID && between(run.CreatedAt, from, to) {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].CreatedAt.Before(runs[j].CreatedAt) })
+	return runs, nil
+}
+
+// ListWorkflowJobs returns the stored jobs of the project's runs created between from and to
+func (s *MemoryStore) ListWorkflowJobs(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowJob, error) {
+	s.workflows.mu.RLock()
+	defer s.workflows.mu.RUnlock()
+
+	var jobs []models.WorkflowJob
+	for key, job := range s.workflows.jobs {
+		run, ok := s.workflows.runs[workflowKey{projectID, job.RunID}]
+		if key.projectID == projectID && ok && between(run.CreatedAt, from, to) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+// between reports whether t lies in [from, to], like SQL BETWEEN
+func between(t, from, to time.Time) bool {
+	return !t.Before(from) && !t.After(to)
+}
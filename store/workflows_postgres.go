@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"avidlogic/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveWorkflowRuns upserts workflow runs and their jobs in a single transaction
+func (s *PostgresStore) SaveWorkflowRuns(ctx context.Context, runs []models.WorkflowRun, jobs []models.WorkflowJob) error {
+	return s.DB.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return saveWorkflowRuns(ctx, tx, runs, jobs)
+	})
+}
+
+// saveWorkflowRuns upserts the runs, then their jobs, in tx
+func saveWorkflowRuns(ctx context.Context, tx pgx.Tx, runs []models.WorkflowRun, jobs []models.WorkflowJob) error {
+	runQuery := `INSERT INTO workflow_runs (id, project_id, repo_name, workflow_id, workflow_name, head_sha, head_branch,
+                     event, status, conclusion, run_attempt, created_at, run_started_at, updated_at)
+                 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+                 ON CONFLICT (project_id, id) DO UPDATE SET
+                     workflow_name = EXCLUDED.workflow_name, status = EXCLUDED.status, conclusion = EXCLUDED.conclusion,
+                     run_attempt = EXCLUDED.run_attempt, run_started_at = EXCLUDED.run_started_at, updated_at = EXCLUDED.updated_at`
+	for _, run := range runs {
+		_, err := tx.Exec(ctx, runQuery, run.ID, run.ProjectID, run.RepoName, run.WorkflowID, run.WorkflowName, run.HeadSHA,
+			run.HeadBranch, run.Event, run.Status, run.Conclusion, run.RunAttempt, run.CreatedAt, run.RunStartedAt, run.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	jobQuery := `INSERT INTO workflow_jobs (id, project_id, run_id, name, head_sha, run_attempt, status, conclusion,
+                     created_at, started_at, completed_at)
+                 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+                 ON CONFLICT (project_id, id) DO UPDATE SET
+                     status = EXCLUDED.status, conclusion = EXCLUDED.conclusion,
+                     started_at = EXCLUDED.started_at, completed_at = EXCLUDED.completed_at`
+	for _, job := range jobs {
+		_, err := tx.Exec(ctx, jobQuery, job.ID, job.ProjectID, job.RunID, job.Name, job.HeadSHA, job.RunAttempt,
+			job.Status, job.Conclusion, job.CreatedAt, job.StartedAt, job.CompletedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListWorkflowRuns returns the stored runs of a project created between from and to, oldest first
+func (s *PostgresStore) ListWorkflowRuns(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowRun, error) {
+	query := `SELECT id, project_id, repo_name, workflow_id, workflow_name, head_sha, COALESCE(head_branch, ''),
+                  COALESCE(event, ''), status, COALESCE(conclusion, ''), run_attempt, created_at, run_started_at, updated_at
+              FROM workflow_runs
+              WHERE project_id = $1 AND created_at BETWEEN $2 AND $3
+              ORDER BY created_at`
+	rows, err := s.DB.Query(ctx, query, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.WorkflowRun
+	for rows.Next() {
+		var run models.WorkflowRun
+		err := rows.Scan(&run.ID, &run.ProjectID, &run.RepoName, &run.WorkflowID, &run.WorkflowName, &run.HeadSHA,
+			&run.HeadBranch, &run.Event, &run.Status, &run.Conclusion, &run.RunAttempt, &run.CreatedAt, &run.RunStartedAt, &run.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// ListWorkflowJobs returns the stored jobs of the project's runs created between from and to
+func (s *PostgresStore) ListWorkflowJobs(ctx context.Context, projectID int, from, to time.Time) ([]models.WorkflowJob, error) {
+	query := `SELECT j.id, j.project_id, j.run_id, j.name, j.head_sha, j.run_attempt, j.status, COALESCE(j.conclusion, ''),
+                  j.created_at, j.started_at, j.completed_at
+              FROM workflow_jobs j
+              JOIN workflow_runs r ON r.project_id = j.project_id AND r.id = j.run_id
+              WHERE j.project_id = $1 AND r.created_at BETWEEN $2 AND $3
+              ORDER BY j.created_at`
+	rows, err := s.DB.Query(ctx, query, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.WorkflowJob
+	for rows.Next() {
+		var job models.WorkflowJob
+		err := rows.Scan(&job.ID, &job.ProjectID, &job.RunID, &job.Name, &job.HeadSHA, &job.RunAttempt, &job.Status,
+			&job.Conclusion, &job.CreatedAt, &job.StartedAt, &job.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
@@ -0,0 +1,161 @@
+package validation
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// prefixLength is the length of the hash prefix of a k-anonymity range
+const prefixLength = 5
+
+// chunkSize is how much of the list is read at a time
+const chunkSize = 4096
+
+// BreachedList is a local copy of a breached password list in the Pwned
+// Passwords format: one uppercase SHA-1 hash per line, optionally followed by
+// ":count", sorted by hash. Lookups follow the k-anonymity model of the Pwned
+// Passwords range API: only the range of hashes sharing the first five hex
+// digits is read, then matched locally, so the list could equally be served
+// remotely. The file is searched in place and never loaded into memory.
+type BreachedList struct {
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenBreachedList opens the list at path
+func OpenBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &BreachedList{file: file, size: info.Size()}, nil
+}
+
+// Close closes the list file
+func (l *BreachedList) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Breached reports whether password is on the list. A nil list contains nothing.
+func (l *BreachedList) Breached(password string) (bool, error) {
+	if l == nil {
+		return false, nil
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := l.Range(hash[:prefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[prefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Range returns the hash suffixes of the list starting with the five hex digit prefix
+func (l *BreachedList) Range(prefix string) ([]string, error) {
+	prefix = strings.ToUpper(prefix)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Binary search for the first line at or after the prefix
+	var searchErr error
+	pos := sort.Search(int(l.size)+1, func(i int) bool {
+		start, err := l.lineStart(int64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		if start >= l.size {
+			return true
+		}
+		line, err := l.line(start)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return line >= prefix
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	start, err := l.lineStart(int64(pos))
+	if err != nil {
+		return nil, err
+	}
+	var suffixes []string
+	for start < l.size {
+		line, err := l.line(start)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, prefix) {
+			break
+		}
+		hash, _, _ := strings.Cut(strings.TrimSuffix(line, "\r"), ":")
+		suffixes = append(suffixes, hash[len(prefix):])
+		start += int64(len(line)) + 1
+	}
+	return suffixes, nil
+}
+
+// lineStart returns the offset of the first line starting at or after pos
+func (l *BreachedList) lineStart(pos int64) (int64, error) {
+	if pos == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, chunkSize)
+	for offset := pos - 1; offset < l.size; offset += chunkSize {
+		n, err := l.file.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return offset + int64(i) + 1, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+	}
+	return l.size, nil
+}
+
+// line reads the line starting at start, up to but excluding the \n, so
+// that the next line starts after its length plus one
+func (l *BreachedList) line(start int64) (string, error) {
+	var line []byte
+	buf := make([]byte, chunkSize)
+	for offset := start; offset < l.size; offset += chunkSize {
+		n, err := l.file.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			line = append(line, buf[:i]...)
+			break
+		}
+		line = append(line, buf[:n]...)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.ToUpper(string(line)), nil
+}
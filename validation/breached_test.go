@@ -0,0 +1,106 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// breachedFixture is a sorted list in the Pwned Passwords format, holding the
+// hashes of "password", "123456" and "qwerty" and a few others
+var breachedFixture = []string{
+	"0000A1F4A8E6B9F8D5D9E0A1B2C3D4E5F6A7B8C9:3",
+	"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824",
+	"5BAA6FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1",
+	"7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195",
+	"98DEC00000000000000000000000000000000000:2", // shares the prefix of "correct horse battery"
+	"B1B3773A05C0ED0176787A4F1574FF0075F7521E:3912816",
+}
+
+// openFixture writes the fixture lines joined by sep, with a final sep when
+// trailing is set, and opens it
+func openFixture(t *testing.T, sep string, trailing bool) *BreachedList {
+	t.Helper()
+	content := strings.Join(breachedFixture, sep)
+	if trailing {
+		content += sep
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := OpenBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { list.Close() })
+	return list
+}
+
+func TestBreachedListRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{"first line", "0000A", []string{"1F4A8E6B9F8D5D9E0A1B2C3D4E5F6A7B8C9"}},
+		{"several lines", "5BAA6", []string{"1E4C9B93F3F0682250B6CF8331B7EE68FD8", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"}},
+		{"lowercase prefix", "7c4a8", []string{"D09CA3762AF61E59520943DC26494F8941B"}},
+		{"last line", "B1B37", []string{"73A05C0ED0176787A4F1574FF0075F7521E"}},
+		{"before the first line", "00000", nil},
+		{"between lines", "5BAA7", nil},
+		{"after the last line", "FFFFF", nil},
+	}
+	for _, format := range []struct {
+		name     string
+		sep      string
+		trailing bool
+	}{
+		{"LF", "\n", true},
+		{"CRLF", "\r\n", true},
+		{"no final newline", "\n", false},
+	} {
+		list := openFixture(t, format.sep, format.trailing)
+		for _, tt := range tests {
+			t.Run(format.name+"/"+tt.name, func(t *testing.T) {
+				got, err := list.Range(tt.prefix)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestBreached(t *testing.T) {
+	list := openFixture(t, "\n", true)
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"123456", true},
+		{"qwerty", true},
+		{"correct horse battery", false}, // its prefix is listed, its suffix is not
+		{"Password", false},
+	}
+	for _, tt := range tests {
+		got, err := list.Breached(tt.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Breached(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+
+	// Without a list, nothing is breached
+	var none *BreachedList
+	if got, err := none.Breached("password"); got || err != nil {
+		t.Errorf("nil list: got %v (%v), want false", got, err)
+	}
+}
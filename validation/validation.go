@@ -0,0 +1,104 @@
+// Package validation adds the request validation rules of the API to gin's
+// validator: username and password policies and the name formats of GitHub
+// accounts and repositories. It also checks passwords against a list of
+// breached passwords.
+package validation
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Password length bounds. bcrypt ignores everything past 72 bytes.
+const (
+	MinPasswordLength = 10
+	MaxPasswordLength = 72
+)
+
+// Username length bounds
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+)
+
+// Messages describes the rules registered here, by tag, for validation errors
+var Messages = map[string]string{
+	"username":    "must be 3 to 32 letters, digits, '.', '_' or '-', starting with a letter or digit",
+	"password":    "must be 10 to 72 bytes long and not only whitespace",
+	"github_name": "must be a GitHub account name: up to 39 letters, digits or single hyphens, not starting or ending with a hyphen",
+	"repo_names":  "must be a comma-separated list of repository names",
+}
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterValidation("username", func(fl validator.FieldLevel) bool { return Username(fl.Field().String()) })
+		v.RegisterValidation("password", func(fl validator.FieldLevel) bool { return Password(fl.Field().String()) })
+		v.RegisterValidation("github_name", func(fl validator.FieldLevel) bool { return GitHubName(fl.Field().String()) })
+		v.RegisterValidation("repo_names", func(fl validator.FieldLevel) bool { return RepoNames(fl.Field().String()) })
+	}
+}
+
+// Username reports whether name is an acceptable username
+func Username(name string) bool {
+	if len(name) < minUsernameLength || len(name) > maxUsernameLength || !alphanumeric(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !alphanumeric(name[i]) && !strings.ContainsRune("._-", rune(name[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Password reports whether password meets the length policy. Whether it was
+// breached is checked separately, see BreachedList.
+func Password(password string) bool {
+	return len(password) >= MinPasswordLength && len(password) <= MaxPasswordLength &&
+		utf8.ValidString(password) && strings.TrimSpace(password) != ""
+}
+
+// GitHubName reports whether name is a valid GitHub user or organization name
+func GitHubName(name string) bool {
+	if name == "" || len(name) > 39 || name[0] == '-' || name[len(name)-1] == '-' || strings.Contains(name, "--") {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !alphanumeric(name[i]) && name[i] != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// GitHubRepo reports whether name is a valid GitHub repository name
+func GitHubRepo(name string) bool {
+	if name == "" || len(name) > 100 || name == "." || name == ".." {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !alphanumeric(name[i]) && !strings.ContainsRune("._-", rune(name[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// RepoNames reports whether list is a comma-separated list of repository
+// names. The names themselves are checked per forge, see GitHubRepo.
+func RepoNames(list string) bool {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+func alphanumeric(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"shortest", strings.Repeat("a", MinPasswordLength), true},
+		{"too short", strings.Repeat("a", MinPasswordLength-1), false},
+		{"longest", strings.Repeat("a", MaxPasswordLength), true},
+		{"too long for bcrypt", strings.Repeat("a", MaxPasswordLength+1), false},
+		{"length in bytes", strings.Repeat("é", 5), true},
+		{"multibyte over the limit", strings.Repeat("é", MaxPasswordLength/2) + "a", false},
+		{"inner spaces", "correct horse battery", true},
+		{"only whitespace", strings.Repeat(" \t", 6), false},
+		{"invalid UTF-8", "correct horse\xff", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Password(tt.password); got != tt.want {
+				t.Errorf("Password(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsername(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"abc", true},
+		{"alice.smith_2-b", true},
+		{"ab", false},
+		{strings.Repeat("a", 32), true},
+		{strings.Repeat("a", 33), false},
+		{"_alice", false},
+		{".alice", false},
+		{"alice smith", false},
+		{"alicé", false},
+	}
+	for _, tt := range tests {
+		if got := Username(tt.name); got != tt.want {
+			t.Errorf("Username(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}